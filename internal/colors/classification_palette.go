@@ -0,0 +1,63 @@
+package colors
+
+import (
+	"math"
+)
+
+// Colors of the classifications defined by the ASPRS LAS specifications
+var classificationPalette = [...][3]uint8{
+	{190, 190, 190}, // 0 created, never classified
+	{170, 170, 170}, // 1 unclassified
+	{166, 118, 60},  // 2 ground
+	{144, 238, 144}, // 3 low vegetation
+	{60, 179, 113},  // 4 medium vegetation
+	{0, 128, 0},     // 5 high vegetation
+	{220, 60, 40},   // 6 building
+	{255, 0, 255},   // 7 low point (noise)
+	{255, 255, 0},   // 8 model key-point
+	{30, 120, 230},  // 9 water
+	{120, 80, 60},   // 10 rail
+	{80, 80, 80},    // 11 road surface
+	{255, 200, 0},   // 12 overlap
+	{255, 230, 160}, // 13 wire guard (shield)
+	{255, 180, 0},   // 14 wire conductor (phase)
+	{160, 80, 200},  // 15 transmission tower
+	{200, 140, 255}, // 16 wire-structure connector (insulator)
+	{140, 140, 180}, // 17 bridge deck
+	{255, 0, 128},   // 18 high noise
+}
+
+// Returns the color of the given classification. Reserved and user defined classifications get distinct colors,
+// spacing their hues by the golden angle
+func GetClassificationColor(classification uint8) (uint8, uint8, uint8) {
+	if int(classification) < len(classificationPalette) {
+		color := classificationPalette[classification]
+		return color[0], color[1], color[2]
+	}
+
+	return hsvToRgb(math.Mod(float64(classification)*137.508, 360), 0.6, 0.9)
+}
+
+// converts a color given as hue in degrees, saturation and value between 0 and 1 to RGB
+func hsvToRgb(hue, saturation, value float64) (uint8, uint8, uint8) {
+	chroma := value * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := value - chroma
+
+	return uint8(math.Round((r + m) * 255)), uint8(math.Round((g + m) * 255)), uint8(math.Round((b + m) * 255))
+}
@@ -0,0 +1,126 @@
+package colors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Defines how the colors of the points are computed
+type Mode string
+
+const (
+	// Keeps the colors stored in the las files
+	ModeLas Mode = "LAS"
+
+	// Colors the points along a ramp by their elevation
+	ModeElevation Mode = "ELEVATION"
+
+	// Colors the points along a ramp by their intensity
+	ModeIntensity Mode = "INTENSITY"
+
+	// Colors the points with the palette of the ASPRS classifications
+	ModeClassification Mode = "CLASSIFICATION"
+
+	// Colors the points along a ramp by their height above the ground
+	ModeHeightAboveGround Mode = "HEIGHT-ABOVE-GROUND"
+)
+
+// Returns the mode with the given name, which can be las, elevation, intensity, classification or height-above-ground
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToUpper(strings.TrimSpace(name))); mode {
+	case "":
+		return ModeLas, nil
+	case ModeLas, ModeElevation, ModeIntensity, ModeClassification, ModeHeightAboveGround:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown color mode %q", name)
+}
+
+// Percent of the intensities excluded at each end of the automatic intensity range, so that a few very bright returns
+// do not darken all the other points
+const intensityRangePercentile = 1
+
+// Number of distinct 16 bit intensities stored in las files
+const IntensityLevels = 1 << 16
+
+// Height in meters mapped to the end of the ramp of the height-above-ground mode when no range is given. The range is
+// fixed rather than taken from the data since heights are computed separately for each file, which would otherwise get
+// different colors for the same height
+const DefaultHeightAboveGroundMax = 30.0
+
+// Colors the points according to a mode
+type ModeColorizer struct {
+	mode Mode
+	ramp *Ramp
+	min  float64
+	max  float64
+}
+
+// Builds a colorizer for the given mode. Elevations and intensities are mapped on the ramp between the given min
+// and max values. If the ramp is nil the hypsometric one is used for elevations, the viridis one for heights above
+// ground and the grayscale one for intensities
+func NewModeColorizer(mode Mode, ramp *Ramp, min float64, max float64) *ModeColorizer {
+	if ramp == nil {
+		switch mode {
+		case ModeElevation:
+			ramp = Hypsometric
+		case ModeHeightAboveGround:
+			ramp = Viridis
+		default:
+			ramp = Grayscale
+		}
+	}
+
+	return &ModeColorizer{
+		mode: mode,
+		ramp: ramp,
+		min:  min,
+		max:  max,
+	}
+}
+
+// Returns the color of a point with the given elevation, 16 bit intensity, as stored in the las file, and classification.
+// In the height-above-ground mode z is the height of the point above the ground
+func (c *ModeColorizer) Colorize(z float64, intensity uint16, classification uint8) (uint8, uint8, uint8) {
+	switch c.mode {
+	case ModeElevation, ModeHeightAboveGround:
+		return c.ramp.GetColor(c.normalize(z))
+	case ModeIntensity:
+		return c.ramp.GetColor(c.normalize(float64(intensity)))
+	}
+
+	return GetClassificationColor(classification)
+}
+
+// returns the position of the given value between min and max
+func (c *ModeColorizer) normalize(value float64) float64 {
+	if c.max <= c.min {
+		return 0.5
+	}
+	return (value - c.min) / (c.max - c.min)
+}
+
+// Returns the automatic range of the intensity mode given the histogram of the 16 bit intensities of the points,
+// excluding the lowest and highest percent of them. Sensors store intensities in different ranges, e.g. 0-255, 0-4095
+// or 0-65535, hence the range is taken from the data. Returns 0 and 65535 if the histogram is empty
+func GetIntensityRange(histogram *[IntensityLevels]int64) (float64, float64) {
+	var total int64
+	for _, count := range histogram {
+		total += count
+	}
+	if total == 0 {
+		return 0, IntensityLevels - 1
+	}
+
+	excluded := total * intensityRangePercentile / 100
+	min, max := 0, IntensityLevels-1
+	for count := histogram[min]; count <= excluded && min < IntensityLevels-1; count += histogram[min] {
+		min++
+	}
+	for count := histogram[max]; count <= excluded && max > min; count += histogram[max] {
+		max--
+	}
+
+	return float64(min), float64(max)
+}
@@ -0,0 +1,91 @@
+package colors
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Color ramp interpolating linearly between color stops placed at increasing positions between 0 and 1
+type Ramp struct {
+	stops []rampStop
+}
+
+type rampStop struct {
+	position float64
+	color    [3]float64
+}
+
+// Ramps available by name
+var (
+	Grayscale = &Ramp{stops: []rampStop{
+		{0, [3]float64{0, 0, 0}},
+		{1, [3]float64{255, 255, 255}},
+	}}
+	// from lowlands green to yellow, brown and the white of the peaks
+	Hypsometric = &Ramp{stops: []rampStop{
+		{0, [3]float64{0, 97, 71}},
+		{0.1, [3]float64{16, 122, 47}},
+		{0.25, [3]float64{232, 215, 125}},
+		{0.5, [3]float64{161, 67, 0}},
+		{0.75, [3]float64{130, 130, 130}},
+		{1, [3]float64{255, 255, 255}},
+	}}
+	Viridis = &Ramp{stops: []rampStop{
+		{0, [3]float64{68, 1, 84}},
+		{0.25, [3]float64{59, 82, 139}},
+		{0.5, [3]float64{33, 145, 140}},
+		{0.75, [3]float64{94, 201, 98}},
+		{1, [3]float64{253, 231, 37}},
+	}}
+	Rainbow = &Ramp{stops: []rampStop{
+		{0, [3]float64{0, 0, 255}},
+		{0.25, [3]float64{0, 255, 255}},
+		{0.5, [3]float64{0, 255, 0}},
+		{0.75, [3]float64{255, 255, 0}},
+		{1, [3]float64{255, 0, 0}},
+	}}
+)
+
+// Returns the color of the ramp at the given position, clamped between 0 and 1
+func (r *Ramp) GetColor(position float64) (uint8, uint8, uint8) {
+	if math.IsNaN(position) || position < 0 {
+		position = 0
+	}
+	if position > 1 {
+		position = 1
+	}
+
+	i := 1
+	for i < len(r.stops)-1 && r.stops[i].position < position {
+		i++
+	}
+	start, end := r.stops[i-1], r.stops[i]
+	t := (position - start.position) / (end.position - start.position)
+
+	var color [3]uint8
+	for c := range color {
+		color[c] = uint8(math.Round(start.color[c] + (end.color[c]-start.color[c])*t))
+	}
+
+	return color[0], color[1], color[2]
+}
+
+// Returns the ramp with the given name, which can be grayscale, hypsometric, viridis or rainbow. Returns nil for an
+// empty name
+func ParseRamp(name string) (*Ramp, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return nil, nil
+	case "grayscale":
+		return Grayscale, nil
+	case "hypsometric":
+		return Hypsometric, nil
+	case "viridis":
+		return Viridis, nil
+	case "rainbow":
+		return Rainbow, nil
+	}
+
+	return nil, fmt.Errorf("unknown color ramp %q", name)
+}
@@ -2,24 +2,30 @@ package geoid_elevation_corrector
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset"
 	"log"
 )
 
+// Size in meters of the cells of the grid the offsets are cached on
+const cellSize = 100
+
 type GeoidElevationCorrector struct {
 	srid             int
 	offsetCalculator converters.EllipsoidToGeoidOffsetCalculator
 }
 
+// Inits a corrector for coordinates in the given srid. Offsets are cached on a grid of metric cells over the WGS84
+// longitudes and latitudes of the points and interpolated between its nodes
 func NewGeoidElevationCorrector(srid int, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator) converters.ElevationCorrector {
-	// TODO: by default we are using the ellipsoidToGeoidSinglePointConverter as the old buffered converter
-	//  suffers of coupling problems with the srid of data. It needs a cell size but this depends on the SRID and
-	//  thus either a way to dynamically estabilish according to the srid is found or we can only use it if data is in 4326 srid
-	//  which introduces an undocumented requirement. We need to fix the EllipsoidToGeoidBufferedCalculator to allow it
-	//  to be used here
+	var coordinateConverter converters.CoordinateConverter
+	if srid != 4326 {
+		coordinateConverter = proj4_coordinate_converter.NewProj4CoordinateConverter()
+	}
+
 	return &GeoidElevationCorrector{
 		srid:             srid,
-		offsetCalculator: geoid_offset.NewEllipsoidToGeoidSinglePointCalculator(ellipsoidToGeoidOffsetCalculator),
+		offsetCalculator: geoid_offset.NewEllipsoidToGeoidBufferedCalculatorWithUnit(cellSize, geoid_offset.Meters, coordinateConverter, ellipsoidToGeoidOffsetCalculator),
 	}
 }
 
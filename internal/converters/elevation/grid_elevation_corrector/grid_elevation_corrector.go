@@ -0,0 +1,27 @@
+package grid_elevation_corrector
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geoid"
+	"log"
+)
+
+// Converts the elevations above the geoid to elevations above the ellipsoid adding the undulation interpolated from
+// a geoid grid. Coordinates must be WGS84 longitudes and latitudes
+type GridElevationCorrector struct {
+	grid *geoid.Grid
+}
+
+func NewGridElevationCorrector(grid *geoid.Grid) converters.ElevationCorrector {
+	return &GridElevationCorrector{
+		grid: grid,
+	}
+}
+
+func (c *GridElevationCorrector) CorrectElevation(lon, lat, z float64) float64 {
+	undulation, ok := c.grid.GetUndulation(lon, lat)
+	if !ok {
+		log.Fatalf("no geoid undulation available at longitude %.6f, latitude %.6f: the point is outside the geoid grid", lon, lat)
+	}
+	return z + undulation
+}
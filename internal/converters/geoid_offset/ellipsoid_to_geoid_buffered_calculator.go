@@ -2,78 +2,136 @@ package geoid_offset
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"math"
 	"sync"
 )
 
+// Units of the size of the cells of the EllipsoidToGeoidBufferedCalculator
+type CellUnit string
+
+const (
+	// Cells are squares in geographic coordinates with sides of the given size in degrees
+	Degrees CellUnit = "DEGREES"
+
+	// Cells are approximately squares on the ground with sides of the given size in meters, measured at the latitude of
+	// the first point converted
+	Meters CellUnit = "METERS"
+)
+
+// WGS84 semi major axis and squared eccentricity, used to compute the size of the metric cells in degrees
+const (
+	semiMajorAxis       = 6378137.0
+	eccentricitySquared = 0.00669437999014
+)
+
+const toRadians = math.Pi / 180
+
 // Represent minimal data necessary to provide an efficient, cache-based solution for the massive geodetic to ellipsoidic height conversion
 type EllipsoidToGeoidBufferedCalculator struct {
 	CellSize                         float64
-	GeoidHeightMap                   sync.Map
+	CellUnit                         CellUnit
+	GeoidHeightMap                   sync.Map // undulations of the grid nodes, by gridNode
+	coordinateConverter              converters.CoordinateConverter
 	ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator
+	spacingOnce                      sync.Once
+	dLon                             float64
+	dLat                             float64
 }
 
-// Inits a new instance of EllipsoidToGeoidBufferedCalculator for the given Srid and with given caching cell size. To all points with X,Y coordinates
-// falling inside a square cell with side equal to cellSize will be applied the same, eventually cached, elevation transformation.
-// Choosing a small value for cell size improves the accuracy but increases computation times. Cell sizes approximately
-// equivalent to 1m are acceptable approximations
+// node of the grid of cached undulations, by column and row
+type gridNode struct {
+	column, row int
+}
+
+// Inits a new instance of EllipsoidToGeoidBufferedCalculator caching the offsets on a grid with the given cell size in
+// degrees, converting the coordinates to EPSG:4326 with a proj4 coordinate converter
 func NewEllipsoidToGeoidBufferedCalculator(cellSize float64, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator) converters.EllipsoidToGeoidOffsetCalculator {
+	return NewEllipsoidToGeoidBufferedCalculatorWithUnit(cellSize, Degrees, proj4_coordinate_converter.NewProj4CoordinateConverter(), ellipsoidToGeoidOffsetCalculator)
+}
+
+// Inits a new instance of EllipsoidToGeoidBufferedCalculator for the given caching cell size and unit. Coordinates are
+// converted to EPSG:4326 with the given converter, whatever their srid, and the offsets are computed and cached at the
+// corners of the cells of a grid over longitudes and latitudes. The offset of each point is interpolated bilinearly
+// between the corners of the cell containing it. Choosing a small value for cell size improves the accuracy but
+// increases computation times. Geoid undulations are smooth enough that cells of 100m give errors below a millimeter
+func NewEllipsoidToGeoidBufferedCalculatorWithUnit(cellSize float64, unit CellUnit, coordinateConverter converters.CoordinateConverter, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator) converters.EllipsoidToGeoidOffsetCalculator {
 	return &EllipsoidToGeoidBufferedCalculator{
 		CellSize:                         cellSize,
+		CellUnit:                         unit,
+		coordinateConverter:              coordinateConverter,
 		ellipsoidToGeoidOffsetCalculator: ellipsoidToGeoidOffsetCalculator,
 	}
 }
 
 func (bc *EllipsoidToGeoidBufferedCalculator) GetEllipsoidToGeoidOffset(lon, lat float64, srid int) (float64, error) {
-	x := bc.getCellIndex(lon)
-	y := bc.getCellIndex(lat)
-
-	yMap, yMapPresent := bc.GeoidHeightMap.Load(x)
-
-	if !yMapPresent {
-		var temp sync.Map
-		bc.GeoidHeightMap.Store(x, &temp)
-		yMap = &temp
+	if srid != 4326 {
+		coordinate, err := bc.coordinateConverter.ConvertCoordinateSrid(srid, 4326, geometry.Coordinate{X: lon, Y: lat})
+		if err != nil {
+			return 0, err
+		}
+		lon, lat = coordinate.X, coordinate.Y
 	}
-
-	yVal, yValPresent := yMap.(*sync.Map).Load(y)
-	if yValPresent {
-		// return cached result
-		return yVal.(float64), nil
+	bc.spacingOnce.Do(func() {
+		bc.dLon, bc.dLat = bc.getCellSpacing(lat)
+	})
+
+	x, y := lon/bc.dLon, lat/bc.dLat
+	column, row := math.Floor(x), math.Floor(y)
+	tx, ty := x-column, y-row
+
+	var offset float64
+	for _, node := range [4]struct {
+		column, row int
+		weight      float64
+	}{
+		{int(column), int(row), (1 - tx) * (1 - ty)},
+		{int(column) + 1, int(row), tx * (1 - ty)},
+		{int(column), int(row) + 1, (1 - tx) * ty},
+		{int(column) + 1, int(row) + 1, tx * ty},
+	} {
+		if node.weight == 0 {
+			continue
+		}
+		nodeOffset, err := bc.getNodeOffset(gridNode{column: node.column, row: node.row})
+		if err != nil {
+			return 0, err
+		}
+		offset += nodeOffset * node.weight
 	}
 
-	// else compute offset and store in cache
-	off, err := computeAndStoreInMap(x, y, srid, bc, yMap.(*sync.Map))
+	return offset, nil
+}
 
-	if err != nil {
-		return 0, err
+// returns the size of the cells in degrees of longitude and latitude
+func (bc *EllipsoidToGeoidBufferedCalculator) getCellSpacing(lat float64) (float64, float64) {
+	if bc.CellUnit != Meters {
+		return bc.CellSize, bc.CellSize
 	}
 
-	// return offset + input elevation
-	return off, nil
-}
+	sinLat := math.Sin(lat * toRadians)
+	w := math.Sqrt(1 - eccentricitySquared*sinLat*sinLat)
+	meridianRadius := semiMajorAxis * (1 - eccentricitySquared) / (w * w * w)
+	primeVerticalRadius := semiMajorAxis / w
+	// avoids infinite cells at the poles
+	cosLat := math.Max(math.Cos(lat*toRadians), 1e-9)
 
-func (bc *EllipsoidToGeoidBufferedCalculator) getCellIndex(dimensionValue float64) int {
-	return int(math.Floor(dimensionValue / bc.CellSize))
+	return bc.CellSize / (primeVerticalRadius * cosLat * toRadians), bc.CellSize / (meridianRadius * toRadians)
 }
 
-func (bc *EllipsoidToGeoidBufferedCalculator) getCellCenter(x, y int) (float64, float64) {
-	return float64(x)*bc.CellSize + bc.CellSize/2, float64(y)*bc.CellSize + bc.CellSize/2
-}
-
-func computeAndStoreInMap(x, y, srid int, elevationConverterBuffer *EllipsoidToGeoidBufferedCalculator, yMap *sync.Map) (float64, error) {
-	off, err := elevationConverterBuffer.getCellEllipsoidToGeoidOffset(x, y, srid)
+// returns the offset of the given grid node, computing and caching it if needed
+func (bc *EllipsoidToGeoidBufferedCalculator) getNodeOffset(node gridNode) (float64, error) {
+	if offset, ok := bc.GeoidHeightMap.Load(node); ok {
+		// return cached result
+		return offset.(float64), nil
+	}
 
+	offset, err := bc.ellipsoidToGeoidOffsetCalculator.GetEllipsoidToGeoidOffset(float64(node.row)*bc.dLat, float64(node.column)*bc.dLon, 4326)
 	if err != nil {
 		return 0, err
 	}
+	bc.GeoidHeightMap.Store(node, offset)
 
-	yMap.Store(y, off)
-	return off, nil
-}
-
-func (bc *EllipsoidToGeoidBufferedCalculator) getCellEllipsoidToGeoidOffset(x, y, srid int) (float64, error) {
-	cX, cY := bc.getCellCenter(x, y)
-
-	return bc.ellipsoidToGeoidOffsetCalculator.GetEllipsoidToGeoidOffset(cY, cX, srid)
+	return offset, nil
 }
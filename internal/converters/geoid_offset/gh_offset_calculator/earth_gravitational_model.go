@@ -2,6 +2,7 @@ package gh_offset_calculator
 
 import (
 	"bufio"
+	"fmt"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
 	"log"
 	"math"
@@ -9,6 +10,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const sqrt03 = 1.7320508075688772935274463415059
@@ -16,7 +18,20 @@ const sqrt05 = 2.2360679774997896964091736687313
 const sqrt13 = 3.6055512754639892931192212674705
 const sqrt17 = 4.1231056256176605498214098559741
 const sqrt21 = 4.5825756949558400065880471937280
-const defaultOrder = 180
+
+// Name of the EGM180 model shipped in the assets folder, used by default
+const DefaultModel = "egm180"
+
+// Degree the default model is truncated at unless another one is given. Previous versions summed the coefficients up
+// to degree 179, which is kept so that the corrected elevations do not change
+const defaultModelDegree = 179
+
+// Number of latitude rows cached per wavelength of the highest degree of the model. Undulations between two rows are
+// linearly interpolated, with errors well below a millimeter
+const rowsPerWavelength = 512
+
+// Max number of sums stored by the cached latitude rows, 128MB. The cache is emptied when it is full
+const maxCachedSums = 1 << 24
 
 type egm struct {
 	wgs84                    bool
@@ -29,103 +44,168 @@ type egm struct {
 	star                     float64
 	cnmGeopCoef, snmGeopCoef []float64
 	aClenshav, bClenshaw, as []float64
+	rowSpacing               float64
+	rows                     map[int]*latitudeRow
+	rowsLock                 sync.RWMutex
+}
+
+// Terms of the spherical harmonic synthesis depending only on the latitude: the sums over the degrees of each order
+// and the factors of the sum over the orders
+type latitudeRow struct {
+	cosineSums  []float64
+	sineSums    []float64
+	as          []float64
+	orderFactor float64
+	f1          float64
+	f2y         float64
+	scale       float64
 }
 
 // Inits a new earth gravitational model according to the default parameters
 func newDefaultEarthGravitationalModel() *egm {
-	return newEarthGraviationalModel(defaultOrder, true)
+	model, err := newEarthGravitationalModel(DefaultModel, 0, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return model
 }
 
-func newEarthGraviationalModel(nmax int, wgs84 bool) *egm {
-	model := egm{
-		nmax:  nmax,
-		wgs84: wgs84,
+// Loads the model with the given name, i.e. egm180 for the one shipped in the assets folder, or the path of a file
+// storing its normalized coefficients. Coefficients are truncated at the given degree, 0 for the max degree of the
+// model or the default one of egm180
+func newEarthGravitationalModel(model string, degree int, wgs84 bool) (*egm, error) {
+	filename := model
+	defaultDegree := 0
+	if model == "" || strings.EqualFold(model, DefaultModel) {
+		filename = path.Join(tools.GetRootFolder(), "assets", "egm180.nor")
+		defaultDegree = defaultModelDegree
+	}
+	coefficients, maxDegree, err := readCoefficients(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error loading gravitational model data: %v", err)
+	}
+	if degree == 0 {
+		degree = maxDegree
+		if defaultDegree > 0 {
+			degree = defaultDegree
+		}
+	}
+	if degree < 2 || degree > maxDegree {
+		return nil, fmt.Errorf("invalid degree %d, the gravitational model has degree %d", degree, maxDegree)
+	}
+
+	// coefficients are summed up to nmax - 1
+	nmax := degree + 1
+	egm := egm{
+		nmax:       nmax,
+		wgs84:      wgs84,
+		rowSpacing: 360 / float64(degree) / rowsPerWavelength,
+		rows:       make(map[int]*latitudeRow),
 	}
 	if wgs84 {
-		model.semiMajor = 6378137.0
-		model.esq = 0.00669437999013
-		model.c2 = 108262.9989050e-8
-		model.rkm = 3.986004418e+14
-		model.grava = 9.7803267714
-		model.star = 0.001931851386
+		egm.semiMajor = 6378137.0
+		egm.esq = 0.00669437999013
+		egm.c2 = 108262.9989050e-8
+		egm.rkm = 3.986004418e+14
+		egm.grava = 9.7803267714
+		egm.star = 0.001931851386
 	} else {
-		model.semiMajor = 6378135.0
-		model.esq = 0.006694317778
-		model.c2 = 108263.0e-8
-		model.rkm = 3.986005e+14
-		model.grava = 9.7803327
-		model.star = 0.005278994
+		egm.semiMajor = 6378135.0
+		egm.esq = 0.006694317778
+		egm.c2 = 108263.0e-8
+		egm.rkm = 3.986005e+14
+		egm.grava = 9.7803327
+		egm.star = 0.005278994
 	}
 	cleanshawLength := locatingArray(nmax + 3)
 	geopCoefLength := locatingArray(nmax + 1)
-	model.aClenshav = make([]float64, cleanshawLength)
-	model.bClenshaw = make([]float64, cleanshawLength)
-	model.cnmGeopCoef = make([]float64, geopCoefLength)
-	model.snmGeopCoef = make([]float64, geopCoefLength)
-	model.as = make([]float64, nmax+1)
+	egm.aClenshav = make([]float64, cleanshawLength)
+	egm.bClenshaw = make([]float64, cleanshawLength)
+	egm.cnmGeopCoef = make([]float64, geopCoefLength)
+	egm.snmGeopCoef = make([]float64, geopCoefLength)
+	egm.as = make([]float64, nmax+1)
 
-	exPath := tools.GetRootFolder()
-
-	// Loading Earth Gravitational Model data
-	err := model.load(path.Join(exPath, "assets", "egm180.nor"))
-	if err != nil {
-		log.Fatal("error loading gravitational model data", err)
+	for _, coefficient := range coefficients {
+		if coefficient.n < egm.nmax {
+			ll := locatingArray(coefficient.n) + coefficient.m
+			egm.cnmGeopCoef[ll] = coefficient.cbar
+			egm.snmGeopCoef[ll] = coefficient.sbar
+		}
 	}
+	egm.initialize()
 
-	return &model
+	return &egm, nil
 }
 
 func locatingArray(n int) int {
 	return ((n + 1) * n) >> 1
 }
 
-func (egm *egm) load(filename string) error {
+// fully normalized coefficient of the given degree and order
+type coefficient struct {
+	n, m       int
+	cbar, sbar float64
+}
+
+// reads the coefficients of a model, stored one per line as degree, order, C and S, optionally followed by other
+// values such as their standard deviations, returning them with the max degree found. Numbers can use the D exponent
+// of Fortran. Degrees 0 and 1 are skipped
+func readCoefficients(filename string) ([]coefficient, int, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, err
 	}
 	defer file.Close()
+
+	var coefficients []coefficient
+	maxDegree := 0
+	fortranExponent := strings.NewReplacer("D", "E", "d", "e")
 	scanner := bufio.NewScanner(file)
+	line := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		tokens := strings.Split(line, " ")
-		i := 0
-		for _, token := range tokens {
-			if token != "" && token != " " {
-				// copy and increment index
-				tokens[i] = token
-				i++
-			}
-		}
-		tokens = tokens[:i]
+		line++
+		tokens := strings.Fields(fortranExponent.Replace(scanner.Text()))
+		if len(tokens) == 0 {
+			continue
+		}
+		if len(tokens) < 4 {
+			return nil, 0, fmt.Errorf("line %d: expected degree, order, C and S", line)
+		}
 		n, err := strconv.Atoi(tokens[0])
 		if err != nil {
-			return err
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
 		}
 		m, err := strconv.Atoi(tokens[1])
 		if err != nil {
-			return err
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
 		}
 		cbar, err := strconv.ParseFloat(tokens[2], 64)
 		if err != nil {
-			return err
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
 		}
 		sbar, err := strconv.ParseFloat(tokens[3], 64)
 		if err != nil {
-			return err
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		if m < 0 || m > n {
+			return nil, 0, fmt.Errorf("line %d: invalid order %d of degree %d", line, m, n)
 		}
-		if n < egm.nmax {
-			ll := locatingArray(n) + m
-			egm.cnmGeopCoef[ll] = cbar
-			egm.snmGeopCoef[ll] = sbar
+		if n < 2 {
+			continue
+		}
+		coefficients = append(coefficients, coefficient{n: n, m: m, cbar: cbar, sbar: sbar})
+		if n > maxDegree {
+			maxDegree = n
 		}
 	}
-
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return nil, 0, err
 	}
-	egm.initialize()
-	return nil
+	if maxDegree < 2 {
+		return nil, 0, fmt.Errorf("no coefficients found in %s", filename)
+	}
+
+	return coefficients, maxDegree, nil
 }
 
 func (egm *egm) initialize() {
@@ -166,9 +246,50 @@ func (egm *egm) initialize() {
 	}
 }
 
+// Returns the geoid undulation at the given longitude, latitude and height
 func (egm *egm) heightOffset(lon, lat, height float64) float64 {
-	cr := make([]float64, egm.nmax+1)
-	sr := make([]float64, egm.nmax+1)
+	return egm.getLatitudeRow(lat, height).evaluate(lon)
+}
+
+// Returns the geoid undulation at the given longitude and latitude, interpolated between the two closest cached
+// latitude rows. The cost of each evaluation grows linearly with the degree of the model rather than quadratically
+func (egm *egm) cachedHeightOffset(lon, lat float64) float64 {
+	position := lat / egm.rowSpacing
+	index := int(math.Floor(position))
+	t := position - float64(index)
+
+	south := egm.getCachedLatitudeRow(index).evaluate(lon)
+	if t == 0 {
+		return south
+	}
+	north := egm.getCachedLatitudeRow(index + 1).evaluate(lon)
+
+	return south*(1-t) + north*t
+}
+
+// returns the latitude row with the given index, computing and caching it if needed
+func (egm *egm) getCachedLatitudeRow(index int) *latitudeRow {
+	egm.rowsLock.RLock()
+	row, ok := egm.rows[index]
+	egm.rowsLock.RUnlock()
+	if ok {
+		return row
+	}
+
+	row = egm.getLatitudeRow(math.Max(-90, math.Min(90, float64(index)*egm.rowSpacing)), 0)
+	egm.rowsLock.Lock()
+	if len(egm.rows)*2*len(row.cosineSums) >= maxCachedSums {
+		egm.rows = make(map[int]*latitudeRow)
+	}
+	egm.rows[index] = row
+	egm.rowsLock.Unlock()
+
+	return row
+}
+
+// computes the terms of the synthesis depending on the latitude, summing with the Clenshaw method over the degrees
+// of each order
+func (egm *egm) getLatitudeRow(lat, height float64) *latitudeRow {
 	s11 := make([]float64, egm.nmax+3)
 	s12 := make([]float64, egm.nmax+3)
 	phi := lat / 180 * math.Pi
@@ -184,22 +305,22 @@ func (egm *egm) heightOffset(lon, lat, height float64) float64 {
 	t := math.Cos(th)
 	f1 := egm.semiMajor / math.Sqrt(x2y2+z1*z1)
 	f2 := f1 * f1
-	rlam := lon / 180 * math.Pi
 	var gravn float64
 	if egm.wgs84 {
 		gravn = egm.grava * (1.0 + egm.star*sin2_phi) / rni
 	} else {
 		gravn = egm.grava*(1.0+egm.star*sin2_phi) + 0.000023461*(sin2_phi*sin2_phi)
 	}
-	sr[0] = 0
-	sr[1] = math.Sin(rlam)
-	cr[0] = 1
-	cr[1] = math.Cos(rlam)
-	for j := 2; j <= egm.nmax; j++ {
-		sr[j] = (2.0 * cr[1] * sr[j-1]) - sr[j-2]
-		cr[j] = (2.0 * cr[1] * cr[j-1]) - cr[j-2]
+
+	row := latitudeRow{
+		cosineSums:  make([]float64, egm.nmax+1),
+		sineSums:    make([]float64, egm.nmax+1),
+		as:          egm.as,
+		orderFactor: y * f1,
+		f1:          f1,
+		f2y:         sqrt03 * y * f2,
+		scale:       egm.rkm / (egm.semiMajor * (gravn - (height * 0.3086e-5))),
 	}
-	var sht, previousSht float64 = 0, 0
 	for i := egm.nmax; i >= 0; i-- {
 		for j := egm.nmax; j >= i; j-- {
 			ll := locatingArray(j) + i
@@ -210,8 +331,26 @@ func (egm *egm) heightOffset(lon, lat, height float64) float64 {
 			s11[j] = (ta * s11[j+1]) - (tb * s11[j+2]) + egm.cnmGeopCoef[ll]
 			s12[j] = (ta * s12[j+1]) - (tb * s12[j+2]) + egm.snmGeopCoef[ll]
 		}
-		previousSht = sht
-		sht = (-egm.as[i] * y * f1 * sht) + (s11[i] * cr[i]) + (s12[i] * sr[i])
+		row.cosineSums[i] = s11[i]
+		row.sineSums[i] = s12[i]
+	}
+
+	return &row
+}
+
+// sums the terms of each order at the given longitude with the Clenshaw method, returning the undulation
+func (row *latitudeRow) evaluate(lon float64) float64 {
+	nmax := len(row.cosineSums) - 1
+	rlam := lon / 180 * math.Pi
+	cosLon := math.Cos(rlam)
+	// cosines and sines of the multiples of the longitude, recurring downwards from the highest order
+	cr, crNext := math.Cos(float64(nmax)*rlam), math.Cos(float64(nmax+1)*rlam)
+	sr, srNext := math.Sin(float64(nmax)*rlam), math.Sin(float64(nmax+1)*rlam)
+	var sht float64
+	for i := nmax; i >= 1; i-- {
+		sht = (-row.as[i] * row.orderFactor * sht) + (row.cosineSums[i] * cr) + (row.sineSums[i] * sr)
+		cr, crNext = (2.0*cosLon*cr)-crNext, cr
+		sr, srNext = (2.0*cosLon*sr)-srNext, sr
 	}
-	return ((s11[0]+s12[0])*f1 + (previousSht * sqrt03 * y * f2)) * egm.rkm / (egm.semiMajor * (gravn - (height * 0.3086e-5)))
+	return ((row.cosineSums[0]+row.sineSums[0])*row.f1 + (sht * row.f2y)) * row.scale
 }
@@ -20,11 +20,26 @@ func NewEllipsoidToGeoidGHOffsetCalculator(coordinateConverter converters.Coordi
 	}
 }
 
+// Inits a calculator evaluating the given spherical harmonic model, egm180 for the one shipped in the assets folder
+// or the path of a file of coefficients such as the EGM96 or EGM2008 ones, truncated at the given degree. A degree of
+// 0 uses the max degree of the model
+func NewEllipsoidToGeoidGHOffsetCalculatorForModel(coordinateConverter converters.CoordinateConverter, model string, degree int) (converters.EllipsoidToGeoidOffsetCalculator, error) {
+	gravitationalModel, err := newEarthGravitationalModel(model, degree, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EllipsoidToGeoidGHOffsetCalculator{
+		gravitationalModel:  gravitationalModel,
+		coordinateConverter: coordinateConverter,
+	}, nil
+}
+
 func (ghc *EllipsoidToGeoidGHOffsetCalculator) GetEllipsoidToGeoidOffset(lat, lon float64, sourceSrid int) (float64, error) {
 	coordinateInEPSG4326, err := ghc.coordinateConverter.ConvertCoordinateSrid(sourceSrid, 4326, geometry.Coordinate{X: lon, Y: lat, Z: math.NaN()})
 	if err != nil {
 		return 0, err
 	}
 
-	return ghc.gravitationalModel.heightOffset(coordinateInEPSG4326.X, coordinateInEPSG4326.Y, 0), err
+	return ghc.gravitationalModel.cachedHeightOffset(coordinateInEPSG4326.X, coordinateInEPSG4326.Y), err
 }
@@ -0,0 +1,117 @@
+package crop
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+)
+
+// number of segments each edge is split into when reprojecting an area, so that edges that are straight in the
+// source srid follow their curved path in the target one
+const reprojectionSegments = 8
+
+// A polygon made of an outer ring followed by its holes. Rings do not need to be explicitly closed
+type Polygon [][]geometry.Coordinate
+
+// Area used to crop the points, made of one or more polygons in the X-Y plane, expressed in the given srid, extruded
+// between a min and a max Z. Z bounds are always expressed in the vertical datum of the input points, before any
+// elevation correction, whatever the srid of the area
+type Area struct {
+	Srid                   int
+	Polygons               []Polygon
+	ZMin, ZMax             float64
+	xMin, xMax, yMin, yMax float64
+}
+
+// Creates an area from the given polygons, extruded between the given min and max Z
+func NewArea(polygons []Polygon, zMin float64, zMax float64, srid int) *Area {
+	area := Area{
+		Srid:     srid,
+		Polygons: polygons,
+		ZMin:     zMin,
+		ZMax:     zMax,
+		xMin:     math.Inf(1),
+		xMax:     math.Inf(-1),
+		yMin:     math.Inf(1),
+		yMax:     math.Inf(-1),
+	}
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for _, vertex := range ring {
+				area.xMin = math.Min(area.xMin, vertex.X)
+				area.xMax = math.Max(area.xMax, vertex.X)
+				area.yMin = math.Min(area.yMin, vertex.Y)
+				area.yMax = math.Max(area.yMax, vertex.Y)
+			}
+		}
+	}
+
+	return &area
+}
+
+// Creates a box shaped area. Use infinite Z bounds for a 2D box
+func NewBoxArea(xMin, yMin, xMax, yMax, zMin, zMax float64, srid int) *Area {
+	ring := []geometry.Coordinate{{X: xMin, Y: yMin}, {X: xMax, Y: yMin}, {X: xMax, Y: yMax}, {X: xMin, Y: yMax}}
+	return NewArea([]Polygon{{ring}}, zMin, zMax, srid)
+}
+
+// Checks if the given point, expressed in the srid of the area, falls within it
+func (a *Area) Contains(x, y, z float64) bool {
+	if z < a.ZMin || z > a.ZMax || x < a.xMin || x > a.xMax || y < a.yMin || y > a.yMax {
+		return false
+	}
+	for _, polygon := range a.Polygons {
+		if polygon.contains(x, y) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the area expressed in the target srid, converting its vertices with the given converter. Z bounds are kept
+// unchanged as they are already expressed in the vertical datum of the input points
+func (a *Area) Reproject(converter converters.CoordinateConverter, targetSrid int) (*Area, error) {
+	if a.Srid == targetSrid {
+		return a, nil
+	}
+
+	polygons := make([]Polygon, 0, len(a.Polygons))
+	for _, polygon := range a.Polygons {
+		reprojected := make(Polygon, 0, len(polygon))
+		for _, ring := range polygon {
+			var vertices []geometry.Coordinate
+			for i, vertex := range ring {
+				next := ring[(i+1)%len(ring)]
+				for j := 0; j < reprojectionSegments; j++ {
+					t := float64(j) / reprojectionSegments
+					coordinate := geometry.Coordinate{X: vertex.X + (next.X-vertex.X)*t, Y: vertex.Y + (next.Y-vertex.Y)*t}
+					converted, err := converter.ConvertCoordinateSrid(a.Srid, targetSrid, coordinate)
+					if err != nil {
+						return nil, err
+					}
+					vertices = append(vertices, converted)
+				}
+			}
+			reprojected = append(reprojected, vertices)
+		}
+		polygons = append(polygons, reprojected)
+	}
+
+	return NewArea(polygons, a.ZMin, a.ZMax, targetSrid), nil
+}
+
+// checks if the point lies inside the polygon with the even-odd rule, so that points within holes are excluded
+func (p Polygon) contains(x, y float64) bool {
+	inside := false
+	for _, ring := range p {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,263 @@
+package crop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Parses a box given as "xMin,yMin,xMax,yMax" or, to also crop along Z, "xMin,yMin,xMax,yMax,zMin,zMax"
+func ParseBox(value string, srid int) (*Area, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 && len(parts) != 6 {
+		return nil, fmt.Errorf("invalid box %q, expected 4 or 6 comma separated values", value)
+	}
+
+	values := []float64{0, 0, 0, 0, math.Inf(-1), math.Inf(1)}
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid box value %q", part)
+		}
+		values[i] = number
+	}
+	if values[0] > values[2] || values[1] > values[3] || values[4] > values[5] {
+		return nil, fmt.Errorf("invalid box %q, min values cannot be greater than max values", value)
+	}
+
+	return NewBoxArea(values[0], values[1], values[2], values[3], values[4], values[5], srid), nil
+}
+
+// Parses a polygon or multipolygon given either as WKT or as GeoJSON geometry, feature or feature collection. The
+// value can also be the path of a file containing them
+func ParsePolygon(value string, srid int) (*Area, error) {
+	if _, err := os.Stat(value); err == nil {
+		content, err := ioutil.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		value = string(content)
+	}
+
+	var polygons []Polygon
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		polygons, err = parseGeoJSON([]byte(value))
+	} else {
+		polygons, err = parseWKT(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(polygons) == 0 {
+		return nil, errors.New("no polygon found")
+	}
+
+	return NewArea(polygons, math.Inf(-1), math.Inf(1), srid), nil
+}
+
+// GeoJSON object, only the members needed to extract the polygons are decoded
+type geoJSONObject struct {
+	Type        string           `json:"type"`
+	Coordinates json.RawMessage  `json:"coordinates"`
+	Geometry    *geoJSONObject   `json:"geometry"`
+	Geometries  []*geoJSONObject `json:"geometries"`
+	Features    []*geoJSONObject `json:"features"`
+}
+
+func parseGeoJSON(content []byte) ([]Polygon, error) {
+	var object geoJSONObject
+	if err := json.Unmarshal(content, &object); err != nil {
+		return nil, err
+	}
+
+	return object.getPolygons()
+}
+
+// returns all the polygons contained in the GeoJSON object
+func (o *geoJSONObject) getPolygons() ([]Polygon, error) {
+	switch o.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(o.Coordinates, &rings); err != nil {
+			return nil, err
+		}
+		polygon, err := toPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+	case "MultiPolygon":
+		var polygonsRings [][][][]float64
+		if err := json.Unmarshal(o.Coordinates, &polygonsRings); err != nil {
+			return nil, err
+		}
+		var polygons []Polygon
+		for _, rings := range polygonsRings {
+			polygon, err := toPolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, nil
+	case "Feature":
+		if o.Geometry == nil {
+			return nil, nil
+		}
+		return o.Geometry.getPolygons()
+	case "FeatureCollection", "GeometryCollection":
+		var polygons []Polygon
+		for _, child := range append(o.Features, o.Geometries...) {
+			childPolygons, err := child.getPolygons()
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, childPolygons...)
+		}
+		return polygons, nil
+	}
+
+	return nil, fmt.Errorf("unsupported GeoJSON type %q", o.Type)
+}
+
+// converts the GeoJSON rings of a polygon, ignoring any Z coordinate
+func toPolygon(rings [][][]float64) (Polygon, error) {
+	var polygon Polygon
+	for _, positions := range rings {
+		var ring []geometry.Coordinate
+		for _, position := range positions {
+			if len(position) < 2 {
+				return nil, errors.New("invalid GeoJSON position")
+			}
+			ring = append(ring, geometry.Coordinate{X: position[0], Y: position[1]})
+		}
+		polygon = append(polygon, openRing(ring))
+	}
+
+	return polygon, nil
+}
+
+// parses a WKT POLYGON or MULTIPOLYGON, ignoring any Z or M coordinate
+func parseWKT(value string) ([]Polygon, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	var body string
+	var multi bool
+	switch {
+	case strings.HasPrefix(value, "MULTIPOLYGON"):
+		body, multi = strings.TrimPrefix(value, "MULTIPOLYGON"), true
+	case strings.HasPrefix(value, "POLYGON"):
+		body = strings.TrimPrefix(value, "POLYGON")
+	default:
+		return nil, fmt.Errorf("unsupported WKT geometry, expected POLYGON or MULTIPOLYGON")
+	}
+	// drops the dimension qualifiers, e.g. POLYGON Z
+	body = strings.TrimLeft(body, " ZM")
+
+	nested, rest, err := parseWKTList(body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, fmt.Errorf("unexpected WKT content %q", rest)
+	}
+
+	polygonsRings := []wktList{nested}
+	if multi {
+		polygonsRings = nested.children
+	}
+
+	var polygons []Polygon
+	for _, rings := range polygonsRings {
+		var polygon Polygon
+		for _, ring := range rings.children {
+			vertices, err := ring.toRing()
+			if err != nil {
+				return nil, err
+			}
+			polygon = append(polygon, vertices)
+		}
+		polygons = append(polygons, polygon)
+	}
+
+	return polygons, nil
+}
+
+// parenthesized WKT list, containing either other lists or the text of a coordinate sequence
+type wktList struct {
+	children []wktList
+	text     string
+}
+
+// parses the parenthesized list at the beginning of the value returning the text that follows it
+func parseWKTList(value string) (wktList, string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "(") {
+		return wktList{}, "", errors.New("invalid WKT, expected (")
+	}
+	value = strings.TrimSpace(value[1:])
+
+	var list wktList
+	if !strings.HasPrefix(value, "(") {
+		end := strings.Index(value, ")")
+		if end < 0 {
+			return wktList{}, "", errors.New("invalid WKT, expected )")
+		}
+		list.text = value[:end]
+		return list, value[end+1:], nil
+	}
+
+	for {
+		child, rest, err := parseWKTList(value)
+		if err != nil {
+			return wktList{}, "", err
+		}
+		list.children = append(list.children, child)
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, ",") {
+			value = rest[1:]
+			continue
+		}
+		if strings.HasPrefix(rest, ")") {
+			return list, rest[1:], nil
+		}
+		return wktList{}, "", errors.New("invalid WKT, expected , or )")
+	}
+}
+
+// converts the coordinate sequence of the list to a ring
+func (l wktList) toRing() ([]geometry.Coordinate, error) {
+	if l.text == "" {
+		return nil, errors.New("invalid WKT ring")
+	}
+
+	var ring []geometry.Coordinate
+	for _, position := range strings.Split(l.text, ",") {
+		values := strings.Fields(position)
+		if len(values) < 2 {
+			return nil, fmt.Errorf("invalid WKT position %q", position)
+		}
+		x, errX := strconv.ParseFloat(values[0], 64)
+		y, errY := strconv.ParseFloat(values[1], 64)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid WKT position %q", position)
+		}
+		ring = append(ring, geometry.Coordinate{X: x, Y: y})
+	}
+
+	return openRing(ring), nil
+}
+
+// removes the last vertex of the ring if it repeats the first one
+func openRing(ring []geometry.Coordinate) []geometry.Coordinate {
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		return ring[:len(ring)-1]
+	}
+	return ring
+}
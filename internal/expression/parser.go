@@ -0,0 +1,236 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// compiled expression, evaluated against the values of a point and the attributes computed so far
+type evaluator func(values *Values, attributes []float32) float64
+
+// binding power of the binary operators, higher binds tighter
+var precedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// functions callable in the expressions with their number of arguments
+var functions = map[string]struct {
+	arity    int
+	function func(args []float64) float64
+}{
+	"abs":   {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"floor": {1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"ceil":  {1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"round": {1, func(args []float64) float64 { return math.Round(args[0]) }},
+	"sqrt":  {1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"log":   {1, func(args []float64) float64 { return math.Log(args[0]) }},
+	"exp":   {1, func(args []float64) float64 { return math.Exp(args[0]) }},
+	"pow":   {2, func(args []float64) float64 { return math.Pow(args[0], args[1]) }},
+	"min":   {2, func(args []float64) float64 { return math.Min(args[0], args[1]) }},
+	"max":   {2, func(args []float64) float64 { return math.Max(args[0], args[1]) }},
+	"clamp": {3, func(args []float64) float64 { return math.Max(args[1], math.Min(args[0], args[2])) }},
+}
+
+// Pratt parser compiling the tokens of an expression in an evaluator
+type parser struct {
+	tokens    []token
+	current   int
+	variables map[string]evaluator
+}
+
+// compiles the given expression resolving its identifiers with the given variables, keyed by lowercase name
+func compile(source string, variables map[string]evaluator) (evaluator, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens, variables: variables}
+	expression, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != endToken {
+		return nil, fmt.Errorf("unexpected %q at position %d", next.text, next.position)
+	}
+
+	return expression, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.current]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.current]
+	if t.kind != endToken {
+		p.current++
+	}
+	return t
+}
+
+func (p *parser) expect(operator string) error {
+	if t := p.next(); t.kind != operatorToken || t.text != operator {
+		return fmt.Errorf("expected %q at position %d", operator, t.position)
+	}
+	return nil
+}
+
+// parses an expression whose binary operators bind tighter than the given precedence
+func (p *parser) parseExpression(precedence int) (evaluator, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		operatorPrecedence, ok := precedences[t.text]
+		if t.kind != operatorToken || !ok || operatorPrecedence <= precedence {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpression(operatorPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		left = binary(t.text, left, right)
+	}
+}
+
+func (p *parser) parseUnary() (evaluator, error) {
+	t := p.peek()
+	if t.kind == operatorToken && (t.text == "-" || t.text == "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "-" {
+			return func(v *Values, a []float32) float64 { return -operand(v, a) }, nil
+		}
+		return func(v *Values, a []float32) float64 { return toNumber(operand(v, a) == 0) }, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (evaluator, error) {
+	t := p.next()
+	switch t.kind {
+	case numberToken:
+		number := t.number
+		return func(v *Values, a []float32) float64 { return number }, nil
+	case identifierToken:
+		if p.peek().kind == operatorToken && p.peek().text == "(" {
+			return p.parseCall(t)
+		}
+		name := strings.ToLower(t.text)
+		switch name {
+		case "true":
+			return func(v *Values, a []float32) float64 { return 1 }, nil
+		case "false":
+			return func(v *Values, a []float32) float64 { return 0 }, nil
+		}
+		variable, ok := p.variables[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q at position %d", t.text, t.position)
+		}
+		return variable, nil
+	case operatorToken:
+		if t.text == "(" {
+			expression, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			return expression, p.expect(")")
+		}
+	}
+
+	if t.kind == endToken {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.position)
+}
+
+func (p *parser) parseCall(name token) (evaluator, error) {
+	function, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.position)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []evaluator
+	for !(p.peek().kind == operatorToken && p.peek().text == ")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != function.arity {
+		return nil, fmt.Errorf("function %q expects %d arguments, got %d", name.text, function.arity, len(args))
+	}
+
+	return func(v *Values, a []float32) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(v, a)
+		}
+		return function.function(values)
+	}, nil
+}
+
+// combines the operands with the given binary operator. Logical operators short-circuit
+func binary(operator string, left evaluator, right evaluator) evaluator {
+	switch operator {
+	case "||":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) != 0 || right(v, a) != 0) }
+	case "&&":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) != 0 && right(v, a) != 0) }
+	case "==":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) == right(v, a)) }
+	case "!=":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) != right(v, a)) }
+	case "<":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) < right(v, a)) }
+	case "<=":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) <= right(v, a)) }
+	case ">":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) > right(v, a)) }
+	case ">=":
+		return func(v *Values, a []float32) float64 { return toNumber(left(v, a) >= right(v, a)) }
+	case "+":
+		return func(v *Values, a []float32) float64 { return left(v, a) + right(v, a) }
+	case "-":
+		return func(v *Values, a []float32) float64 { return left(v, a) - right(v, a) }
+	case "*":
+		return func(v *Values, a []float32) float64 { return left(v, a) * right(v, a) }
+	case "/":
+		return func(v *Values, a []float32) float64 { return left(v, a) / right(v, a) }
+	}
+	return func(v *Values, a []float32) float64 { return math.Mod(left(v, a), right(v, a)) }
+}
+
+// booleans are represented as 1 and 0
+func toNumber(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
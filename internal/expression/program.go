@@ -0,0 +1,130 @@
+package expression
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Values of a point that expressions can refer to. Coordinates are expressed in the srid of the input points and the
+// scan angle in degrees. GpsTime is zero for the point formats not storing it
+type Values struct {
+	X, Y, Z         float64
+	R, G, B         uint8
+	Intensity       uint8
+	Classification  uint8
+	ReturnNumber    uint8
+	NumberOfReturns uint8
+	GpsTime         float64
+	ScanAngle       float64
+	PointSourceID   uint16
+	UserData        uint8
+}
+
+// variables available to all the expressions, keyed by lowercase name
+var pointVariables = map[string]evaluator{
+	"x":               func(v *Values, a []float32) float64 { return v.X },
+	"y":               func(v *Values, a []float32) float64 { return v.Y },
+	"z":               func(v *Values, a []float32) float64 { return v.Z },
+	"r":               func(v *Values, a []float32) float64 { return float64(v.R) },
+	"g":               func(v *Values, a []float32) float64 { return float64(v.G) },
+	"b":               func(v *Values, a []float32) float64 { return float64(v.B) },
+	"intensity":       func(v *Values, a []float32) float64 { return float64(v.Intensity) },
+	"classification":  func(v *Values, a []float32) float64 { return float64(v.Classification) },
+	"returnnumber":    func(v *Values, a []float32) float64 { return float64(v.ReturnNumber) },
+	"numberofreturns": func(v *Values, a []float32) float64 { return float64(v.NumberOfReturns) },
+	"gpstime":         func(v *Values, a []float32) float64 { return v.GpsTime },
+	"scanangle":       func(v *Values, a []float32) float64 { return v.ScanAngle },
+	"pointsourceid":   func(v *Values, a []float32) float64 { return float64(v.PointSourceID) },
+	"userdata":        func(v *Values, a []float32) float64 { return float64(v.UserData) },
+}
+
+// matches the statements that define an attribute, e.g. "HeightBand = floor(Z / 10)"
+var attributeStatement = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=([^=].*)$`)
+
+// A set of filters and computed attributes. Points are kept only if all filters evaluate to a non zero value. Attributes
+// are computed in the order they are defined and each of them can refer to the ones defined before
+type Program struct {
+	filters        []evaluator
+	attributes     []evaluator
+	attributeNames []string
+}
+
+// Parses a program made of statements separated by semicolons or new lines. Statements in the form "Name = expression"
+// define an attribute, all the others are filters. Text following a # is a comment
+func Parse(source string) (*Program, error) {
+	program := Program{}
+	variables := make(map[string]evaluator, len(pointVariables))
+	for name, variable := range pointVariables {
+		variables[name] = variable
+	}
+
+	for _, line := range strings.Split(source, "\n") {
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+		for _, statement := range strings.Split(line, ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			if err := program.addStatement(statement, variables); err != nil {
+				return nil, fmt.Errorf("%s: %v", strings.TrimSpace(statement), err)
+			}
+		}
+	}
+
+	return &program, nil
+}
+
+func (p *Program) addStatement(statement string, variables map[string]evaluator) error {
+	match := attributeStatement.FindStringSubmatch(statement)
+	if match == nil {
+		filter, err := compile(statement, variables)
+		if err != nil {
+			return err
+		}
+		p.filters = append(p.filters, filter)
+		return nil
+	}
+
+	name := match[1]
+	if _, exists := variables[strings.ToLower(name)]; exists {
+		return fmt.Errorf("attribute %q is already defined", name)
+	}
+	attribute, err := compile(match[2], variables)
+	if err != nil {
+		return err
+	}
+
+	index := len(p.attributes)
+	p.attributes = append(p.attributes, attribute)
+	p.attributeNames = append(p.attributeNames, name)
+	variables[strings.ToLower(name)] = func(v *Values, a []float32) float64 { return float64(a[index]) }
+
+	return nil
+}
+
+// Returns the names of the attributes computed by the program, in the order they are defined
+func (p *Program) GetAttributeNames() []string {
+	return p.attributeNames
+}
+
+// Computes the attributes of the point with the given values and checks if it passes all the filters. Returns nil
+// attributes if the program defines none
+func (p *Program) Evaluate(values *Values) ([]float32, bool) {
+	var attributes []float32
+	if len(p.attributes) > 0 {
+		attributes = make([]float32, len(p.attributes))
+		for i, attribute := range p.attributes {
+			attributes[i] = float32(attribute(values, attributes))
+		}
+	}
+
+	for _, filter := range p.filters {
+		if filter(values, attributes) == 0 {
+			return nil, false
+		}
+	}
+
+	return attributes, true
+}
@@ -0,0 +1,109 @@
+package geoid
+
+import (
+	"errors"
+	"math"
+)
+
+// Regular grid of geoid undulations, i.e. of the heights of the geoid above the WGS84 ellipsoid, over geographic
+// coordinates. Nodes are stored row by row starting from the south west one
+type Grid struct {
+	west        float64
+	south       float64
+	dLon        float64
+	dLat        float64
+	columns     int
+	rows        int
+	period      int // number of columns spanning 360 degrees if the grid wraps around the globe, 0 otherwise
+	undulations []float32
+}
+
+// Builds a geoid grid from the longitude and latitude in degrees of its south west node, the spacing of the nodes in
+// degrees and the undulations in meters of its nodes, row by row starting from the south west one. Nodes without
+// data must be NaN
+func NewGrid(west, south, dLon, dLat float64, columns, rows int, undulations []float32) (*Grid, error) {
+	if columns <= 0 || rows <= 0 || len(undulations) != columns*rows {
+		return nil, errors.New("invalid geoid grid size")
+	}
+	if !(dLon > 0) || !(dLat > 0) {
+		return nil, errors.New("invalid geoid grid spacing")
+	}
+
+	grid := &Grid{
+		west:        west,
+		south:       south,
+		dLon:        dLon,
+		dLat:        dLat,
+		columns:     columns,
+		rows:        rows,
+		undulations: undulations,
+	}
+	if period := math.Round(360 / dLon); math.Abs(period*dLon-360) < 1e-6 && columns >= int(period) {
+		grid.period = int(period)
+	}
+
+	return grid, nil
+}
+
+// Returns the undulation in meters at the given longitude and latitude in degrees, interpolated bilinearly between the
+// four closest nodes. Nodes without data are skipped. Returns false if the location is outside the grid or none of
+// the closest nodes has data
+func (g *Grid) GetUndulation(lon, lat float64) (float64, bool) {
+	y := (lat - g.south) / g.dLat
+	if !(y > -1e-9 && y < float64(g.rows-1)+1e-9) {
+		return 0, false
+	}
+
+	x := (math.Mod(lon-g.west, 360) + 360) / g.dLon
+	if g.period == 0 {
+		// the longitude is brought in the range of the grid
+		x = math.Mod(x, 360/g.dLon)
+		if x > 360/g.dLon-1e-9 {
+			// just west of the first column
+			x -= 360 / g.dLon
+		}
+		if x > float64(g.columns-1)+1e-9 {
+			return 0, false
+		}
+	}
+
+	x0, y0 := math.Floor(x), math.Floor(y)
+	tx, ty := x-x0, y-y0
+	var sum, weights float64
+	for _, node := range [4]struct {
+		column, row int
+		weight      float64
+	}{
+		{int(x0), int(y0), (1 - tx) * (1 - ty)},
+		{int(x0) + 1, int(y0), tx * (1 - ty)},
+		{int(x0), int(y0) + 1, (1 - tx) * ty},
+		{int(x0) + 1, int(y0) + 1, tx * ty},
+	} {
+		if node.weight == 0 {
+			continue
+		}
+		undulation, ok := g.getNode(node.column, node.row)
+		if ok {
+			sum += undulation * node.weight
+			weights += node.weight
+		}
+	}
+	if weights == 0 {
+		return 0, false
+	}
+
+	return sum / weights, true
+}
+
+// returns the undulation of the given node, false if it is outside the grid or has no data
+func (g *Grid) getNode(column, row int) (float64, bool) {
+	if g.period > 0 {
+		column %= g.period
+	}
+	if column < 0 || column >= g.columns || row < 0 || row >= g.rows {
+		return 0, false
+	}
+	undulation := float64(g.undulations[row*g.columns+column])
+
+	return undulation, !math.IsNaN(undulation)
+}
@@ -0,0 +1,169 @@
+package geoid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// undulation marking the GTX nodes without data
+const gtxNoData = -88.8888
+
+// BYN values marking the nodes without data, for 2 and 4 bytes values, the latter to be multiplied by the factor
+const (
+	bynNoData16 = 32767
+	bynNoData32 = 9999
+)
+
+// Reads a geoid grid in the GTX format, the NOAA VDatum one used by PROJ, in the BYN format of the Canadian Geodetic
+// Survey or as a GeoTIFF, e.g. the EGM96 and EGM2008 grids distributed by PROJ, choosing the format by the extension
+// of the file
+func ReadGrid(path string) (*Grid, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gtx":
+		return readGtx(path)
+	case ".byn":
+		return readByn(path)
+	case ".tif", ".tiff":
+		return readGeoTiff(path)
+	}
+
+	return nil, fmt.Errorf("unsupported geoid grid format %q, expected a .gtx, .byn, .tif or .tiff file", filepath.Ext(path))
+}
+
+// reads a GTX grid: a big endian header storing the latitude and longitude of the south west node, the latitude and
+// longitude spacings and the number of rows and columns, followed by the undulations as 4 bytes floats, row by row
+// from the south west node
+func readGtx(path string) (*Grid, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 40 {
+		return nil, errors.New("invalid GTX header")
+	}
+
+	south := math.Float64frombits(binary.BigEndian.Uint64(content[0:]))
+	west := math.Float64frombits(binary.BigEndian.Uint64(content[8:]))
+	dLat := math.Float64frombits(binary.BigEndian.Uint64(content[16:]))
+	dLon := math.Float64frombits(binary.BigEndian.Uint64(content[24:]))
+	rows := int(int32(binary.BigEndian.Uint32(content[32:])))
+	columns := int(int32(binary.BigEndian.Uint32(content[36:])))
+	if rows <= 0 || columns <= 0 || len(content) < 40+rows*columns*4 {
+		return nil, errors.New("invalid or truncated GTX grid")
+	}
+
+	undulations := make([]float32, rows*columns)
+	for i := range undulations {
+		undulations[i] = math.Float32frombits(binary.BigEndian.Uint32(content[40+i*4:]))
+		if math.Abs(float64(undulations[i])-gtxNoData) < 1e-4 {
+			undulations[i] = float32(math.NaN())
+		}
+	}
+
+	return NewGrid(west, south, dLon, dLat, columns, rows, undulations)
+}
+
+// reads a BYN grid: an 80 bytes header storing the bounds and the spacings of the nodes in arc seconds, the
+// factor dividing the values to get meters, their size and the byte order, followed by the values as 2 or 4 bytes
+// integers, row by row from the north west node
+func readByn(path string) (*Grid, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) < 80 {
+		return nil, errors.New("invalid BYN header")
+	}
+
+	var byteOrder binary.ByteOrder = binary.BigEndian
+	if binary.LittleEndian.Uint16(content[44:]) == 1 {
+		byteOrder = binary.LittleEndian
+	}
+	bounds := make([]float64, 4)
+	for i := range bounds {
+		bounds[i] = float64(int32(byteOrder.Uint32(content[i*4:])))
+	}
+	if int16(byteOrder.Uint16(content[46:])) == 1 {
+		// bounds are stored in thousandths of arc seconds
+		for i := range bounds {
+			bounds[i] /= 1000
+		}
+	}
+	south, north, west, east := bounds[0], bounds[1], bounds[2], bounds[3]
+	dLat := float64(int16(byteOrder.Uint16(content[16:])))
+	dLon := float64(int16(byteOrder.Uint16(content[18:])))
+	factor := math.Float64frombits(byteOrder.Uint64(content[24:]))
+	size := int(int16(byteOrder.Uint16(content[32:])))
+	if dLat <= 0 || dLon <= 0 || factor == 0 || (size != 2 && size != 4) {
+		return nil, errors.New("invalid BYN header")
+	}
+
+	rows := int(math.Round((north-south)/dLat)) + 1
+	columns := int(math.Round((east-west)/dLon)) + 1
+	if rows <= 0 || columns <= 0 || len(content) < 80+rows*columns*size {
+		return nil, errors.New("invalid or truncated BYN grid")
+	}
+
+	undulations := make([]float32, rows*columns)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			offset := 80 + (row*columns+column)*size
+			var value float64
+			var noData bool
+			if size == 2 {
+				value = float64(int16(byteOrder.Uint16(content[offset:])))
+				noData = value == bynNoData16
+			} else {
+				value = float64(int32(byteOrder.Uint32(content[offset:])))
+				noData = value == bynNoData32*factor
+			}
+			undulation := float32(value / factor)
+			if noData {
+				undulation = float32(math.NaN())
+			}
+			// rows are stored from the north
+			undulations[(rows-1-row)*columns+column] = undulation
+		}
+	}
+
+	return NewGrid(west/3600, south/3600, dLon/3600, dLat/3600, columns, rows, undulations)
+}
+
+// reads a GeoTIFF grid in geographic coordinates, storing the undulations in its first band
+func readGeoTiff(path string) (*Grid, error) {
+	grid, err := raster.OpenGrid(path)
+	if err != nil {
+		return nil, err
+	}
+	if grid.Srid != 0 && !grid.Geographic {
+		return nil, fmt.Errorf("the geoid grid must be in geographic coordinates, e.g. EPSG:4326 or EPSG:4979, got EPSG:%d", grid.Srid)
+	}
+	t := grid.Transform
+	if t[2] != 0 || t[4] != 0 || t[1] <= 0 || t[5] == 0 {
+		return nil, errors.New("unsupported rotated or flipped geoid grid")
+	}
+
+	// nodes are the centers of the pixels
+	west := t[0] + t[1]/2
+	north := t[3] + t[5]/2
+	undulations := make([]float32, grid.Width*grid.Height)
+	for row := 0; row < grid.Height; row++ {
+		target := row
+		if t[5] < 0 {
+			// rows are stored from the north
+			target = grid.Height - 1 - row
+		}
+		for column := 0; column < grid.Width; column++ {
+			undulations[target*grid.Width+column] = float32(grid.Values[row*grid.Width+column])
+		}
+	}
+	south := math.Min(north, north+t[5]*float64(grid.Height-1))
+
+	return NewGrid(west, south, t[1], math.Abs(t[5]), grid.Width, grid.Height, undulations)
+}
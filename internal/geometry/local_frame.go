@@ -0,0 +1,168 @@
+package geometry
+
+import "math"
+
+// WGS84 ellipsoid parameters
+const wgs84SemiMajorAxis = 6378137.0
+const wgs84Flattening = 1 / 298.257223563
+const wgs84EccentricitySquared = wgs84Flattening * (2 - wgs84Flattening)
+
+// Local East-North-Up cartesian frame tangent to the WGS84 ellipsoid at a given origin, optionally rotated around the
+// vertical axis. Coordinates are expressed in meters and, as the frame is a rigid rotation and translation of the
+// geocentric frame, distances are preserved everywhere on Earth.
+type LocalFrame struct {
+	origin                         Coordinate // origin of the frame in geocentric (EPSG:4978) coordinates
+	sinLon, cosLon, sinLat, cosLat float64
+	sinHeading, cosHeading         float64
+}
+
+// Instantiates a new LocalFrame with origin in the given geodetic coordinates, expressed as longitude and latitude in
+// degrees and ellipsoidal height in meters
+func NewLocalFrame(lon, lat, height float64) *LocalFrame {
+	return NewLocalFrameWithHeading(lon, lat, height, 0)
+}
+
+// Instantiates a new LocalFrame with origin in the given geodetic coordinates whose Y axis points to the given heading,
+// expressed in degrees clockwise from north. The X axis points 90 degrees clockwise from the Y axis and the Z axis up
+func NewLocalFrameWithHeading(lon, lat, height, heading float64) *LocalFrame {
+	lonRad := lon * toRadians
+	latRad := lat * toRadians
+	headingRad := heading * toRadians
+	return &LocalFrame{
+		origin:     GeodeticToGeocentric(lon, lat, height),
+		sinLon:     math.Sin(lonRad),
+		cosLon:     math.Cos(lonRad),
+		sinLat:     math.Sin(latRad),
+		cosLat:     math.Cos(latRad),
+		sinHeading: math.Sin(headingRad),
+		cosHeading: math.Cos(headingRad),
+	}
+}
+
+// Converts the given geodetic coordinates, expressed as longitude and latitude in degrees and ellipsoidal height in
+// meters, to the local frame
+func (f *LocalFrame) FromGeodetic(lon, lat, height float64) Coordinate {
+	return f.FromGeocentric(GeodeticToGeocentric(lon, lat, height))
+}
+
+// Converts the given geocentric (EPSG:4978) coordinate to the local frame
+func (f *LocalFrame) FromGeocentric(coordinate Coordinate) Coordinate {
+	dx := coordinate.X - f.origin.X
+	dy := coordinate.Y - f.origin.Y
+	dz := coordinate.Z - f.origin.Z
+	east := -f.sinLon*dx + f.cosLon*dy
+	north := -f.sinLat*f.cosLon*dx - f.sinLat*f.sinLon*dy + f.cosLat*dz
+	return Coordinate{
+		X: f.cosHeading*east - f.sinHeading*north,
+		Y: f.sinHeading*east + f.cosHeading*north,
+		Z: f.cosLat*f.cosLon*dx + f.cosLat*f.sinLon*dy + f.sinLat*dz,
+	}
+}
+
+// Converts the given local frame coordinate to geocentric (EPSG:4978) coordinates
+func (f *LocalFrame) ToGeocentric(coordinate Coordinate) Coordinate {
+	east := f.cosHeading*coordinate.X + f.sinHeading*coordinate.Y
+	north := -f.sinHeading*coordinate.X + f.cosHeading*coordinate.Y
+	return Coordinate{
+		X: f.origin.X - f.sinLon*east - f.sinLat*f.cosLon*north + f.cosLat*f.cosLon*coordinate.Z,
+		Y: f.origin.Y + f.cosLon*east - f.sinLat*f.sinLon*north + f.cosLat*f.sinLon*coordinate.Z,
+		Z: f.origin.Z + f.cosLat*north + f.sinLat*coordinate.Z,
+	}
+}
+
+// Returns the 4x4 matrix, in column major order, converting local frame coordinates to geocentric (EPSG:4978) ones, as
+// expected by the transform property of the 3D Tiles tiles
+func (f *LocalFrame) GetTransform() [16]float64 {
+	east := [3]float64{-f.sinLon, f.cosLon, 0}
+	north := [3]float64{-f.sinLat * f.cosLon, -f.sinLat * f.sinLon, f.cosLat}
+	up := [3]float64{f.cosLat * f.cosLon, f.cosLat * f.sinLon, f.sinLat}
+
+	var transform [16]float64
+	for i := 0; i < 3; i++ {
+		transform[i] = f.cosHeading*east[i] - f.sinHeading*north[i]
+		transform[4+i] = f.sinHeading*east[i] + f.cosHeading*north[i]
+		transform[8+i] = up[i]
+	}
+	transform[12], transform[13], transform[14], transform[15] = f.origin.X, f.origin.Y, f.origin.Z, 1
+
+	return transform
+}
+
+// Converts the given local frame coordinate to geodetic coordinates, expressed as longitude and latitude in degrees and
+// ellipsoidal height in meters
+func (f *LocalFrame) ToGeodetic(coordinate Coordinate) Coordinate {
+	return GeocentricToGeodetic(f.ToGeocentric(coordinate))
+}
+
+// Returns the WGS84 region, in radians, enclosing the given box expressed in the local frame. The region is returned
+// as a BoundingBox whose fields, in order, contain west, south, east, north, min height and max height
+func (f *LocalFrame) BoundingBoxToWGS84Region(bbox *BoundingBox) *BoundingBox {
+	west, south, minHeight := math.MaxFloat64, math.MaxFloat64, math.MaxFloat64
+	east, north, maxHeight := -math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
+
+	// the corners of the box and the centers of its top and bottom faces, as the ellipsoid curvature makes the
+	// latter higher than the corners
+	samples := []Coordinate{
+		{X: bbox.Xmin, Y: bbox.Ymin, Z: bbox.Zmin},
+		{X: bbox.Xmax, Y: bbox.Ymin, Z: bbox.Zmin},
+		{X: bbox.Xmin, Y: bbox.Ymax, Z: bbox.Zmin},
+		{X: bbox.Xmax, Y: bbox.Ymax, Z: bbox.Zmin},
+		{X: bbox.Xmin, Y: bbox.Ymin, Z: bbox.Zmax},
+		{X: bbox.Xmax, Y: bbox.Ymin, Z: bbox.Zmax},
+		{X: bbox.Xmin, Y: bbox.Ymax, Z: bbox.Zmax},
+		{X: bbox.Xmax, Y: bbox.Ymax, Z: bbox.Zmax},
+		{X: bbox.Xmid, Y: bbox.Ymid, Z: bbox.Zmin},
+		{X: bbox.Xmid, Y: bbox.Ymid, Z: bbox.Zmax},
+	}
+
+	for _, sample := range samples {
+		geodetic := f.ToGeodetic(sample)
+		west = math.Min(west, geodetic.X)
+		east = math.Max(east, geodetic.X)
+		south = math.Min(south, geodetic.Y)
+		north = math.Max(north, geodetic.Y)
+		minHeight = math.Min(minHeight, geodetic.Z)
+		maxHeight = math.Max(maxHeight, geodetic.Z)
+	}
+
+	return NewBoundingBox(west*toRadians, south*toRadians, east*toRadians, north*toRadians, minHeight, maxHeight)
+}
+
+// Converts geodetic coordinates, expressed as longitude and latitude in degrees and ellipsoidal height in meters, to
+// geocentric (EPSG:4978) coordinates
+func GeodeticToGeocentric(lon, lat, height float64) Coordinate {
+	lonRad := lon * toRadians
+	latRad := lat * toRadians
+	sinLat := math.Sin(latRad)
+	cosLat := math.Cos(latRad)
+	n := wgs84SemiMajorAxis / math.Sqrt(1-wgs84EccentricitySquared*sinLat*sinLat)
+	return Coordinate{
+		X: (n + height) * cosLat * math.Cos(lonRad),
+		Y: (n + height) * cosLat * math.Sin(lonRad),
+		Z: (n*(1-wgs84EccentricitySquared) + height) * sinLat,
+	}
+}
+
+// Converts geocentric (EPSG:4978) coordinates to geodetic coordinates, expressed as longitude and latitude in degrees
+// and ellipsoidal height in meters. Uses an iterative solution that is stable also near the poles
+func GeocentricToGeodetic(coordinate Coordinate) Coordinate {
+	p := math.Sqrt(coordinate.X*coordinate.X + coordinate.Y*coordinate.Y)
+	lon := math.Atan2(coordinate.Y, coordinate.X)
+	lat := math.Atan2(coordinate.Z, p*(1-wgs84EccentricitySquared))
+
+	var sinLat, n float64
+	for i := 0; i < 5; i++ {
+		sinLat = math.Sin(lat)
+		n = wgs84SemiMajorAxis / math.Sqrt(1-wgs84EccentricitySquared*sinLat*sinLat)
+		lat = math.Atan2(coordinate.Z+wgs84EccentricitySquared*n*sinLat, p)
+	}
+
+	sinLat = math.Sin(lat)
+	height := p*math.Cos(lat) + coordinate.Z*sinLat - wgs84SemiMajorAxis*math.Sqrt(1-wgs84EccentricitySquared*sinLat*sinLat)
+
+	return Coordinate{
+		X: lon * toDeg,
+		Y: lat * toDeg,
+		Z: height,
+	}
+}
@@ -0,0 +1,74 @@
+package io
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
+	"math"
+	"path"
+)
+
+// Generates a tileset child referencing the tileset.json stored in the given subfolder, generated from the tree having
+// the given root node
+func NewExternalTilesetChild(root octree.INode, subfolder string, coordinateConverter converters.CoordinateConverter, refineMode tiler.RefineMode) (*Child, error) {
+	reg, err := root.GetBoundingBoxRegion(coordinateConverter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Child{
+		Content:        Content{Url: subfolder + "/tileset.json"},
+		BoundingVolume: BoundingVolume{Region: reg.GetAsArray()},
+		GeometricError: root.ComputeGeometricError(),
+		Refine:         GetNodeRefineMode(root, refineMode).String(),
+	}, nil
+}
+
+// Writes in the given folder a tileset.json file without content whose children are the given external tilesets. The
+// bounding volume of the root is the union of the ones of the children.
+func WriteCompositeTilesetJson(folder string, children []Child, refineMode tiler.RefineMode) error {
+	if len(children) == 0 {
+		return errors.New("cannot write a composite tileset without children")
+	}
+
+	region := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+	geometricError := 0.0
+	for _, child := range children {
+		// regions are expressed as [west, south, east, north, min height, max height]
+		childRegion := child.BoundingVolume.Region
+		region[0] = math.Min(region[0], childRegion[0])
+		region[1] = math.Min(region[1], childRegion[1])
+		region[2] = math.Max(region[2], childRegion[2])
+		region[3] = math.Max(region[3], childRegion[3])
+		region[4] = math.Min(region[4], childRegion[4])
+		region[5] = math.Max(region[5], childRegion[5])
+		geometricError = math.Max(geometricError, child.GeometricError)
+	}
+
+	tileset := Tileset{
+		Asset:          Asset{Version: "1.0"},
+		GeometricError: geometricError,
+		Root: Root{
+			Children:       children,
+			BoundingVolume: BoundingVolume{Region: region},
+			GeometricError: geometricError,
+			Refine:         refineMode.String(),
+		},
+	}
+
+	jsonData, err := json.MarshalIndent(tileset, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = tools.CreateDirectoryIfDoesNotExist(folder)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(folder, "tileset.json"), jsonData, 0666)
+}
@@ -35,6 +35,8 @@ type intermediateData struct {
 	colors          []uint8
 	intensities     []uint8
 	classifications []uint8
+	attributes      []float64 // computed attributes, grouped by attribute
+	attributeNames  []string
 	numPoints       int
 }
 
@@ -93,7 +95,7 @@ func (c *StandardConsumer) writeBinaryPntsFile(workUnit WorkUnit) error {
 		return err
 	}
 
-	intermediatePointData, err := c.generateIntermediateDataForPnts(node)
+	intermediatePointData, err := c.generateIntermediateDataForPnts(node, getAttributeNames(workUnit.Opts), getPlacement(workUnit.Opts))
 	if err != nil {
 		return err
 	}
@@ -111,7 +113,7 @@ func (c *StandardConsumer) writeBinaryPntsFile(workUnit WorkUnit) error {
 	featureTableBytes, featureTableLen := c.generateFeatureTable(averageXYZ[0], averageXYZ[1], averageXYZ[2], intermediatePointData.numPoints)
 
 	// Batch table
-	batchTableBytes, batchTableLen := c.generateBatchTable(intermediatePointData.numPoints)
+	batchTableBytes, batchTableLen := c.generateBatchTable(intermediatePointData.numPoints, intermediatePointData.attributeNames)
 
 	// Appending binary content to slice
 	outputByte := c.generatePntsByteArray(intermediatePointData, positionBytes, featureTableBytes, featureTableLen, batchTableBytes, batchTableLen)
@@ -127,10 +129,28 @@ func (c *StandardConsumer) writeBinaryPntsFile(workUnit WorkUnit) error {
 	return nil
 }
 
-func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*intermediateData, error) {
+// Returns the names of the attributes computed while reading the points, if any
+func getAttributeNames(opts *tiler.TilerOptions) []string {
+	if opts == nil {
+		return nil
+	}
+	return opts.GetAttributeNames()
+}
+
+// Returns the local frame the points are placed in, nil if they are georeferenced
+func getPlacement(opts *tiler.TilerOptions) *geometry.LocalFrame {
+	if opts == nil {
+		return nil
+	}
+	return opts.Placement
+}
+
+// Decomposes the points of the node in separate lists. Coordinates are converted to EPSG:4978 unless the points are
+// placed in a local frame, in which case they are kept in it and the frame is set as the transform of the root tile
+func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode, attributeNames []string, placement *geometry.LocalFrame) (*intermediateData, error) {
 	points := node.GetPoints()
 
-	if c.refineMode == tiler.RefineModeReplace {
+	if c.refineMode == tiler.RefineModeReplace && node.IsAdditive() {
 		points = appendParentPoints(node, points)
 	}
 
@@ -140,6 +160,8 @@ func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*
 		colors:          make([]uint8, numPoints*3),
 		intensities:     make([]uint8, numPoints),
 		classifications: make([]uint8, numPoints),
+		attributes:      make([]float64, numPoints*len(attributeNames)),
+		attributeNames:  attributeNames,
 		numPoints:       numPoints,
 	}
 
@@ -156,9 +178,13 @@ func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*
 		}
 
 		// ConvertCoordinateSrid coords according to cesium CRS
-		outCrd, err := c.coordinateConverter.ConvertToWGS84Cartesian(srcCoord, node.GetInternalSrid())
-		if err != nil {
-			return nil, err
+		outCrd := srcCoord
+		if placement == nil {
+			var err error
+			outCrd, err = node.ToWGS84Cartesian(srcCoord, c.coordinateConverter)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		intermediateData.coords[i*3] = outCrd.X
@@ -171,6 +197,11 @@ func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*
 
 		intermediateData.intensities[i] = point.Intensity
 		intermediateData.classifications[i] = point.Classification
+
+		// points without attributes, e.g. from trees built before the attributes were defined, are stored as zeros
+		for j := 0; j < len(attributeNames) && j < len(point.Attributes); j++ {
+			intermediateData.attributes[j*numPoints+i] = float64(point.Attributes[j])
+		}
 	}
 
 	return &intermediateData, nil
@@ -206,32 +237,53 @@ func (c *StandardConsumer) generateFeatureTable(avgX float64, avgY float64, avgZ
 	return []byte(featureTableStr), featureTableLen
 }
 
-func (c *StandardConsumer) generateBatchTable(numPoints int) ([]byte, int) {
-	batchTableStr := c.generateBatchTableJsonContent(numPoints, 0)
+func (c *StandardConsumer) generateBatchTable(numPoints int, attributeNames []string) ([]byte, int) {
+	batchTableStr := c.generateBatchTableJsonContent(numPoints, attributeNames, 0)
 	batchTableLen := len(batchTableStr)
 	return []byte(batchTableStr), batchTableLen
 }
 
 func (c *StandardConsumer) generatePntsByteArray(intermediateData *intermediateData, positionBytes []byte, featureTableBytes []byte, featureTableLen int, batchTableBytes []byte, batchTableLen int) []byte {
+	batchTableBinary := c.generateBatchTableBinary(intermediateData)
 	outputByte := make([]byte, 0)
 	outputByte = append(outputByte, []byte("pnts")...)                 // magic
 	outputByte = append(outputByte, tools.ConvertIntToByteArray(1)...) // version number
 	byteLength := 28 + featureTableLen + len(positionBytes) + len(intermediateData.colors)
 	outputByte = append(outputByte, tools.ConvertIntToByteArray(byteLength)...)
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(featureTableLen)...)                                                         // feature table length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(positionBytes)+len(intermediateData.colors))...)                         // feature table binary length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(batchTableLen)...)                                                           // batch table length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(intermediateData.intensities)+len(intermediateData.classifications))...) // batch table binary length
-	outputByte = append(outputByte, featureTableBytes...)                                                                                    // feature table
-	outputByte = append(outputByte, positionBytes...)                                                                                        // positions array
-	outputByte = append(outputByte, intermediateData.colors...)                                                                              // colors array
-	outputByte = append(outputByte, batchTableBytes...)                                                                                      // batch table
-	outputByte = append(outputByte, intermediateData.intensities...)                                                                         // intensities array
+	outputByte = append(outputByte, tools.ConvertIntToByteArray(featureTableLen)...)                                 // feature table length
+	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(positionBytes)+len(intermediateData.colors))...) // feature table binary length
+	outputByte = append(outputByte, tools.ConvertIntToByteArray(batchTableLen)...)                                   // batch table length
+	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(batchTableBinary))...)                           // batch table binary length
+	outputByte = append(outputByte, featureTableBytes...)                                                            // feature table
+	outputByte = append(outputByte, positionBytes...)                                                                // positions array
+	outputByte = append(outputByte, intermediateData.colors...)                                                      // colors array
+	outputByte = append(outputByte, batchTableBytes...)                                                              // batch table
+	outputByte = append(outputByte, batchTableBinary...)                                                             // batch table binary
+
+	return outputByte
+}
+
+// Generates the binary body of the batch table: intensities, classifications and then, aligned to 4 bytes, the float
+// values of each computed attribute
+func (c *StandardConsumer) generateBatchTableBinary(intermediateData *intermediateData) []byte {
+	outputByte := make([]byte, 0)
+	outputByte = append(outputByte, intermediateData.intensities...)
 	outputByte = append(outputByte, intermediateData.classifications...)
+	if len(intermediateData.attributeNames) == 0 {
+		return outputByte
+	}
+
+	outputByte = append(outputByte, make([]byte, getAttributesByteOffset(intermediateData.numPoints)-len(outputByte))...)
+	outputByte = append(outputByte, tools.ConvertTruncateFloat64ToFloat32ByteArray(intermediateData.attributes)...)
 
 	return outputByte
 }
 
+// returns the offset of the first attribute in the batch table binary, after intensities and classifications
+func getAttributesByteOffset(numPoints int) int {
+	return (numPoints*2 + 3) / 4 * 4
+}
+
 func (c *StandardConsumer) computeAverageXYZ(intermediatePointData *intermediateData) []float64 {
 	var avgX, avgY, avgZ float64
 
@@ -272,15 +324,20 @@ func (c *StandardConsumer) generateFeatureTableJsonContent(x, y, z float64, poin
 }
 
 // Generates the json representation of the batch table
-func (c *StandardConsumer) generateBatchTableJsonContent(pointNumber, spaceNumber int) string {
+func (c *StandardConsumer) generateBatchTableJsonContent(pointNumber int, attributeNames []string, spaceNumber int) string {
 	sb := ""
 	sb += "{\"INTENSITY\":" + "{\"byteOffset\":" + "0" + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"},"
-	sb += "\"CLASSIFICATION\":" + "{\"byteOffset\":" + strconv.Itoa(pointNumber) + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"}}"
+	sb += "\"CLASSIFICATION\":" + "{\"byteOffset\":" + strconv.Itoa(pointNumber) + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"}"
+	for i, name := range attributeNames {
+		byteOffset := getAttributesByteOffset(pointNumber) + i*pointNumber*4
+		sb += ",\"" + name + "\":" + "{\"byteOffset\":" + strconv.Itoa(byteOffset) + ", \"componentType\":\"FLOAT\", \"type\":\"SCALAR\"}"
+	}
+	sb += "}"
 	sb += strings.Repeat(" ", spaceNumber)
 	headerByteLength := len([]byte(sb))
 	paddingSize := headerByteLength % 4
 	if paddingSize != 0 {
-		return c.generateBatchTableJsonContent(pointNumber, 4-paddingSize)
+		return c.generateBatchTableJsonContent(pointNumber, attributeNames, 4-paddingSize)
 	}
 	return sb
 }
@@ -298,7 +355,7 @@ func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 
 	// tileset.json file
 	file := path.Join(parentFolder, "tileset.json")
-	jsonData, err := c.generateTilesetJson(node)
+	jsonData, err := c.generateTilesetJson(node, getPlacement(workUnit.Opts))
 	if err != nil {
 		return err
 	}
@@ -313,9 +370,9 @@ func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 }
 
 // Generates the tileset.json content for the given tree node
-func (c *StandardConsumer) generateTilesetJson(node octree.INode) ([]byte, error) {
+func (c *StandardConsumer) generateTilesetJson(node octree.INode, placement *geometry.LocalFrame) ([]byte, error) {
 	if !node.IsLeaf() || node.IsRoot() {
-		root, err := c.generateTilesetRoot(node)
+		root, err := c.generateTilesetRoot(node, placement)
 		if err != nil {
 			return nil, err
 		}
@@ -334,29 +391,55 @@ func (c *StandardConsumer) generateTilesetJson(node octree.INode) ([]byte, error
 	return nil, errors.New("this node is a leaf, cannot create a tileset json for it")
 }
 
-func (c *StandardConsumer) generateTilesetRoot(node octree.INode) (*Root, error) {
-	reg, err := node.GetBoundingBoxRegion(c.coordinateConverter)
-
+func (c *StandardConsumer) generateTilesetRoot(node octree.INode, placement *geometry.LocalFrame) (*Root, error) {
+	boundingVolume, err := c.generateBoundingVolume(node, placement)
 	if err != nil {
 		return nil, err
 	}
 
-	children, err := c.generateTilesetChildren(node)
+	children, err := c.generateTilesetChildren(node, placement)
 	if err != nil {
 		return nil, err
 	}
 
 	root := Root{
-		Content:        Content{"content.pnts"},
-		BoundingVolume: BoundingVolume{reg.GetAsArray()},
+		Content:        &Content{"content.pnts"},
+		BoundingVolume: *boundingVolume,
 		GeometricError: node.ComputeGeometricError(),
-		Refine:         c.refineMode.String(),
+		Refine:         GetNodeRefineMode(node, c.refineMode).String(),
 		Children:       children,
 	}
+	// the transform of the root tile applies also to the tilesets nested in it
+	if placement != nil && node.IsRoot() {
+		transform := placement.GetTransform()
+		root.Transform = transform[:]
+	}
 
 	return &root, nil
 }
 
+// Returns the bounding volume of the node, a region if the points are georeferenced or a box in the local frame if
+// they are placed in it
+func (c *StandardConsumer) generateBoundingVolume(node octree.INode, placement *geometry.LocalFrame) (*BoundingVolume, error) {
+	if placement != nil {
+		box := node.GetBoundingBox()
+		return &BoundingVolume{
+			Box: []float64{
+				box.Xmid, box.Ymid, box.Zmid,
+				(box.Xmax - box.Xmin) / 2, 0, 0,
+				0, (box.Ymax - box.Ymin) / 2, 0,
+				0, 0, (box.Zmax - box.Zmin) / 2,
+			},
+		}, nil
+	}
+
+	reg, err := node.GetBoundingBoxRegion(c.coordinateConverter)
+	if err != nil {
+		return nil, err
+	}
+	return &BoundingVolume{Region: reg.GetAsArray()}, nil
+}
+
 func (c *StandardConsumer) generateTileset(node octree.INode, root *Root) *Tileset {
 	tileset := Tileset{}
 	tileset.Asset = Asset{Version: "1.0"}
@@ -366,11 +449,11 @@ func (c *StandardConsumer) generateTileset(node octree.INode, root *Root) *Tiles
 	return &tileset
 }
 
-func (c *StandardConsumer) generateTilesetChildren(node octree.INode) ([]Child, error) {
+func (c *StandardConsumer) generateTilesetChildren(node octree.INode, placement *geometry.LocalFrame) ([]Child, error) {
 	var children []Child
 	for i, child := range node.GetChildren() {
 		if c.nodeContainsPoints(child) {
-			childJson, err := c.generateTilesetChild(child, i)
+			childJson, err := c.generateTilesetChild(child, i, placement)
 			if err != nil {
 				return nil, err
 			}
@@ -380,11 +463,21 @@ func (c *StandardConsumer) generateTilesetChildren(node octree.INode) ([]Child,
 	return children, nil
 }
 
+// Returns the refine mode to use for the given node. Nodes whose points are not additive always replace the content
+// of their parents regardless of the requested refine mode
+func GetNodeRefineMode(node octree.INode, refineMode tiler.RefineMode) tiler.RefineMode {
+	if !node.IsAdditive() {
+		return tiler.RefineModeReplace
+	}
+
+	return refineMode
+}
+
 func (c *StandardConsumer) nodeContainsPoints(node octree.INode) bool {
 	return node != nil && node.TotalNumberOfPoints() > 0
 }
 
-func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex int) (*Child, error) {
+func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex int, placement *geometry.LocalFrame) (*Child, error) {
 	childJson := Child{}
 	filename := "tileset.json"
 	if child.IsLeaf() {
@@ -393,14 +486,12 @@ func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex i
 	childJson.Content = Content{
 		Url: strconv.Itoa(childIndex) + "/" + filename,
 	}
-	reg, err := child.GetBoundingBoxRegion(c.coordinateConverter)
+	boundingVolume, err := c.generateBoundingVolume(child, placement)
 	if err != nil {
 		return nil, err
 	}
-	childJson.BoundingVolume = BoundingVolume{
-		Region: reg.GetAsArray(),
-	}
+	childJson.BoundingVolume = *boundingVolume
 	childJson.GeometricError = child.ComputeGeometricError()
-	childJson.Refine = c.refineMode.String()
+	childJson.Refine = GetNodeRefineMode(child, c.refineMode).String()
 	return &childJson, nil
 }
@@ -9,7 +9,8 @@ type Content struct {
 }
 
 type BoundingVolume struct {
-	Region []float64 `json:"region"`
+	Region []float64 `json:"region,omitempty"`
+	Box    []float64 `json:"box,omitempty"`
 }
 
 type Child struct {
@@ -21,7 +22,8 @@ type Child struct {
 
 type Root struct {
 	Children       []Child        `json:"children"`
-	Content        Content        `json:"content"`
+	Content        *Content       `json:"content,omitempty"`
+	Transform      []float64      `json:"transform,omitempty"`
 	BoundingVolume BoundingVolume `json:"boundingVolume"`
 	GeometricError float64        `json:"geometricError"`
 	Refine         string         `json:"refine"`
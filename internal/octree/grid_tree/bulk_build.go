@@ -0,0 +1,92 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"sync"
+)
+
+// nodes up to this depth bulk build their children in parallel
+const parallelBulkBuildDepth = 3
+
+// builds the node and its subtree from the given points, in the order the node would receive them in the streaming
+// build. The node samples the points replaying the cell rules used when they are added one by one, without any lock,
+// and passes the rejected ones to its children, which are built independently
+func (n *GridNode) bulkBuild(points []*data.Point) {
+	n.initialized = true
+	n.totalNumberOfPoints = int64(len(points))
+
+	rejected := n.selectPoints(points)
+	n.numberOfPoints = int32(len(n.points))
+
+	if len(rejected) == 0 {
+		return
+	}
+	n.clearLeafFlag()
+
+	var wg sync.WaitGroup
+	for octant, group := range n.splitInOctants(rejected) {
+		if len(group) == 0 {
+			continue
+		}
+		octant := uint8(octant)
+		child := NewGridNode(n, n.Frame, getOctantBoundingBox(&octant, n.BoundingBox), n.cellSize/2.0, n.opts, false).(*GridNode)
+		n.children[octant] = child
+		if n.depth < parallelBulkBuildDepth {
+			wg.Add(1)
+			go func(child *GridNode, points []*data.Point) {
+				child.bulkBuild(points)
+				wg.Done()
+			}(child, group)
+		} else {
+			child.bulkBuild(group)
+		}
+	}
+	wg.Wait()
+}
+
+// stores in the node the points its grid cells retain when the given points are pushed to them in order, as the
+// streaming build does, and returns the other ones in the order they are pushed out of the cells, which is the order
+// the children receive them in
+func (n *GridNode) selectPoints(points []*data.Point) []*data.Point {
+	var storedPoints int32
+	reserveSlot := func() bool {
+		if n.maxNumberOfPoints > 0 && storedPoints >= n.maxNumberOfPoints && !n.isAtMaxDepth() {
+			return false
+		}
+		storedPoints++
+		return true
+	}
+
+	cells := make(map[gridIndex]*gridCell)
+	var rejected []*data.Point
+	for _, point := range points {
+		index := *n.getPointGridCellIndex(point)
+		cell := cells[index]
+		if cell == nil {
+			cell = &gridCell{index: index, size: n.cellSize, sizeThreshold: n.minCellSize, opts: n.opts}
+			cells[index] = cell
+		}
+		if pushedOut := cell.pushPoint(point, n.isAtMaxDepth(), reserveSlot); pushedOut != nil {
+			rejected = append(rejected, pushedOut)
+		}
+	}
+
+	n.points = make([]*data.Point, 0, storedPoints)
+	for _, cell := range getCells(cells, true) {
+		n.points = append(n.points, cell.points...)
+	}
+	n.cells = nil
+
+	return rejected
+}
+
+// groups the given points by the octant of the node they fall into, keeping their order
+func (n *GridNode) splitInOctants(points []*data.Point) [8][]*data.Point {
+	var octants [8][]*data.Point
+	for _, point := range points {
+		octant := octree.GetOctant(point, n.BoundingBox)
+		octants[octant] = append(octants[octant], point)
+	}
+	return octants
+}
@@ -2,18 +2,24 @@ package grid_tree
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"math"
+	"sort"
 	"sync"
 )
 
 // Data structure that accepts points and stores just the one closest to its center, or if the side is too small,
-// all the points. It assumes that coordinates are expressed in a metric cartesian system.
+// all the points. Points whose classification has a higher priority are preferred regardless of their distance from
+// the center, and if a point scorer is configured points with a higher score are preferred to the closest ones.
+// It assumes that coordinates are expressed in a metric cartesian system.
 type gridCell struct {
-	index              gridIndex     // unique spatial index of the cell
-	size               float64       // length of the side of the cell (cubic cell)
-	points             []*data.Point // points stored in the cell
-	sizeThreshold      float64       // if size is below sizeThreshold store all points in the cell instead of just the one closest to the center
-	distanceFromCenter float64       // distance from center of current point at index 0
+	index              gridIndex           // unique spatial index of the cell
+	size               float64             // length of the side of the cell (cubic cell)
+	column             bool                // if the cell spans the whole height of its node, ignoring Z in distances
+	points             []*data.Point       // points stored in the cell
+	sizeThreshold      float64             // if size is below sizeThreshold store all points in the cell instead of just the one closest to the center
+	distanceFromCenter float64             // distance from center of current point at index 0
+	opts               *tiler.TilerOptions // options with the classification priorities and the point scorer
 	sync.RWMutex
 }
 
@@ -29,17 +35,26 @@ func (gc *gridCell) getCellCenter() (float64, float64, float64) {
 		float64(gc.index.z)*gc.size + gc.size/2
 }
 
-// submits a point to the cell, eventually returning a pointer to the point pushed out.
-func (gc *gridCell) pushPoint(point *data.Point) *data.Point {
-	if gc.points == nil {
+// submits a point to the cell, eventually returning a pointer to the point pushed out. If storeAll is true the cell
+// stores all the points as if its size was below the threshold. New points are stored only if reserveSlot, which
+// tracks the capacity of the node owning the cell, returns true, otherwise they are pushed out
+func (gc *gridCell) pushPoint(point *data.Point, storeAll bool, reserveSlot func() bool) *data.Point {
+	gc.Lock()
+	defer gc.Unlock()
+
+	if len(gc.points) == 0 {
+		if !reserveSlot() {
+			return point
+		}
 		gc.storeFirstPoint(point)
 		return nil
 	}
 
-	if gc.isSizeBelowThreshold() {
-		gc.Lock()
+	if storeAll || gc.isSizeBelowThreshold() {
+		if !reserveSlot() {
+			return point
+		}
 		gc.points = append(gc.points, point)
-		gc.Unlock()
 		return nil
 	}
 
@@ -51,34 +66,36 @@ func (gc *gridCell) isSizeBelowThreshold() bool {
 	return gc.size < gc.sizeThreshold
 }
 
-// sets the points slice to a new slice containing the input point and stores its distanceFromCenter
+// sets the points slice to a new slice containing the input point and stores its distanceFromCenter.
+// Must be called with the cell lock held
 func (gc *gridCell) storeFirstPoint(point *data.Point) {
-	gc.Lock()
 	gc.points = []*data.Point{point}
 	gc.distanceFromCenter = gc.getDistanceFromCenter(point)
-	gc.Unlock()
 }
 
 // takes the input point and compares its distance from the center to the one in the points array,
-// storing in the array only the one closest to the center and returning the other, rejected and farthest from the center, one
+// storing in the array only the one closest to the center and returning the other, rejected and farthest from the center, one.
+// Points with a higher classification priority are always preferred. Ties are broken comparing the points attributes,
+// so that the point kept does not depend on the insertion order. Must be called with the cell lock held
 func (gc *gridCell) storeClosestPointAndReturnFarthestOne(point *data.Point) *data.Point {
 	distance := gc.getDistanceFromCenter(point)
 
-	if distance < gc.distanceFromCenter {
-		gc.Lock()
+	if isBetterRepresentative(point, distance, gc.points[0], gc.distanceFromCenter, gc.opts) {
 		oldPoint := gc.points[0]
 		gc.points[0] = point
 		gc.distanceFromCenter = distance
-		gc.Unlock()
 		return oldPoint
 	}
 
 	return point
 }
 
-// computes the cartesian distance of a point from the cell center
+// computes the cartesian distance of a point from the cell center, or from its vertical axis for column cells
 func (gc *gridCell) getDistanceFromCenter(point *data.Point) float64 {
 	xc, yc, zc := gc.getCellCenter()
+	if gc.column {
+		return math.Sqrt(math.Pow(point.X-xc, 2) + math.Pow(point.Y-yc, 2))
+	}
 
 	return math.Sqrt(
 		math.Pow(point.X-xc, 2) +
@@ -86,3 +103,65 @@ func (gc *gridCell) getDistanceFromCenter(point *data.Point) float64 {
 			math.Pow(point.Z-zc, 2),
 	)
 }
+
+// checks if a point at the given distance from the cell center should replace the current one, i.e. if its
+// classification has a higher priority or, with the same priority, if it has a higher score or, with the same score,
+// if it is closer to the center
+func isBetterRepresentative(point *data.Point, distance float64, current *data.Point, currentDistance float64, opts *tiler.TilerOptions) bool {
+	if opts != nil {
+		priority := opts.ClassPriorities[point.Classification]
+		currentPriority := opts.ClassPriorities[current.Classification]
+		if priority != currentPriority {
+			return priority > currentPriority
+		}
+
+		if opts.PointScorer != nil {
+			score := opts.PointScorer.Score(point, distance)
+			currentScore := opts.PointScorer.Score(current, currentDistance)
+			if score != currentScore {
+				return score > currentScore
+			}
+		}
+	}
+
+	return distance < currentDistance || (distance == currentDistance && precedes(point, current))
+}
+
+// checks if point a comes before point b comparing their coordinates and then their other attributes
+func precedes(a *data.Point, b *data.Point) bool {
+	if a.X != b.X {
+		return a.X < b.X
+	}
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	if a.Z != b.Z {
+		return a.Z < b.Z
+	}
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	if a.B != b.B {
+		return a.B < b.B
+	}
+	if a.Intensity != b.Intensity {
+		return a.Intensity < b.Intensity
+	}
+	return a.Classification < b.Classification
+}
+
+// returns the given cells, sorted by their index if sorted is true, in map iteration order otherwise
+func getCells(cells map[gridIndex]*gridCell, sorted bool) []*gridCell {
+	out := make([]*gridCell, 0, len(cells))
+	for _, cell := range cells {
+		out = append(out, cell)
+	}
+	if sorted {
+		sort.Slice(out, func(i, j int) bool { return out[i].index.less(out[j].index) })
+	}
+
+	return out
+}
@@ -6,3 +6,14 @@ type gridIndex struct {
 	y int
 	z int
 }
+
+// checks if the index comes before the given one, comparing x, then y, then z
+func (i gridIndex) less(other gridIndex) bool {
+	if i.x != other.x {
+		return i.x < other.x
+	}
+	if i.y != other.y {
+		return i.y < other.y
+	}
+	return i.z < other.z
+}
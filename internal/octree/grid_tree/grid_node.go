@@ -2,10 +2,10 @@ package grid_tree
 
 import "C"
 import (
-	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"math"
 	"sync"
 	"sync/atomic"
@@ -14,16 +14,21 @@ import (
 // Models a node of the octree, which can either be a leaf (a node without children nodes) or not.
 // Each Node can contain up to eight children nodes. The node uses a grid algorithm to decide which points to store.
 // It divides its bounding box in gridCells and only stores points retained by these cells, propagating the ones rejected
-// by the cells to its children which will have smaller cells.
+// by the cells to its children which will have smaller cells. Once a node stores the max number of points allowed,
+// new points are propagated to its children too, unless the node is at the max depth allowed.
 type GridNode struct {
+	octree.LocalFrameNode
 	root                bool
 	parent              octree.INode
-	boundingBox         *geometry.BoundingBox
 	children            [8]octree.INode
 	cells               map[gridIndex]*gridCell
 	points              []*data.Point
 	cellSize            float64
 	minCellSize         float64
+	maxNumberOfPoints   int32
+	depth               int
+	maxDepth            int
+	opts                *tiler.TilerOptions
 	totalNumberOfPoints int64
 	numberOfPoints      int32
 	leaf                int32
@@ -31,14 +36,24 @@ type GridNode struct {
 	sync.RWMutex
 }
 
-// Instantiates a new GridNode
-func NewGridNode(parent octree.INode, boundingBox *geometry.BoundingBox, maxCellSize float64, minCellSize float64, root bool) octree.INode {
+// Instantiates a new GridNode. The bounding box and the points are expressed in the given local frame
+func NewGridNode(parent octree.INode, frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox, cellSize float64, opts *tiler.TilerOptions, root bool) octree.INode {
+	depth := 0
+	if parent != nil {
+		depth = parent.(*GridNode).depth + 1
+	}
+
 	node := GridNode{
+		LocalFrameNode: octree.LocalFrameNode{Frame: frame, BoundingBox: boundingBox},
+
 		parent:              parent,						   // the parent node
 		root:                root,                             // if the node is the tree root
-		boundingBox:         boundingBox,                      // bounding box of the node
-		cellSize:            maxCellSize,                      // max size setting to use for gridCells
-		minCellSize:         minCellSize,                      // min size setting to use for gridCells
+		cellSize:            cellSize,                         // size of the gridCells of this node
+		minCellSize:         opts.CellMinSize,                 // min size setting to use for gridCells
+		maxNumberOfPoints:   opts.GridMaxNumPointsPerNode,     // max number of points stored in the node, 0 if unlimited
+		depth:               depth,                            // depth of the node in the tree, 0 for the root
+		maxDepth:            octree.GetMaxDepth(opts),         // max depth of the nodes of the tree
+		opts:                opts,                             // options to pass to the children nodes
 		points:              make([]*data.Point, 0),           // slice keeping references to points stored in the gridCells
 		cells:               make(map[gridIndex]*gridCell, 0), // gridCells that subdivide this node bounding box
 		totalNumberOfPoints: 0,                                // total number of points stored in this node and its children
@@ -60,37 +75,18 @@ func (n *GridNode) AddDataPoint(point *data.Point) {
 		n.initializeChildren()
 	}
 
+	// if a point is stored without pushing out another one the number of points stored is increased when reserving
+	// its slot
 	pushedOutPoint := n.pushPointToCell(point)
 
 	if pushedOutPoint != nil {
 		n.addPointToChildren(pushedOutPoint)
-	} else {
-		// if no point was rejected then the number of points stored is increased by 1
-		atomic.AddInt32(&n.numberOfPoints, 1)
 	}
 
 	// in any case the total number of points stored by the n or its children increases by one
 	atomic.AddInt64(&n.totalNumberOfPoints, 1)
 }
 
-func (n *GridNode) GetInternalSrid() int {
-	return internalCoordinateEpsgCode
-}
-
-func (n *GridNode) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
-	reg, err := converter.Convert2DBoundingboxToWGS84Region(n.boundingBox, n.GetInternalSrid())
-
-	if err != nil {
-		return nil, err
-	}
-
-	return reg, nil
-}
-
-func (n *GridNode) GetBoundingBox() *geometry.BoundingBox {
-	return n.boundingBox
-}
-
 func (n *GridNode) GetChildren() [8]octree.INode {
 	return n.children
 }
@@ -122,36 +118,21 @@ func (n *GridNode) IsRoot() bool {
 // Computes the geometric error for the given GridNode
 func (n *GridNode) ComputeGeometricError() float64 {
 	if n.IsRoot() {
-		var w = math.Abs(n.boundingBox.Xmax - n.boundingBox.Xmin)
-		var l = math.Abs(n.boundingBox.Ymax - n.boundingBox.Ymin)
-		var h = math.Abs(n.boundingBox.Zmax - n.boundingBox.Zmin)
+		var w = math.Abs(n.BoundingBox.Xmax - n.BoundingBox.Xmin)
+		var l = math.Abs(n.BoundingBox.Ymax - n.BoundingBox.Ymin)
+		var h = math.Abs(n.BoundingBox.Zmax - n.BoundingBox.Zmin)
 		return math.Sqrt(w * w + l * l + h * h)
 	}
 	// geometric error is estimated as the maximum possible distance between two points lying in the cell
 	return n.cellSize * math.Sqrt(3) * 2
 }
 
-// Returns the index of the octant that contains the given Point within this boundingBox
-func getOctantFromElement(element *data.Point, bbox *geometry.BoundingBox) uint8 {
-	var result uint8 = 0
-	if float64(element.X) > bbox.Xmid {
-		result += 1
-	}
-	if float64(element.Y) > bbox.Ymid {
-		result += 2
-	}
-	if float64(element.Z) > bbox.Zmid {
-		result += 4
-	}
-	return result
-}
-
-// loads the points stored in the grid cells into the slice data structure
+// loads the points stored in the grid cells into the slice data structure, sorting the cells in deterministic mode,
 // and recursively builds the points of its children.
 // sets the slice reference to nil to allow GC to happen as the cells won't be used anymore
 func (n *GridNode) BuildPoints() {
 	var points []*data.Point
-	for _, cell := range n.cells {
+	for _, cell := range getCells(n.cells, n.opts.Deterministic) {
 		points = append(points, cell.points...)
 	}
 	n.points = points
@@ -168,6 +149,10 @@ func (n *GridNode) GetParent() octree.INode {
 	return n.parent
 }
 
+func (n *GridNode) IsAdditive() bool {
+	return true
+}
+
 // gets the grid cell where the given point falls into, eventually creating it if it does not exist
 func (n *GridNode) getPointGridCell(point *data.Point) *gridCell {
 	index := *n.getPointGridCellIndex(point)
@@ -201,6 +186,7 @@ func (n *GridNode) initializeGridCell(index *gridIndex) *gridCell {
 			index:         *index,
 			size:          n.cellSize,
 			sizeThreshold: n.minCellSize,
+			opts:          n.opts,
 		}
 		n.cells[*index] = out
 	}
@@ -217,12 +203,29 @@ func (n *GridNode) isEmpty() bool {
 
 // pushes a point to its gridcell and returns the point eventually pushed out
 func (n *GridNode) pushPointToCell(point *data.Point) *data.Point {
-	return n.getPointGridCell(point).pushPoint(point)
+	return n.getPointGridCell(point).pushPoint(point, n.isAtMaxDepth(), n.reserveSlot)
+}
+
+// checks if the node is at the max depth allowed, in which case it must store all points submitted
+func (n *GridNode) isAtMaxDepth() bool {
+	return n.depth >= n.maxDepth
+}
+
+// atomically reserves a slot for a new point in the node, returning false if the node already stores the max
+// number of points allowed
+func (n *GridNode) reserveSlot() bool {
+	numberOfPoints := atomic.AddInt32(&n.numberOfPoints, 1)
+	if n.maxNumberOfPoints > 0 && numberOfPoints > n.maxNumberOfPoints && !n.isAtMaxDepth() {
+		atomic.AddInt32(&n.numberOfPoints, -1)
+		return false
+	}
+
+	return true
 }
 
 // add a point to the node children and clears the leaf flag from this node
 func (n *GridNode) addPointToChildren(point *data.Point) {
-	n.children[getOctantFromElement(point, n.boundingBox)].AddDataPoint(point)
+	n.children[octree.GetOctant(point, n.BoundingBox)].AddDataPoint(point)
 	n.clearLeafFlag()
 }
 
@@ -231,12 +234,12 @@ func (n *GridNode) clearLeafFlag() {
 	atomic.StoreInt32(&n.leaf, 0)
 }
 
-// initializes the children to new empty nodes
+// initializes the children to new empty nodes. Nodes at the max depth have no children
 func (n *GridNode) initializeChildren() {
 	n.Lock()
-	for i := uint8(0); i < 8; i++ {
+	for i := uint8(0); i < 8 && !n.isAtMaxDepth(); i++ {
 		if n.children[i] == nil {
-			n.children[i] = NewGridNode(n, getOctantBoundingBox(&i, n.boundingBox), n.cellSize/2.0, n.minCellSize, false)
+			n.children[i] = NewGridNode(n, n.Frame, getOctantBoundingBox(&i, n.BoundingBox), n.cellSize/2.0, n.opts, false)
 		}
 	}
 	n.initialized = true
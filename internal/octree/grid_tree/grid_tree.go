@@ -1,123 +1,54 @@
 package grid_tree
 
 import (
-	"errors"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
-	"log"
-	"runtime"
-	"sync"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 )
 
-// Coordinates are stored in EPSG 3395, which is a cartesian 2D metric reference system
-const internalCoordinateEpsgCode = 3395
-
 // Represents an GridTree of points and contains all information needed
-// to propagate points in the tree
+// to propagate points in the tree. Points are stored in a local East-North-Up frame, so that cell sizes are
+// expressed in true meters regardless of the position of the cloud on Earth
 type GridTree struct {
-	rootNode            octree.INode
-	built               bool
-	maxCellSize         float64
-	minCellSize         float64
-	coordinateConverter converters.CoordinateConverter
-	elevationCorrector  converters.ElevationCorrector
-	point_loader.Loader
-	sync.RWMutex
+	opts *tiler.TilerOptions
+	*octree.LocalFrameTree
 }
 
 // Builds an empty GridTree initializing its properties to the correct defaults
-func NewGridTree(coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector, maxCellSize float64, minCellSize float64) octree.ITree {
+func NewGridTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
 	return &GridTree{
-		built:               false,
-		maxCellSize:         maxCellSize,
-		minCellSize:         minCellSize,
-		Loader:              point_loader.NewSequentialLoader(),
-		coordinateConverter: coordinateConverter,
-		elevationCorrector:  elevationCorrector,
+		opts: opts,
+		LocalFrameTree: octree.NewLocalFrameTree(point_loader.NewSequentialLoader(), opts, coordinateConverter, elevationCorrector, func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) octree.INode {
+			return NewGridNode(nil, frame, boundingBox, opts.CellMaxSize, opts, true)
+		}),
 	}
 }
 
-// Builds the hierarchical tree structure 
+// Builds the hierarchical tree structure
 func (tree *GridTree) Build() error {
-	if tree.built {
-		return errors.New("octree already built")
+	if tree.opts.GridBulkBuild {
+		return tree.BuildWith(tree.bulkBuild)
 	}
-
-	tree.init()
-
-	var wg sync.WaitGroup
-	tree.launchParallelPointLoaders(&wg)
-	wg.Wait()
-
-	tree.rootNode.(*GridNode).BuildPoints()
-	tree.built = true
-
-	return nil
-}
-
-func (tree *GridTree) GetRootNode() octree.INode {
-	return tree.rootNode
+	return tree.LocalFrameTree.Build()
 }
 
-func (tree *GridTree) IsBuilt() bool {
-	return tree.built
-}
-
-func (tree *GridTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) {
-	tree.Loader.AddPoint(tree.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid))
-}
-
-func (tree *GridTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) *data.Point {
-	wgs84coords, err := tree.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
-	z := tree.elevationCorrector.CorrectElevation(wgs84coords.X, wgs84coords.Y, wgs84coords.Z)
-
-	worldMercatorCoords, err := tree.coordinateConverter.ConvertCoordinateSrid(
-		srid,
-		internalCoordinateEpsgCode,
-		geometry.Coordinate{
-			X: coordinate.X,
-			Y: coordinate.Y,
-			Z: z,
-		},
-	)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return data.NewPoint(worldMercatorCoords.X, worldMercatorCoords.Y, worldMercatorCoords.Z, r, g, b, intensity, classification)
-}
-
-
-
-func (tree *GridTree) init() {
-	box := tree.GetBounds()
-	node := NewGridNode(nil, geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5]), tree.maxCellSize, tree.minCellSize, true)
-	tree.rootNode = node
-	tree.InitializeLoader()
-}
-
-func (tree *GridTree) launchParallelPointLoaders(waitGroup *sync.WaitGroup) {
-	N := runtime.NumCPU()
-
-	for i := 0; i < N; i++ {
-		waitGroup.Add(1)
-		go tree.launchPointLoader(waitGroup)
-	}
-}
-
-func (tree *GridTree) launchPointLoader(waitGroup *sync.WaitGroup) {
+// builds the tree once all the points have been read, replaying the streaming build of each subtree in parallel
+// without locks
+func (tree *GridTree) bulkBuild(rootNode octree.INode) {
+	var points []*data.Point
 	for {
 		val, shouldContinue := tree.Loader.GetNext()
 		if val != nil {
-			tree.rootNode.AddDataPoint(val)
+			points = append(points, val)
 		}
 		if !shouldContinue {
 			break
 		}
 	}
-	waitGroup.Done()
-}
\ No newline at end of file
+
+	root := rootNode.(*GridNode)
+	root.bulkBuild(points)
+}
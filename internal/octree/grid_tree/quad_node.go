@@ -0,0 +1,249 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Models a node of the quadtree, which can either be a leaf (a node without children nodes) or not.
+// Each Node can contain up to four children nodes, obtained splitting its bounding box along X and Y only, stored in
+// the first four slots of the children array. Points are sampled with the same rules used by the GridNode, but its
+// gridCells are columns spanning the whole height of the node, so that each level only refines the sampling in X and Y.
+// Once the tree is built the vertical extent of the bounding box of each node is shrunk to the one of its points.
+type QuadNode struct {
+	root   bool
+	parent octree.INode
+	octree.LocalFrameNode
+	children            [8]octree.INode
+	cells               map[gridIndex]*gridCell
+	points              []*data.Point
+	cellSize            float64
+	minCellSize         float64
+	maxNumberOfPoints   int32
+	depth               int
+	maxDepth            int
+	opts                *tiler.TilerOptions
+	totalNumberOfPoints int64
+	numberOfPoints      int32
+	leaf                int32
+	initialized         bool
+	sync.RWMutex
+}
+
+// Instantiates a new QuadNode. The bounding box and the points are expressed in the given local frame
+func NewQuadNode(parent octree.INode, frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox, cellSize float64, opts *tiler.TilerOptions, root bool) octree.INode {
+	depth := 0
+	if parent != nil {
+		depth = parent.(*QuadNode).depth + 1
+	}
+
+	node := QuadNode{
+		parent:            parent,
+		LocalFrameNode:    octree.LocalFrameNode{Frame: frame, BoundingBox: boundingBox},
+		root:              root,
+		cellSize:          cellSize,
+		minCellSize:       opts.CellMinSize,
+		maxNumberOfPoints: opts.GridMaxNumPointsPerNode,
+		depth:             depth,
+		maxDepth:          octree.GetMaxDepth(opts),
+		opts:              opts,
+		points:            make([]*data.Point, 0),
+		cells:             make(map[gridIndex]*gridCell, 0),
+		leaf:              1,
+		initialized:       false,
+	}
+
+	return &node
+}
+
+// Adds a Point to the QuadNode and propagates the point eventually pushed out to the appropriate children
+func (n *QuadNode) AddDataPoint(point *data.Point) {
+	if point == nil {
+		return
+	}
+
+	if atomic.LoadInt32(&n.numberOfPoints) == 0 {
+		n.initializeChildren()
+	}
+
+	pushedOutPoint := n.getPointGridCell(point).pushPoint(point, n.isAtMaxDepth(), n.reserveSlot)
+
+	if pushedOutPoint != nil {
+		n.children[getQuadrantFromElement(pushedOutPoint, n.BoundingBox)].AddDataPoint(pushedOutPoint)
+		atomic.StoreInt32(&n.leaf, 0)
+	}
+
+	atomic.AddInt64(&n.totalNumberOfPoints, 1)
+}
+
+func (n *QuadNode) GetChildren() [8]octree.INode {
+	return n.children
+}
+
+func (n *QuadNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *QuadNode) TotalNumberOfPoints() int64 {
+	return n.totalNumberOfPoints
+}
+
+func (n *QuadNode) NumberOfPoints() int32 {
+	return n.numberOfPoints
+}
+
+func (n *QuadNode) IsLeaf() bool {
+	return atomic.LoadInt32(&n.leaf) == 1
+}
+
+func (n *QuadNode) IsInitialized() bool {
+	return n.initialized
+}
+
+func (n *QuadNode) IsRoot() bool {
+	return n.root
+}
+
+func (n *QuadNode) GetParent() octree.INode {
+	return n.parent
+}
+
+func (n *QuadNode) IsAdditive() bool {
+	return true
+}
+
+// Computes the geometric error for the given QuadNode
+func (n *QuadNode) ComputeGeometricError() float64 {
+	if n.IsRoot() {
+		var w = math.Abs(n.BoundingBox.Xmax - n.BoundingBox.Xmin)
+		var l = math.Abs(n.BoundingBox.Ymax - n.BoundingBox.Ymin)
+		var h = math.Abs(n.BoundingBox.Zmax - n.BoundingBox.Zmin)
+		return math.Sqrt(w*w + l*l + h*h)
+	}
+	// geometric error is estimated as the maximum possible horizontal distance between two points lying in the cell
+	return n.cellSize * math.Sqrt(2) * 2
+}
+
+// loads the points stored in the grid cells into the slice data structure and recursively builds the points of its
+// children, shrinking the vertical extent of their bounding boxes to the one of their points
+func (n *QuadNode) BuildPoints() {
+	n.buildPoints()
+}
+
+// builds the points of the node and of its children, then shrinks the vertical extent of the bounding box to the one
+// of the points of the node and its children. Returns the min and max Z of these points, or false if there are none
+func (n *QuadNode) buildPoints() (float64, float64, bool) {
+	var points []*data.Point
+	for _, cell := range getCells(n.cells, n.opts.Deterministic) {
+		points = append(points, cell.points...)
+	}
+	n.points = points
+	n.cells = nil
+
+	zMin, zMax := math.Inf(1), math.Inf(-1)
+	for _, point := range points {
+		zMin = math.Min(zMin, point.Z)
+		zMax = math.Max(zMax, point.Z)
+	}
+
+	for _, child := range n.children {
+		if child != nil {
+			if childZMin, childZMax, ok := child.(*QuadNode).buildPoints(); ok {
+				zMin = math.Min(zMin, childZMin)
+				zMax = math.Max(zMax, childZMax)
+			}
+		}
+	}
+
+	if zMin > zMax {
+		return 0, 0, false
+	}
+
+	n.BoundingBox = geometry.NewBoundingBox(n.BoundingBox.Xmin, n.BoundingBox.Xmax, n.BoundingBox.Ymin, n.BoundingBox.Ymax, zMin, zMax)
+	return zMin, zMax, true
+}
+
+// gets the column grid cell where the given point falls into, eventually creating it if it does not exist
+func (n *QuadNode) getPointGridCell(point *data.Point) *gridCell {
+	index := gridIndex{
+		getDimensionIndex(point.X, n.cellSize),
+		getDimensionIndex(point.Y, n.cellSize),
+		0,
+	}
+
+	n.RLock()
+	cell := n.cells[index]
+	n.RUnlock()
+
+	if cell != nil {
+		return cell
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	cell = n.cells[index]
+	if cell == nil {
+		cell = &gridCell{
+			index:         index,
+			size:          n.cellSize,
+			column:        true,
+			sizeThreshold: n.minCellSize,
+			opts:          n.opts,
+		}
+		n.cells[index] = cell
+	}
+
+	return cell
+}
+
+// checks if the node is at the max depth allowed, in which case it must store all points submitted
+func (n *QuadNode) isAtMaxDepth() bool {
+	return n.depth >= n.maxDepth
+}
+
+// atomically reserves a slot for a new point in the node, returning false if the node already stores the max
+// number of points allowed
+func (n *QuadNode) reserveSlot() bool {
+	numberOfPoints := atomic.AddInt32(&n.numberOfPoints, 1)
+	if n.maxNumberOfPoints > 0 && numberOfPoints > n.maxNumberOfPoints && !n.isAtMaxDepth() {
+		atomic.AddInt32(&n.numberOfPoints, -1)
+		return false
+	}
+
+	return true
+}
+
+// initializes the four children to new empty nodes. Nodes at the max depth have no children
+func (n *QuadNode) initializeChildren() {
+	n.Lock()
+	for i := uint8(0); i < 4 && !n.isAtMaxDepth(); i++ {
+		if n.children[i] == nil {
+			n.children[i] = NewQuadNode(n, n.Frame, getQuadrantBoundingBox(i, n.BoundingBox), n.cellSize/2.0, n.opts, false)
+		}
+	}
+	n.initialized = true
+	n.Unlock()
+}
+
+// Returns the index of the quadrant that contains the given Point within this boundingBox
+func getQuadrantFromElement(element *data.Point, bbox *geometry.BoundingBox) uint8 {
+	var result uint8 = 0
+	if element.X > bbox.Xmid {
+		result += 1
+	}
+	if element.Y > bbox.Ymid {
+		result += 2
+	}
+	return result
+}
+
+// Returns the bounding box of the given quadrant of the given box, which keeps the whole vertical extent of the box
+func getQuadrantBoundingBox(quadrant uint8, bbox *geometry.BoundingBox) *geometry.BoundingBox {
+	octantBox := geometry.NewBoundingBoxFromParent(bbox, &quadrant)
+	return geometry.NewBoundingBox(octantBox.Xmin, octantBox.Xmax, octantBox.Ymin, octantBox.Ymax, bbox.Zmin, bbox.Zmax)
+}
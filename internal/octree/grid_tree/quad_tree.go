@@ -0,0 +1,25 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+)
+
+// Represents a QuadTree of points, suited for airborne datasets much wider than tall. Nodes are subdivided along the
+// East and North axes only, while their vertical extent is taken from the points they contain. Points are stored in a
+// local East-North-Up frame, so that cell sizes are expressed in true meters regardless of the position of the cloud
+type QuadTree struct {
+	*octree.LocalFrameTree
+}
+
+// Builds an empty QuadTree initializing its properties to the correct defaults
+func NewQuadTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &QuadTree{
+		LocalFrameTree: octree.NewLocalFrameTree(point_loader.NewSequentialLoader(), opts, coordinateConverter, elevationCorrector, func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) octree.INode {
+			return NewQuadNode(nil, frame, boundingBox, opts.CellMaxSize, opts, true)
+		}),
+	}
+}
@@ -0,0 +1,221 @@
+package kd_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// nodes up to this depth build their children in parallel
+const parallelBuildDepth = 3
+
+// Models a node of the kd-tree, which can either be a leaf (a node without children nodes) or not.
+// A non leaf node stores a random sample of its points, as large as the max number of points per node, and splits the
+// remaining ones in two children along the median of the longest axis of their bounding box. The children are stored
+// in the first two slots of the children array. Leaf nodes store up to the max number of points per node.
+// The bounding box of each node tightly encloses the points of the node and of its children.
+type KdNode struct {
+	root   bool
+	parent octree.INode
+	octree.LocalFrameNode
+	children            [8]octree.INode
+	points              []*data.Point
+	depth               int
+	seed                int64
+	opts                *tiler.TilerOptions
+	totalNumberOfPoints int64
+	numberOfPoints      int32
+	leaf                bool
+	initialized         bool
+	sync.Mutex
+}
+
+// Instantiates a new KdNode. The bounding box and the points are expressed in the given local frame
+func NewKdNode(parent octree.INode, frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox, opts *tiler.TilerOptions, root bool) octree.INode {
+	depth := 0
+	if parent != nil {
+		depth = parent.(*KdNode).depth + 1
+	}
+
+	node := KdNode{
+		root:           root,
+		parent:         parent,
+		LocalFrameNode: octree.LocalFrameNode{Frame: frame, BoundingBox: boundingBox},
+		points:         make([]*data.Point, 0),
+		depth:          depth,
+		seed:           opts.Seed,
+		opts:           opts,
+		leaf:           true,
+		initialized:    false,
+	}
+
+	return &node
+}
+
+// Adds a Point to the KdNode. Points are distributed in the tree only when BuildPoints is called
+func (n *KdNode) AddDataPoint(point *data.Point) {
+	if point == nil {
+		return
+	}
+
+	n.Lock()
+	n.points = append(n.points, point)
+	n.totalNumberOfPoints++
+	n.Unlock()
+}
+
+// Shrinks the bounding box to the points added to the node, then keeps a sample of them and splits the remaining ones
+// among the children, recursively building them
+func (n *KdNode) BuildPoints() {
+	points := n.points
+	n.totalNumberOfPoints = int64(len(points))
+	n.initialized = true
+	if len(points) > 0 {
+		n.BoundingBox = getPointsBoundingBox(points)
+	}
+
+	maxNumberOfPoints := int(n.opts.MaxNumPointsPerNode)
+	if maxNumberOfPoints <= 0 || len(points) <= maxNumberOfPoints {
+		n.numberOfPoints = int32(len(points))
+		return
+	}
+
+	// moves a random sample of the points at the beginning of the slice
+	random := rand.New(rand.NewSource(n.seed))
+	for i := 0; i < maxNumberOfPoints; i++ {
+		j := i + random.Intn(len(points)-i)
+		points[i], points[j] = points[j], points[i]
+	}
+	n.points = points[:maxNumberOfPoints:maxNumberOfPoints]
+	n.numberOfPoints = int32(maxNumberOfPoints)
+
+	n.initializeChildren(points[maxNumberOfPoints:])
+
+	if n.depth < parallelBuildDepth {
+		var wg sync.WaitGroup
+		for _, child := range n.children[:2] {
+			wg.Add(1)
+			go func(child *KdNode) {
+				child.BuildPoints()
+				wg.Done()
+			}(child.(*KdNode))
+		}
+		wg.Wait()
+	} else {
+		for _, child := range n.children[:2] {
+			child.(*KdNode).BuildPoints()
+		}
+	}
+}
+
+func (n *KdNode) GetChildren() [8]octree.INode {
+	return n.children
+}
+
+func (n *KdNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *KdNode) TotalNumberOfPoints() int64 {
+	return n.totalNumberOfPoints
+}
+
+func (n *KdNode) NumberOfPoints() int32 {
+	return n.numberOfPoints
+}
+
+func (n *KdNode) IsLeaf() bool {
+	return n.leaf
+}
+
+func (n *KdNode) IsInitialized() bool {
+	return n.initialized
+}
+
+func (n *KdNode) IsRoot() bool {
+	return n.root
+}
+
+func (n *KdNode) GetParent() octree.INode {
+	return n.parent
+}
+
+func (n *KdNode) IsAdditive() bool {
+	return true
+}
+
+// Computes the geometric error for the given KdNode
+func (n *KdNode) ComputeGeometricError() float64 {
+	var w = math.Abs(n.BoundingBox.Xmax - n.BoundingBox.Xmin)
+	var l = math.Abs(n.BoundingBox.Ymax - n.BoundingBox.Ymin)
+	var h = math.Abs(n.BoundingBox.Zmax - n.BoundingBox.Zmin)
+	diagonal := math.Sqrt(w*w + l*l + h*h)
+	if n.IsRoot() || n.parent == nil {
+		return diagonal
+	}
+
+	geometricError := diagonal
+	if n.numberOfPoints > 0 {
+		// the average spacing of the points is estimated assuming that they are spread on a surface, which is the
+		// common case for lidar clouds and overestimates the spacing of volumetric clouds, and doubled as done for
+		// grid cells
+		geometricError = diagonal / math.Sqrt(float64(n.numberOfPoints)) * 2
+	}
+
+	// a median split barely shrinks the diagonal, hence a sparse node could get a larger error than its parent, while
+	// the error must not grow while refining
+	return math.Min(geometricError, n.parent.ComputeGeometricError())
+}
+
+// sorts the given points along the longest axis of their bounding box and assigns each half to a new child
+func (n *KdNode) initializeChildren(points []*data.Point) {
+	axis := getLongestAxis(getPointsBoundingBox(points))
+	sort.Slice(points, func(i, j int) bool {
+		return axis(points[i]) < axis(points[j])
+	})
+
+	median := len(points) / 2
+	for i, half := range [][]*data.Point{points[:median:median], points[median:]} {
+		child := NewKdNode(n, n.Frame, n.BoundingBox, n.opts, false).(*KdNode)
+		child.points = half
+		// each node samples its points with its own generator, as children are built in parallel
+		child.seed = n.seed*2 + int64(i) + 1
+		n.children[i] = child
+	}
+	n.leaf = false
+}
+
+// returns the tightest bounding box enclosing the given points
+func getPointsBoundingBox(points []*data.Point) *geometry.BoundingBox {
+	xMin, yMin, zMin := math.Inf(1), math.Inf(1), math.Inf(1)
+	xMax, yMax, zMax := math.Inf(-1), math.Inf(-1), math.Inf(-1)
+	for _, point := range points {
+		xMin = math.Min(xMin, point.X)
+		xMax = math.Max(xMax, point.X)
+		yMin = math.Min(yMin, point.Y)
+		yMax = math.Max(yMax, point.Y)
+		zMin = math.Min(zMin, point.Z)
+		zMax = math.Max(zMax, point.Z)
+	}
+
+	return geometry.NewBoundingBox(xMin, xMax, yMin, yMax, zMin, zMax)
+}
+
+// returns a function extracting from a point the coordinate along the longest axis of the given bounding box
+func getLongestAxis(bbox *geometry.BoundingBox) func(point *data.Point) float64 {
+	w := bbox.Xmax - bbox.Xmin
+	l := bbox.Ymax - bbox.Ymin
+	h := bbox.Zmax - bbox.Zmin
+	if w >= l && w >= h {
+		return func(point *data.Point) float64 { return point.X }
+	}
+	if l >= h {
+		return func(point *data.Point) float64 { return point.Y }
+	}
+	return func(point *data.Point) float64 { return point.Z }
+}
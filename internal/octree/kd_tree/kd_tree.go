@@ -0,0 +1,25 @@
+package kd_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+)
+
+// Represents a balanced KdTree of points. Nodes are split in two along the median of the longest axis of their points,
+// so that all tiles hold about the max number of points per node even if the density of the cloud is very uneven.
+// Points are stored in a local East-North-Up frame, so that bounding boxes are expressed in true meters
+type KdTree struct {
+	*octree.LocalFrameTree
+}
+
+// Builds an empty KdTree initializing its properties to the correct defaults
+func NewKdTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &KdTree{
+		LocalFrameTree: octree.NewLocalFrameTree(point_loader.NewSequentialLoader(), opts, coordinateConverter, elevationCorrector, func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) octree.INode {
+			return NewKdNode(nil, frame, boundingBox, opts, true)
+		}),
+	}
+}
@@ -0,0 +1,25 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+)
+
+// Wraps the given loader with the noise and outlier filters and the height above ground and curvature computations
+// enabled in the options. They use distances in meters, hence they can be applied only to the points of the trees that
+// work in a local frame. Heights above ground and curvatures are computed on the points that pass the noise filters
+func NewLocalFrameLoader(loader point_loader.Loader, opts *tiler.TilerOptions) point_loader.Loader {
+	if opts.Curvature {
+		loader = point_loader.NewCurvatureLoader(loader, opts.CurvatureRadius)
+	}
+	if opts.HeightAboveGround {
+		heightAboveGroundLoader := point_loader.NewHeightAboveGroundLoader(loader, opts.GroundCellSize)
+		if opts.ColorMode == colors.ModeHeightAboveGround {
+			heightAboveGroundLoader.SetColorRamp(opts.ColorRamp, opts.ColorMin, opts.ColorMax)
+		}
+		loader = heightAboveGroundLoader
+	}
+	loader = point_loader.NewNoiseFilterLoader(loader, opts.NoiseRadius, opts.NoiseMinNeighbours, opts.NoiseKNearest, opts.NoiseMaxMeanDistance)
+	return point_loader.NewRobustBoundsLoader(loader, opts.BoundsPercentile, opts.BoundsSigma)
+}
@@ -0,0 +1,30 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+)
+
+// Bounding box of a node whose points are stored in a local frame, implementing the methods of INode that convert it
+// and the points to WGS84. Conversions from the local frame do not need the coordinate converter
+type LocalFrameNode struct {
+	Frame       *geometry.LocalFrame
+	BoundingBox *geometry.BoundingBox
+}
+
+func (n *LocalFrameNode) GetBoundingBox() *geometry.BoundingBox {
+	return n.BoundingBox
+}
+
+func (n *LocalFrameNode) SetBoundingBox(boundingBox *geometry.BoundingBox) {
+	n.BoundingBox = boundingBox
+}
+
+// Returns the WGS84 region enclosing the node bounding box
+func (n *LocalFrameNode) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return n.Frame.BoundingBoxToWGS84Region(n.BoundingBox), nil
+}
+
+func (n *LocalFrameNode) ToWGS84Cartesian(coordinate geometry.Coordinate, converter converters.CoordinateConverter) (geometry.Coordinate, error) {
+	return n.Frame.ToGeocentric(coordinate), nil
+}
@@ -0,0 +1,85 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"log"
+	"sync"
+)
+
+// Builds the points stored by the trees that work in a local East-North-Up frame. The frame is anchored at the first
+// point built unless it has been explicitly anchored before. If the frame is explicitly placed the coordinates of the
+// points are taken as already expressed in it
+type LocalFramePointFactory struct {
+	frame               *geometry.LocalFrame
+	frameOnce           sync.Once
+	placed              bool
+	coordinateConverter converters.CoordinateConverter
+	elevationCorrector  converters.ElevationCorrector
+}
+
+// Instantiates a new LocalFramePointFactory
+func NewLocalFramePointFactory(coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) *LocalFramePointFactory {
+	return &LocalFramePointFactory{
+		coordinateConverter: coordinateConverter,
+		elevationCorrector:  elevationCorrector,
+	}
+}
+
+// Anchors the local frame at the given coordinate. Has no effect if the frame has already been anchored
+func (f *LocalFramePointFactory) SetFrameOrigin(coordinate *geometry.Coordinate, srid int) {
+	wgs84coords, err := f.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f.getFrame(wgs84coords)
+}
+
+// Sets the local frame the coordinates of the points are expressed in, which are then stored without any conversion
+// except for the elevation correction. Has no effect if the frame has already been anchored
+func (f *LocalFramePointFactory) SetPlacement(frame *geometry.LocalFrame) {
+	f.frameOnce.Do(func() {
+		f.frame = frame
+		f.placed = true
+	})
+}
+
+// Returns the local frame, nil if it has not been anchored yet
+func (f *LocalFramePointFactory) GetFrame() *geometry.LocalFrame {
+	return f.frame
+}
+
+// Builds a point in the local frame from the given raw data, applying the elevation correction
+func (f *LocalFramePointFactory) NewPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, attributes []float32, srid int) *data.Point {
+	if f.placed {
+		z := f.elevationCorrector.CorrectElevation(coordinate.X, coordinate.Y, coordinate.Z)
+		point := data.NewPoint(coordinate.X, coordinate.Y, z, r, g, b, intensity, classification)
+		point.Attributes = attributes
+		return point
+	}
+
+	wgs84coords, err := f.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wgs84coords.Z = f.elevationCorrector.CorrectElevation(wgs84coords.X, wgs84coords.Y, wgs84coords.Z)
+
+	localCoords := f.getFrame(wgs84coords).FromGeodetic(wgs84coords.X, wgs84coords.Y, wgs84coords.Z)
+
+	point := data.NewPoint(localCoords.X, localCoords.Y, localCoords.Z, r, g, b, intensity, classification)
+	point.Attributes = attributes
+
+	return point
+}
+
+// returns the local frame, anchoring it at the given WGS84 coordinate if it has not been anchored yet
+func (f *LocalFramePointFactory) getFrame(origin geometry.Coordinate) *geometry.LocalFrame {
+	f.frameOnce.Do(func() {
+		f.frame = geometry.NewLocalFrame(origin.X, origin.Y, origin.Z)
+	})
+
+	return f.frame
+}
@@ -0,0 +1,146 @@
+package octree
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"runtime"
+	"sync"
+)
+
+// Hard limit to the depth of the trees, used if no max depth is specified. Without it a cloud with more points than
+// the max number of points per node sharing the same position would lead to an endless subdivision
+const depthLimit = 64
+
+// Implemented by the root nodes that finalize the points stored in the tree once all of them have been added
+type IPointsBuilder interface {
+	BuildPoints()
+}
+
+// Common implementation of the trees that store points in a local East-North-Up frame, so that sizes are expressed in
+// true meters regardless of the position of the cloud on Earth. The root node is created for the bounds of the
+// points once all of them have been loaded
+type LocalFrameTree struct {
+	rootNode     INode
+	built        bool
+	opts         *tiler.TilerOptions
+	pointFactory *LocalFramePointFactory
+	newRootNode  func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) INode
+	point_loader.Loader
+}
+
+// Instantiates a LocalFrameTree whose points are stored by the given loader, wrapped with the filters enabled in the
+// options, and whose root node is created by newRootNode
+func NewLocalFrameTree(loader point_loader.Loader, opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector, newRootNode func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) INode) *LocalFrameTree {
+	return &LocalFrameTree{
+		built:        false,
+		opts:         opts,
+		Loader:       NewLocalFrameLoader(loader, opts),
+		pointFactory: NewLocalFramePointFactory(coordinateConverter, elevationCorrector),
+		newRootNode:  newRootNode,
+	}
+}
+
+// Builds the hierarchical tree structure adding all the points to the root node and then finalizing the points
+// stored in the nodes
+func (tree *LocalFrameTree) Build() error {
+	return tree.BuildWith(func(root INode) {
+		LoadPoints(tree.Loader, root, tree.opts.Deterministic)
+		root.(IPointsBuilder).BuildPoints()
+	})
+}
+
+// Builds the hierarchical tree structure creating the root node and passing it to build, which must add the points
+// retrieved from the loader to it
+func (tree *LocalFrameTree) BuildWith(build func(root INode)) error {
+	if tree.built {
+		return errors.New("octree already built")
+	}
+
+	box := tree.GetBounds()
+	tree.rootNode = tree.newRootNode(tree.pointFactory.GetFrame(), geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5]))
+	tree.InitializeLoader()
+	build(tree.rootNode)
+	if tree.opts.BoundsPercentile > 0 {
+		EnclosePoints(tree.rootNode)
+	}
+	tree.built = true
+
+	return nil
+}
+
+func (tree *LocalFrameTree) GetRootNode() INode {
+	return tree.rootNode
+}
+
+func (tree *LocalFrameTree) IsBuilt() bool {
+	return tree.built
+}
+
+func (tree *LocalFrameTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, attributes []float32, srid int) {
+	tree.Loader.AddPoint(tree.pointFactory.NewPoint(coordinate, r, g, b, intensity, classification, attributes, srid))
+}
+
+// Anchors the local frame of the tree at the given coordinate. Has no effect if points have already been added
+func (tree *LocalFrameTree) SetFrameOrigin(coordinate *geometry.Coordinate, srid int) {
+	tree.pointFactory.SetFrameOrigin(coordinate, srid)
+}
+
+// Sets the local frame the coordinates of the points are expressed in. Has no effect if points have already been added
+func (tree *LocalFrameTree) SetPlacement(frame *geometry.LocalFrame) {
+	tree.pointFactory.SetPlacement(frame)
+}
+
+// Adds all the points of the loader to the given node, using a goroutine per CPU or, in deterministic mode, a single
+// one adding the points in the order they have been read
+func LoadPoints(loader point_loader.Loader, node INode, deterministic bool) {
+	N := runtime.NumCPU()
+	if deterministic {
+		N = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				val, shouldContinue := loader.GetNext()
+				if val != nil {
+					node.AddDataPoint(val)
+				}
+				if !shouldContinue {
+					break
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Returns the max depth of the nodes of a tree according to the given options
+func GetMaxDepth(opts *tiler.TilerOptions) int {
+	if opts.GridMaxDepth <= 0 || opts.GridMaxDepth > depthLimit {
+		return depthLimit
+	}
+	return opts.GridMaxDepth
+}
+
+// Returns the index of the octant of the given bounding box that contains the given point. Points lying on a mid
+// plane belong to the lower octant
+func GetOctant(point *data.Point, bbox *geometry.BoundingBox) uint8 {
+	var result uint8 = 0
+	if point.X > bbox.Xmid {
+		result += 1
+	}
+	if point.Y > bbox.Ymid {
+		result += 2
+	}
+	if point.Z > bbox.Zmid {
+		result += 4
+	}
+	return result
+}
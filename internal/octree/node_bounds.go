@@ -0,0 +1,40 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+)
+
+// Extends the bounding boxes of the given node and of its descendants so that each one encloses the points of the
+// node and the bounding boxes of its children, and returns the one of the given node. Trees whose root bounds exclude
+// the outliers still store them, in the nodes closest to them, whose bounding volumes must enclose them. The nodes
+// whose bounding box must be extended have to implement IBoundingBoxSetter
+func EnclosePoints(node INode) *geometry.BoundingBox {
+	box := node.GetBoundingBox()
+	bounds := [6]float64{box.Xmin, box.Xmax, box.Ymin, box.Ymax, box.Zmin, box.Zmax}
+	extended := false
+	extend := func(minX, maxX, minY, maxY, minZ, maxZ float64) {
+		for i, value := range [6]float64{minX, maxX, minY, maxY, minZ, maxZ} {
+			if (i%2 == 0 && value < bounds[i]) || (i%2 == 1 && value > bounds[i]) {
+				bounds[i] = value
+				extended = true
+			}
+		}
+	}
+
+	for _, point := range node.GetPoints() {
+		extend(point.X, point.X, point.Y, point.Y, point.Z, point.Z)
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			childBox := EnclosePoints(child)
+			extend(childBox.Xmin, childBox.Xmax, childBox.Ymin, childBox.Ymax, childBox.Zmin, childBox.Zmax)
+		}
+	}
+
+	if !extended {
+		return box
+	}
+	box = geometry.NewBoundingBox(bounds[0], bounds[1], bounds[2], bounds[3], bounds[4], bounds[5])
+	node.(IBoundingBoxSetter).SetBoundingBox(box)
+	return box
+}
@@ -0,0 +1,237 @@
+package poisson_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Models a node of the octree, which can either be a leaf (a node without children nodes) or not.
+// Each Node can contain up to eight children nodes. A node accepts a point only if no point already stored is closer
+// than its spacing, propagating the rejected points to its children, whose spacing is half of the parent one. Nodes
+// with a spacing below the min cell size store all the points until they reach the max number of points allowed,
+// nodes at the max depth allowed store all the points they receive.
+type PoissonNode struct {
+	root   bool
+	parent octree.INode
+	octree.LocalFrameNode
+	children            [8]octree.INode
+	cells               map[cellIndex][]*data.Point
+	points              []*data.Point
+	spacing             float64
+	depth               int
+	maxDepth            int
+	opts                *tiler.TilerOptions
+	totalNumberOfPoints int64
+	numberOfPoints      int32
+	leaf                int32
+	initialized         bool
+	sync.RWMutex
+}
+
+// struct used to index the points stored in a node in a grid of cells whose size equals the node spacing, so that
+// the points closer than the spacing to a given one can only be in the cell where it falls or in the adjacent ones
+type cellIndex struct {
+	x int
+	y int
+	z int
+}
+
+// Instantiates a new PoissonNode. The bounding box and the points are expressed in the given local frame
+func NewPoissonNode(parent octree.INode, frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox, spacing float64, opts *tiler.TilerOptions, root bool) octree.INode {
+	depth := 0
+	if parent != nil {
+		depth = parent.(*PoissonNode).depth + 1
+	}
+
+	node := PoissonNode{
+		root:           root,
+		parent:         parent,
+		LocalFrameNode: octree.LocalFrameNode{Frame: frame, BoundingBox: boundingBox},
+		cells:          make(map[cellIndex][]*data.Point),
+		points:         make([]*data.Point, 0),
+		spacing:        spacing,
+		depth:          depth,
+		maxDepth:       octree.GetMaxDepth(opts),
+		opts:           opts,
+		leaf:           1,
+		initialized:    false,
+	}
+
+	return &node
+}
+
+// Adds a Point to the PoissonNode, propagating it to the children if the node rejects it
+func (n *PoissonNode) AddDataPoint(point *data.Point) {
+	if point == nil {
+		return
+	}
+
+	if atomic.LoadInt32(&n.numberOfPoints) == 0 {
+		n.initializeChildren()
+	}
+
+	if !n.storePoint(point) {
+		n.children[octree.GetOctant(point, n.BoundingBox)].AddDataPoint(point)
+		atomic.StoreInt32(&n.leaf, 0)
+	}
+
+	atomic.AddInt64(&n.totalNumberOfPoints, 1)
+}
+
+// Releases the spatial index used to check the spacing between points, which is not needed once all points are added
+func (n *PoissonNode) BuildPoints() {
+	n.cells = nil
+
+	for _, child := range n.children {
+		if child != nil {
+			child.(*PoissonNode).BuildPoints()
+		}
+	}
+}
+
+func (n *PoissonNode) GetChildren() [8]octree.INode {
+	return n.children
+}
+
+func (n *PoissonNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *PoissonNode) TotalNumberOfPoints() int64 {
+	return n.totalNumberOfPoints
+}
+
+func (n *PoissonNode) NumberOfPoints() int32 {
+	return n.numberOfPoints
+}
+
+func (n *PoissonNode) IsLeaf() bool {
+	return atomic.LoadInt32(&n.leaf) == 1
+}
+
+func (n *PoissonNode) IsInitialized() bool {
+	return n.initialized
+}
+
+func (n *PoissonNode) IsRoot() bool {
+	return n.root
+}
+
+func (n *PoissonNode) GetParent() octree.INode {
+	return n.parent
+}
+
+func (n *PoissonNode) IsAdditive() bool {
+	return true
+}
+
+// Computes the geometric error for the given PoissonNode
+func (n *PoissonNode) ComputeGeometricError() float64 {
+	if n.IsRoot() {
+		var w = math.Abs(n.BoundingBox.Xmax - n.BoundingBox.Xmin)
+		var l = math.Abs(n.BoundingBox.Ymax - n.BoundingBox.Ymin)
+		var h = math.Abs(n.BoundingBox.Zmax - n.BoundingBox.Zmin)
+		return math.Sqrt(w*w + l*l + h*h)
+	}
+	// estimated as for a grid node with cells as large as the spacing, which stores points with a comparable density
+	return n.spacing * math.Sqrt(3) * 2
+}
+
+// stores the point in the node if it satisfies the node constraints, returning false if the point has been rejected
+func (n *PoissonNode) storePoint(point *data.Point) bool {
+	if n.depth >= n.maxDepth {
+		n.Lock()
+		n.appendPoint(point)
+		n.Unlock()
+		return true
+	}
+
+	if n.spacing < n.opts.CellMinSize {
+		n.Lock()
+		defer n.Unlock()
+		if n.isFull() {
+			return false
+		}
+		n.appendPoint(point)
+		return true
+	}
+
+	index := getCellIndex(point, n.spacing)
+
+	// most points are rejected once the node is saturated, hence a read lock is tried first
+	n.RLock()
+	rejected := n.isFull() || n.hasPointsWithinSpacing(point, index)
+	n.RUnlock()
+	if rejected {
+		return false
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	if n.isFull() || n.hasPointsWithinSpacing(point, index) {
+		return false
+	}
+	n.cells[index] = append(n.cells[index], point)
+	n.appendPoint(point)
+
+	return true
+}
+
+// appends a point to the node points. Must be called with the node lock held
+func (n *PoissonNode) appendPoint(point *data.Point) {
+	n.points = append(n.points, point)
+	atomic.AddInt32(&n.numberOfPoints, 1)
+}
+
+// checks if the node stores the max number of points allowed
+func (n *PoissonNode) isFull() bool {
+	return n.opts.GridMaxNumPointsPerNode > 0 && atomic.LoadInt32(&n.numberOfPoints) >= n.opts.GridMaxNumPointsPerNode
+}
+
+// checks if any point stored in the cell with the given index or in the adjacent ones is closer than the spacing
+// to the given point. Must be called with the node lock held
+func (n *PoissonNode) hasPointsWithinSpacing(point *data.Point, index cellIndex) bool {
+	squaredSpacing := n.spacing * n.spacing
+	for x := index.x - 1; x <= index.x+1; x++ {
+		for y := index.y - 1; y <= index.y+1; y++ {
+			for z := index.z - 1; z <= index.z+1; z++ {
+				for _, other := range n.cells[cellIndex{x, y, z}] {
+					dx := other.X - point.X
+					dy := other.Y - point.Y
+					dz := other.Z - point.Z
+					if dx*dx+dy*dy+dz*dz < squaredSpacing {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// initializes the children to new empty nodes. Nodes at the max depth have no children
+func (n *PoissonNode) initializeChildren() {
+	n.Lock()
+	for i := uint8(0); i < 8 && n.depth < n.maxDepth; i++ {
+		if n.children[i] == nil {
+			n.children[i] = NewPoissonNode(n, n.Frame, geometry.NewBoundingBoxFromParent(n.BoundingBox, &i), n.spacing/2.0, n.opts, false)
+		}
+	}
+	n.initialized = true
+	n.Unlock()
+}
+
+// returns the index of the cell of the given size where the given point falls into
+func getCellIndex(point *data.Point, size float64) cellIndex {
+	return cellIndex{
+		x: int(math.Floor(point.X / size)),
+		y: int(math.Floor(point.Y / size)),
+		z: int(math.Floor(point.Z / size)),
+	}
+}
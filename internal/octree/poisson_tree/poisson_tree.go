@@ -0,0 +1,26 @@
+package poisson_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+)
+
+// Represents a PoissonTree of points. Each node stores a Poisson-disk sample of the points it receives, in which no two
+// points are closer than the node spacing, and propagates the other points to its children whose spacing is halved.
+// Points are submitted in random order and are stored in a local East-North-Up frame, so that spacings are expressed
+// in true meters regardless of the position of the cloud on Earth
+type PoissonTree struct {
+	*octree.LocalFrameTree
+}
+
+// Builds an empty PoissonTree initializing its properties to the correct defaults
+func NewPoissonTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &PoissonTree{
+		LocalFrameTree: octree.NewLocalFrameTree(point_loader.NewRandomLoader(opts.Seed), opts, coordinateConverter, elevationCorrector, func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) octree.INode {
+			return NewPoissonNode(nil, frame, boundingBox, opts.CellMaxSize, opts, true)
+		}),
+	}
+}
@@ -63,7 +63,7 @@ func (n *RandomNode) AddDataPoint(element *data.Point) {
 		atomic.AddInt32(&n.numberOfPoints, 1)
 		n.Unlock()
 	} else {
-		n.children[getOctantFromElement(element, n.boundingBox)].AddDataPoint(element)
+		n.children[octree.GetOctant(element, n.boundingBox)].AddDataPoint(element)
 		if n.leaf {
 			n.Lock()
 			n.leaf = false
@@ -77,6 +77,10 @@ func (n *RandomNode) GetParent() octree.INode {
 	return n.parent
 }
 
+func (n *RandomNode) IsAdditive() bool {
+	return true
+}
+
 func (n *RandomNode) GetInternalSrid() int {
 	return n.internalSrid
 }
@@ -91,10 +95,18 @@ func (n *RandomNode) GetBoundingBoxRegion(converter converters.CoordinateConvert
 	return reg, nil
 }
 
+func (n *RandomNode) ToWGS84Cartesian(coordinate geometry.Coordinate, converter converters.CoordinateConverter) (geometry.Coordinate, error) {
+	return converter.ConvertToWGS84Cartesian(coordinate, n.GetInternalSrid())
+}
+
 func (n *RandomNode) GetBoundingBox() *geometry.BoundingBox {
 	return n.boundingBox
 }
 
+func (n *RandomNode) SetBoundingBox(boundingBox *geometry.BoundingBox) {
+	n.boundingBox = boundingBox
+}
+
 func (n *RandomNode) GetChildren() [8]octree.INode {
 	return n.children
 }
@@ -123,21 +135,6 @@ func (n *RandomNode) IsInitialized() bool {
 	return n.initialized
 }
 
-// Returns the index of the octant that contains the given Point within this boundingBox
-func getOctantFromElement(element *data.Point, bbox *geometry.BoundingBox) uint8 {
-	var result uint8 = 0
-	if float64(element.X) > bbox.Xmid {
-		result += 1
-	}
-	if float64(element.Y) > bbox.Ymid {
-		result += 2
-	}
-	if float64(element.Z) > bbox.Zmid {
-		result += 4
-	}
-	return result
-}
-
 // Returns a bounding box from the given box and the given octant index
 func getOctantBoundingBox(octant *uint8, bbox *geometry.BoundingBox) *geometry.BoundingBox {
 	return geometry.NewBoundingBoxFromParent(bbox, octant)
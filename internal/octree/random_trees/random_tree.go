@@ -9,8 +9,6 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"log"
-	"runtime"
-	"sync"
 )
 
 // Represents an RandomTree of points and contains all information needed
@@ -29,7 +27,7 @@ func NewRandomTree(opts *tiler.TilerOptions, coordinateConverter converters.Coor
 	return &RandomTree{
 		built:               false,
 		opts:                opts,
-		Loader:              point_loader.NewRandomLoader(),
+		Loader:              point_loader.NewRobustBoundsLoader(point_loader.NewRandomLoader(opts.Seed), opts.BoundsPercentile, opts.BoundsSigma),
 		coordinateConverter: coordinateConverter,
 		elevationCorrector:  elevationCorrector,
 	}
@@ -39,7 +37,7 @@ func NewBoxedRandomTree(opts *tiler.TilerOptions, coordinateConverter converters
 	return &RandomTree{
 		built:               false,
 		opts:                opts,
-		Loader:              point_loader.NewRandomBoxLoader(),
+		Loader:              point_loader.NewRobustBoundsLoader(point_loader.NewRandomBoxLoader(opts.Seed), opts.BoundsPercentile, opts.BoundsSigma),
 		coordinateConverter: coordinateConverter,
 		elevationCorrector:  elevationCorrector,
 	}
@@ -54,9 +52,10 @@ func (t *RandomTree) Build() error {
 
 	t.init()
 
-	var wg sync.WaitGroup
-	t.launchParallelPointLoaders(&wg)
-	wg.Wait()
+	octree.LoadPoints(t.Loader, t.rootNode, t.opts.Deterministic)
+	if t.opts.BoundsPercentile > 0 {
+		octree.EnclosePoints(t.rootNode)
+	}
 
 	t.built = true
 
@@ -70,28 +69,6 @@ func (t *RandomTree) init() {
 	t.InitializeLoader()
 }
 
-func (t *RandomTree) launchParallelPointLoaders(waitGroup *sync.WaitGroup) {
-	N := runtime.NumCPU()
-
-	for i := 0; i < N; i++ {
-		waitGroup.Add(1)
-		go t.launchPointLoader(waitGroup)
-	}
-}
-
-func (t *RandomTree) launchPointLoader(waitGroup *sync.WaitGroup) {
-	for {
-		val, shouldContinue := t.Loader.GetNext()
-		if val != nil {
-			t.rootNode.AddDataPoint(val)
-		}
-		if !shouldContinue {
-			break
-		}
-	}
-	waitGroup.Done()
-}
-
 func (t *RandomTree) GetRootNode() octree.INode {
 	return t.rootNode
 }
@@ -100,15 +77,18 @@ func (t *RandomTree) IsBuilt() bool {
 	return t.built
 }
 
-func (t *RandomTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) {
-	t.Loader.AddPoint(t.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid))
+func (t *RandomTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, attributes []float32, srid int) {
+	t.Loader.AddPoint(t.getPointFromRawData(coordinate, r, g, b, intensity, classification, attributes, srid))
 }
 
-func (t *RandomTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) *data.Point {
+func (t *RandomTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, attributes []float32, srid int) *data.Point {
 	tr, err := t.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return data.NewPoint(tr.X, tr.Y, t.elevationCorrector.CorrectElevation(tr.X, tr.Y, tr.Z), r, g, b, intensity, classification)
+	point := data.NewPoint(tr.X, tr.Y, t.elevationCorrector.CorrectElevation(tr.X, tr.Y, tr.Z), r, g, b, intensity, classification)
+	point.Attributes = attributes
+
+	return point
 }
@@ -11,14 +11,36 @@ type ITree interface {
 	GetRootNode() INode
 	IsBuilt() bool
 	// Adds a Point to the Tree
-	AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int)
+	AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, attributes []float32, srid int)
+}
+
+// Implemented by the trees that store points in a local frame. Anchoring the frame at the centre of the cloud, before
+// adding any point, keeps its vertical axis close to the local vertical everywhere in the cloud
+type ILocalFrameTree interface {
+	// Anchors the local frame at the given coordinate. Has no effect if called after the first point has been added
+	SetFrameOrigin(coordinate *geometry.Coordinate, srid int)
+	// Sets the local frame the coordinates of the points are expressed in, skipping their srid conversion. Has no
+	// effect if called after the first point has been added
+	SetPlacement(frame *geometry.LocalFrame)
+}
+
+// Implemented by the trees that can discard outlier points when computing their bounds
+type IOutlierRejectingTree interface {
+	// Returns the number of points discarded as outliers while building the tree
+	GetNumberOfRejectedPoints() int64
+}
+
+// Implemented by the nodes whose bounding box can be replaced once the tree is built
+type IBoundingBoxSetter interface {
+	SetBoundingBox(boundingBox *geometry.BoundingBox)
 }
 
 type INode interface {
 	AddDataPoint(element *data.Point)
-	GetInternalSrid() int
 	IsRoot() bool
 	GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error)
+	// Converts a coordinate of a point stored in the node to EPSG:4978
+	ToWGS84Cartesian(coordinate geometry.Coordinate, converter converters.CoordinateConverter) (geometry.Coordinate, error)
 	GetChildren() [8]INode
 	GetPoints() []*data.Point
 	TotalNumberOfPoints() int64
@@ -28,4 +50,7 @@ type INode interface {
 	ComputeGeometricError() float64
 	GetParent() INode
 	GetBoundingBox() *geometry.BoundingBox
+	// Returns true if the points of the node complement the ones of its ancestors, false if they are a full
+	// representation of the node volume that replaces the ancestors content, as when they are synthesized
+	IsAdditive() bool
 }
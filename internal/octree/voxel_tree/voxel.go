@@ -0,0 +1,126 @@
+package voxel_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"sort"
+)
+
+// struct used to store the unique index of a voxel as a unique combination of 3 int values
+type voxelIndex struct {
+	x int
+	y int
+	z int
+}
+
+// returns the index of the voxel of the given size where the given point falls into
+func getVoxelIndex(point *data.Point, size float64) voxelIndex {
+	return voxelIndex{
+		x: int(math.Floor(point.X / size)),
+		y: int(math.Floor(point.Y / size)),
+		z: int(math.Floor(point.Z / size)),
+	}
+}
+
+// groups the given points by the voxel of the given size they fall into
+func groupInVoxels(points []*data.Point, size float64) map[voxelIndex][]*data.Point {
+	voxels := make(map[voxelIndex][]*data.Point)
+	for _, point := range points {
+		index := getVoxelIndex(point, size)
+		voxels[index] = append(voxels[index], point)
+	}
+
+	return voxels
+}
+
+// returns the indexes of the given voxels, sorted if sorted is true, in map iteration order otherwise
+func getVoxelIndexes(voxels map[voxelIndex][]*data.Point, sorted bool) []voxelIndex {
+	indexes := make([]voxelIndex, 0, len(voxels))
+	for index := range voxels {
+		indexes = append(indexes, index)
+	}
+	if sorted {
+		sort.Slice(indexes, func(i, j int) bool {
+			a, b := indexes[i], indexes[j]
+			if a.x != b.x {
+				return a.x < b.x
+			}
+			if a.y != b.y {
+				return a.y < b.y
+			}
+			return a.z < b.z
+		})
+	}
+
+	return indexes
+}
+
+// synthesizes a point representing the given points, placed in their centroid, with their average color, intensity and
+// attributes and their most frequent classification. Ties between classifications are resolved in favour of the lowest code
+func synthesizePoint(points []*data.Point) *data.Point {
+	if len(points) == 1 {
+		return points[0]
+	}
+
+	var x, y, z float64
+	var r, g, b, intensity int
+	var classificationCounts [256]int
+	for _, point := range points {
+		x += point.X
+		y += point.Y
+		z += point.Z
+		r += int(point.R)
+		g += int(point.G)
+		b += int(point.B)
+		intensity += int(point.Intensity)
+		classificationCounts[point.Classification]++
+	}
+
+	classification := 0
+	for i, count := range classificationCounts {
+		if count > classificationCounts[classification] {
+			classification = i
+		}
+	}
+
+	n := float64(len(points))
+	point := data.NewPoint(
+		x/n,
+		y/n,
+		z/n,
+		averageUint8(r, len(points)),
+		averageUint8(g, len(points)),
+		averageUint8(b, len(points)),
+		averageUint8(intensity, len(points)),
+		uint8(classification),
+	)
+	point.Attributes = averageAttributes(points)
+
+	return point
+}
+
+// returns the average of the attributes of the given points, nil if they have none
+func averageAttributes(points []*data.Point) []float32 {
+	if len(points[0].Attributes) == 0 {
+		return nil
+	}
+
+	sums := make([]float64, len(points[0].Attributes))
+	for _, point := range points {
+		for i, attribute := range point.Attributes {
+			sums[i] += float64(attribute)
+		}
+	}
+
+	attributes := make([]float32, len(sums))
+	for i, sum := range sums {
+		attributes[i] = float32(sum / float64(len(points)))
+	}
+
+	return attributes
+}
+
+// returns the rounded average of the given sum of uint8 values
+func averageUint8(sum int, count int) uint8 {
+	return uint8(math.Round(float64(sum) / float64(count)))
+}
@@ -0,0 +1,182 @@
+package voxel_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sync"
+)
+
+// nodes up to this depth build their children in parallel
+const parallelBuildDepth = 2
+
+// Models a node of the octree, which can either be a leaf (a node without children nodes) or not.
+// Each Node can contain up to eight children nodes. A non leaf node divides its bounding box in voxels and stores one
+// synthesized point per voxel, passing all the original points to its children. Leaf nodes store the original points.
+// A node is a leaf if its voxels contain at most one point each or are smaller than the min cell size, as long as
+// it does not exceed the max number of points, or if it is at the max depth allowed.
+type VoxelNode struct {
+	root   bool
+	parent octree.INode
+	octree.LocalFrameNode
+	children            [8]octree.INode
+	points              []*data.Point
+	cellSize            float64
+	depth               int
+	maxDepth            int
+	opts                *tiler.TilerOptions
+	totalNumberOfPoints int64
+	numberOfPoints      int32
+	leaf                bool
+	initialized         bool
+	sync.RWMutex
+}
+
+// Instantiates a new VoxelNode. The bounding box and the points are expressed in the given local frame
+func NewVoxelNode(parent octree.INode, frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox, cellSize float64, opts *tiler.TilerOptions, root bool) octree.INode {
+	depth := 0
+	if parent != nil {
+		depth = parent.(*VoxelNode).depth + 1
+	}
+
+	node := VoxelNode{
+		root:           root,
+		parent:         parent,
+		LocalFrameNode: octree.LocalFrameNode{Frame: frame, BoundingBox: boundingBox},
+		points:         make([]*data.Point, 0),
+		cellSize:       cellSize,
+		depth:          depth,
+		maxDepth:       octree.GetMaxDepth(opts),
+		opts:           opts,
+		leaf:           true,
+		initialized:    false,
+	}
+
+	return &node
+}
+
+// Adds a Point to the VoxelNode. Points are distributed in the tree only when BuildPoints is called
+func (n *VoxelNode) AddDataPoint(point *data.Point) {
+	if point == nil {
+		return
+	}
+
+	n.Lock()
+	n.points = append(n.points, point)
+	n.totalNumberOfPoints++
+	n.Unlock()
+}
+
+// Replaces the points added to the node with the synthesized ones, propagating the original points to the children
+// and recursively building them. Leaf nodes keep the original points
+func (n *VoxelNode) BuildPoints() {
+	points := n.points
+	n.totalNumberOfPoints = int64(len(points))
+	n.initialized = true
+
+	voxels := groupInVoxels(points, n.cellSize)
+	if n.shouldBeLeaf(len(voxels), len(points)) {
+		n.numberOfPoints = int32(len(points))
+		return
+	}
+
+	synthesizedPoints := make([]*data.Point, 0, len(voxels))
+	for _, index := range getVoxelIndexes(voxels, n.opts.Deterministic) {
+		synthesizedPoints = append(synthesizedPoints, synthesizePoint(voxels[index]))
+	}
+	n.points = synthesizedPoints
+	n.numberOfPoints = int32(len(synthesizedPoints))
+
+	n.initializeChildren(points)
+
+	var wg sync.WaitGroup
+	for _, child := range n.children {
+		if child == nil {
+			continue
+		}
+		if n.depth < parallelBuildDepth {
+			wg.Add(1)
+			go func(child *VoxelNode) {
+				child.BuildPoints()
+				wg.Done()
+			}(child.(*VoxelNode))
+		} else {
+			child.(*VoxelNode).BuildPoints()
+		}
+	}
+	wg.Wait()
+}
+
+func (n *VoxelNode) GetChildren() [8]octree.INode {
+	return n.children
+}
+
+func (n *VoxelNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *VoxelNode) TotalNumberOfPoints() int64 {
+	return n.totalNumberOfPoints
+}
+
+func (n *VoxelNode) NumberOfPoints() int32 {
+	return n.numberOfPoints
+}
+
+func (n *VoxelNode) IsLeaf() bool {
+	return n.leaf
+}
+
+func (n *VoxelNode) IsInitialized() bool {
+	return n.initialized
+}
+
+func (n *VoxelNode) IsRoot() bool {
+	return n.root
+}
+
+func (n *VoxelNode) GetParent() octree.INode {
+	return n.parent
+}
+
+// Synthesized points are a full representation of the node volume and replace the content of the ancestors
+func (n *VoxelNode) IsAdditive() bool {
+	return false
+}
+
+// Computes the geometric error for the given VoxelNode
+func (n *VoxelNode) ComputeGeometricError() float64 {
+	if n.IsRoot() {
+		var w = math.Abs(n.BoundingBox.Xmax - n.BoundingBox.Xmin)
+		var l = math.Abs(n.BoundingBox.Ymax - n.BoundingBox.Ymin)
+		var h = math.Abs(n.BoundingBox.Zmax - n.BoundingBox.Zmin)
+		return math.Sqrt(w*w + l*l + h*h)
+	}
+	// geometric error is estimated as the maximum possible distance between two points lying in the voxel
+	return n.cellSize * math.Sqrt(3) * 2
+}
+
+// checks if the node must store the original points instead of synthesizing them
+func (n *VoxelNode) shouldBeLeaf(numberOfVoxels int, numberOfPoints int) bool {
+	if n.depth >= n.maxDepth {
+		return true
+	}
+
+	withinMaxNumberOfPoints := n.opts.GridMaxNumPointsPerNode <= 0 || numberOfPoints <= int(n.opts.GridMaxNumPointsPerNode)
+	return withinMaxNumberOfPoints && (numberOfVoxels == numberOfPoints || n.cellSize < n.opts.CellMinSize)
+}
+
+// creates the children nodes needed to store the given points, and assigns each point to the relevant child
+func (n *VoxelNode) initializeChildren(points []*data.Point) {
+	for _, point := range points {
+		octant := octree.GetOctant(point, n.BoundingBox)
+		if n.children[octant] == nil {
+			n.children[octant] = NewVoxelNode(n, n.Frame, geometry.NewBoundingBoxFromParent(n.BoundingBox, &octant), n.cellSize/2.0, n.opts, false)
+		}
+		child := n.children[octant].(*VoxelNode)
+		child.points = append(child.points, point)
+	}
+	n.leaf = false
+}
@@ -0,0 +1,25 @@
+package voxel_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+)
+
+// Represents a VoxelTree of points. Coarse levels of the tree store points synthesized as the centroids of the voxels
+// in which they divide their volume, while the leaves store the original points. Points are stored in a local
+// East-North-Up frame, so that voxel sizes are expressed in true meters regardless of the position of the cloud on Earth
+type VoxelTree struct {
+	*octree.LocalFrameTree
+}
+
+// Builds an empty VoxelTree initializing its properties to the correct defaults
+func NewVoxelTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &VoxelTree{
+		LocalFrameTree: octree.NewLocalFrameTree(point_loader.NewSequentialLoader(), opts, coordinateConverter, elevationCorrector, func(frame *geometry.LocalFrame, boundingBox *geometry.BoundingBox) octree.INode {
+			return NewVoxelNode(nil, frame, boundingBox, opts.CellMaxSize, opts, true)
+		}),
+	}
+}
@@ -0,0 +1,133 @@
+package point_loader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"sync"
+)
+
+// Name of the batch table property storing the local curvature of the points
+const CurvatureAttribute = "Curvature"
+
+// Min number of points, including the point itself, needed to estimate the curvature of a neighbourhood
+const minCurvatureNeighbours = 4
+
+// Wraps a Loader computing the local curvature of the points once all of them have been added. The curvature is the
+// surface variation of the neighbourhood of each point within the given radius, i.e. the smallest eigenvalue of the
+// covariance of the neighbours divided by the sum of the eigenvalues. It ranges from zero on flat areas to one third on
+// edges, corners and vegetation, and is appended to the attributes of each point. The points are passed to the wrapped
+// loader in the order they have been added
+type CurvatureLoader struct {
+	Loader
+	radius      float64
+	points      []*data.Point
+	computeOnce sync.Once
+	sync.Mutex
+}
+
+// Wraps the given loader in a CurvatureLoader whose neighbourhoods have the given radius in meters
+func NewCurvatureLoader(loader Loader, radius float64) *CurvatureLoader {
+	return &CurvatureLoader{
+		Loader: loader,
+		radius: radius,
+	}
+}
+
+func (l *CurvatureLoader) AddPoint(e *data.Point) {
+	l.Lock()
+	l.points = append(l.points, e)
+	l.Unlock()
+}
+
+func (l *CurvatureLoader) InitializeLoader() {
+	l.compute()
+	l.Loader.InitializeLoader()
+}
+
+func (l *CurvatureLoader) GetBounds() []float64 {
+	l.compute()
+	return l.Loader.GetBounds()
+}
+
+// computes in parallel the curvature of the points and passes them to the wrapped loader. Runs only once
+func (l *CurvatureLoader) compute() {
+	l.computeOnce.Do(func() {
+		l.Lock()
+		defer l.Unlock()
+
+		index := newSpatialIndex(l.points, l.radius)
+		curvatures := make([]float32, len(l.points))
+		forEachInParallel(len(l.points), func(i int) {
+			curvatures[i] = float32(index.getCurvature(l.points[i], l.radius))
+		})
+
+		for i, point := range l.points {
+			point.Attributes = append(point.Attributes, curvatures[i])
+			l.Loader.AddPoint(point)
+		}
+		l.points = nil
+	})
+}
+
+// returns the surface variation of the points within the given radius from the given one, which must not exceed the
+// cell size, or zero if they are too few to estimate it
+func (index *spatialIndex) getCurvature(point *data.Point, radius float64) float64 {
+	radiusSquared := radius * radius
+	key := index.getKey(point)
+
+	// coordinates are taken relative to the point to preserve the precision of the sums
+	var count, sumX, sumY, sumZ, sumXX, sumXY, sumXZ, sumYY, sumYZ, sumZZ float64
+	for x := key.X - 1; x <= key.X+1; x++ {
+		for y := key.Y - 1; y <= key.Y+1; y++ {
+			for z := key.Z - 1; z <= key.Z+1; z++ {
+				for _, other := range index.cells[geoKey{X: x, Y: y, Z: z}] {
+					if getSquaredDistance(point, other) > radiusSquared {
+						continue
+					}
+					dx, dy, dz := other.X-point.X, other.Y-point.Y, other.Z-point.Z
+					count++
+					sumX, sumY, sumZ = sumX+dx, sumY+dy, sumZ+dz
+					sumXX, sumXY, sumXZ = sumXX+dx*dx, sumXY+dx*dy, sumXZ+dx*dz
+					sumYY, sumYZ, sumZZ = sumYY+dy*dy, sumYZ+dy*dz, sumZZ+dz*dz
+				}
+			}
+		}
+	}
+	if count < minCurvatureNeighbours {
+		return 0
+	}
+
+	meanX, meanY, meanZ := sumX/count, sumY/count, sumZ/count
+	covariance := [6]float64{
+		sumXX/count - meanX*meanX,
+		sumYY/count - meanY*meanY,
+		sumZZ/count - meanZ*meanZ,
+		sumXY/count - meanX*meanY,
+		sumXZ/count - meanX*meanZ,
+		sumYZ/count - meanY*meanZ,
+	}
+	trace := covariance[0] + covariance[1] + covariance[2]
+	if trace <= 0 {
+		return 0
+	}
+
+	return math.Max(0, getSmallestEigenvalue(covariance)) / trace
+}
+
+// returns the smallest eigenvalue of the symmetric 3x3 matrix whose diagonal is given by the first three elements and
+// whose xy, xz and yz elements by the last three, computed in closed form
+func getSmallestEigenvalue(m [6]float64) float64 {
+	offDiagonal := m[3]*m[3] + m[4]*m[4] + m[5]*m[5]
+	if offDiagonal == 0 {
+		return math.Min(m[0], math.Min(m[1], m[2]))
+	}
+
+	q := (m[0] + m[1] + m[2]) / 3
+	a, b, c := m[0]-q, m[1]-q, m[2]-q
+	p := math.Sqrt((a*a + b*b + c*c + 2*offDiagonal) / 6)
+	// half of the determinant of (m - q * I) / p
+	r := (a*(b*c-m[5]*m[5]) - m[3]*(m[3]*c-m[5]*m[4]) + m[4]*(m[3]*m[5]-b*m[4])) / (2 * p * p * p)
+	phi := math.Acos(math.Max(-1, math.Min(1, r))) / 3
+
+	return q + 2*p*math.Cos(phi+2*math.Pi/3)
+}
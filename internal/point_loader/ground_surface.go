@@ -0,0 +1,169 @@
+package point_loader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+)
+
+// ASPRS classification of the ground points
+const groundClassification = 2
+
+// Max number of cells of a ground surface per ground point, the cell size is enlarged to respect it
+const MaxGroundCellsPerPoint = 4
+
+// Gridded digital terrain model storing in each cell the mean elevation of the ground points falling in it. Cells
+// without ground points are filled from their neighbours, growing the surface outwards from the cells with data
+type groundSurface struct {
+	minX, minY float64
+	cellSize   float64
+	nx, ny     int
+	heights    []float64
+}
+
+// Builds the ground surface of the given points from the ones classified as ground, covering the XY extent of all the
+// points. Returns nil if there are no ground points. The cell size is enlarged if the grid would have more than four
+// cells per ground point, as most of them would be empty
+func newGroundSurface(points []*data.Point, cellSize float64) *groundSurface {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	numGroundPoints := 0
+	for _, point := range points {
+		minX, maxX = math.Min(minX, point.X), math.Max(maxX, point.X)
+		minY, maxY = math.Min(minY, point.Y), math.Max(maxY, point.Y)
+		if point.Classification == groundClassification {
+			numGroundPoints++
+		}
+	}
+	if numGroundPoints == 0 {
+		return nil
+	}
+
+	area := (maxX - minX + cellSize) * (maxY - minY + cellSize)
+	if area/(cellSize*cellSize) > MaxGroundCellsPerPoint*float64(numGroundPoints) {
+		cellSize = math.Sqrt(area / (MaxGroundCellsPerPoint * float64(numGroundPoints)))
+	}
+
+	surface := groundSurface{
+		minX:     minX,
+		minY:     minY,
+		cellSize: cellSize,
+		nx:       int(math.Floor((maxX-minX)/cellSize)) + 1,
+		ny:       int(math.Floor((maxY-minY)/cellSize)) + 1,
+	}
+	surface.heights = make([]float64, surface.nx*surface.ny)
+	counts := make([]int, len(surface.heights))
+	for _, point := range points {
+		if point.Classification == groundClassification {
+			cell := surface.getCell(point.X, point.Y)
+			surface.heights[cell] += point.Z
+			counts[cell]++
+		}
+	}
+	filled := make([]bool, len(surface.heights))
+	for cell, count := range counts {
+		if count > 0 {
+			surface.heights[cell] /= float64(count)
+			filled[cell] = true
+		}
+	}
+	surface.fillEmptyCells(filled)
+
+	return &surface
+}
+
+// Returns the elevation of the ground at the given location, interpolated bilinearly between the centers of the four
+// closest cells. Locations outside the grid take the elevation of the closest cells
+func (s *groundSurface) getHeight(x, y float64) float64 {
+	fx := clamp((x-s.minX)/s.cellSize-0.5, 0, float64(s.nx-1))
+	fy := clamp((y-s.minY)/s.cellSize-0.5, 0, float64(s.ny-1))
+	i0, j0 := int(fx), int(fy)
+	i1, j1 := minInt(i0+1, s.nx-1), minInt(j0+1, s.ny-1)
+	tx, ty := fx-float64(i0), fy-float64(j0)
+
+	bottom := s.heights[j0*s.nx+i0]*(1-tx) + s.heights[j0*s.nx+i1]*tx
+	top := s.heights[j1*s.nx+i0]*(1-tx) + s.heights[j1*s.nx+i1]*tx
+
+	return bottom*(1-ty) + top*ty
+}
+
+// returns the index of the cell containing the given location
+func (s *groundSurface) getCell(x, y float64) int {
+	i := minInt(int((x-s.minX)/s.cellSize), s.nx-1)
+	j := minInt(int((y-s.minY)/s.cellSize), s.ny-1)
+	return j*s.nx + i
+}
+
+// fills the empty cells ring by ring, assigning to each the mean height of its neighbours filled in the previous rings
+func (s *groundSurface) fillEmptyCells(filled []bool) {
+	var front []int
+	for cell := range s.heights {
+		if !filled[cell] && s.hasFilledNeighbour(cell, filled) {
+			front = append(front, cell)
+		}
+	}
+
+	for len(front) > 0 {
+		heights := make([]float64, len(front))
+		for i, cell := range front {
+			var sum float64
+			count := 0
+			s.forEachNeighbour(cell, func(neighbour int) {
+				if filled[neighbour] {
+					sum += s.heights[neighbour]
+					count++
+				}
+			})
+			heights[i] = sum / float64(count)
+		}
+		for i, cell := range front {
+			s.heights[cell] = heights[i]
+			filled[cell] = true
+		}
+
+		var next []int
+		for _, cell := range front {
+			s.forEachNeighbour(cell, func(neighbour int) {
+				if !filled[neighbour] {
+					// flagged as filled to be queued once, its height is computed in the next ring
+					filled[neighbour] = true
+					next = append(next, neighbour)
+				}
+			})
+		}
+		for _, cell := range next {
+			filled[cell] = false
+		}
+		front = next
+	}
+}
+
+func (s *groundSurface) hasFilledNeighbour(cell int, filled []bool) bool {
+	found := false
+	s.forEachNeighbour(cell, func(neighbour int) {
+		found = found || filled[neighbour]
+	})
+	return found
+}
+
+// calls the given function with the index of each of the eight cells surrounding the given one within the grid
+func (s *groundSurface) forEachNeighbour(cell int, f func(neighbour int)) {
+	i, j := cell%s.nx, cell/s.nx
+	for y := j - 1; y <= j+1; y++ {
+		for x := i - 1; x <= i+1; x++ {
+			if (x != i || y != j) && x >= 0 && x < s.nx && y >= 0 && y < s.ny {
+				f(y*s.nx + x)
+			}
+		}
+	}
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
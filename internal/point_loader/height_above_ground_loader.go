@@ -0,0 +1,100 @@
+package point_loader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"sync"
+)
+
+// Name of the batch table property storing the height of the points above the ground
+const HeightAboveGroundAttribute = "HeightAboveGround"
+
+// Wraps a Loader computing the height above ground of the points once all of them have been added. The ground is a
+// gridded terrain model built from the points classified as ground, and the height is appended to the attributes of
+// each point. If no point is classified as ground heights are measured from the lowest point. The points are passed to
+// the wrapped loader in the order they have been added, optionally colored along a ramp by their height
+type HeightAboveGroundLoader struct {
+	Loader
+	cellSize    float64
+	colorize    bool
+	ramp        *colors.Ramp
+	colorMin    float64
+	colorMax    float64
+	points      []*data.Point
+	computeOnce sync.Once
+	sync.Mutex
+}
+
+// Wraps the given loader in a HeightAboveGroundLoader whose terrain model has cells of the given size
+func NewHeightAboveGroundLoader(loader Loader, cellSize float64) *HeightAboveGroundLoader {
+	return &HeightAboveGroundLoader{
+		Loader:   loader,
+		cellSize: cellSize,
+	}
+}
+
+// Colors the points along the given ramp, nil for the default one, mapping heights between min and max. If min equals
+// max the range goes from zero to colors.DefaultHeightAboveGroundMax
+func (l *HeightAboveGroundLoader) SetColorRamp(ramp *colors.Ramp, min float64, max float64) {
+	l.colorize = true
+	l.ramp = ramp
+	l.colorMin = min
+	l.colorMax = max
+}
+
+func (l *HeightAboveGroundLoader) AddPoint(e *data.Point) {
+	l.Lock()
+	l.points = append(l.points, e)
+	l.Unlock()
+}
+
+func (l *HeightAboveGroundLoader) InitializeLoader() {
+	l.compute()
+	l.Loader.InitializeLoader()
+}
+
+func (l *HeightAboveGroundLoader) GetBounds() []float64 {
+	l.compute()
+	return l.Loader.GetBounds()
+}
+
+// computes the heights above ground and passes the points to the wrapped loader. Runs only once
+func (l *HeightAboveGroundLoader) compute() {
+	l.computeOnce.Do(func() {
+		l.Lock()
+		defer l.Unlock()
+
+		surface := newGroundSurface(l.points, l.cellSize)
+		lowest := math.Inf(1)
+		for _, point := range l.points {
+			lowest = math.Min(lowest, point.Z)
+		}
+
+		heights := make([]float64, len(l.points))
+		for i, point := range l.points {
+			ground := lowest
+			if surface != nil {
+				ground = surface.getHeight(point.X, point.Y)
+			}
+			heights[i] = point.Z - ground
+			point.Attributes = append(point.Attributes, float32(heights[i]))
+		}
+
+		if l.colorize {
+			min, max := l.colorMin, l.colorMax
+			if min == max {
+				min, max = 0, colors.DefaultHeightAboveGroundMax
+			}
+			colorizer := colors.NewModeColorizer(colors.ModeHeightAboveGround, l.ramp, min, max)
+			for i, point := range l.points {
+				point.R, point.G, point.B = colorizer.Colorize(heights[i], uint16(point.Intensity), point.Classification)
+			}
+		}
+
+		for _, point := range l.points {
+			l.Loader.AddPoint(point)
+		}
+		l.points = nil
+	})
+}
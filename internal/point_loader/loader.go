@@ -19,4 +19,7 @@ type Loader interface {
 
 	// Returns the bounding box extremes of the stored cloud minX, maxX, minY, maxY, minZ, maxZ
 	GetBounds() []float64
+
+	// Returns the number of points added but discarded as outliers instead of being returned by GetNext
+	GetNumberOfRejectedPoints() int64
 }
@@ -0,0 +1,209 @@
+package point_loader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Wraps a Loader removing the isolated noise points once all the points have been added. A point is discarded if it
+// has less than the given number of neighbours within the given radius, or if the mean distance from its k nearest
+// neighbours exceeds the given max distance. The remaining points are passed to the wrapped loader in the order they
+// have been added
+type NoiseFilterLoader struct {
+	Loader
+	radius             float64
+	minNeighbours      int
+	kNearest           int
+	maxMeanDistance    float64
+	points             []*data.Point
+	filterOnce         sync.Once
+	numberOfRejections int64
+	sync.Mutex
+}
+
+// Wraps the given loader in a NoiseFilterLoader if any of the filters is enabled, returns it unchanged otherwise. The
+// radius filter is enabled if both radius and minNeighbours are greater than zero, the k nearest neighbours filter
+// if both kNearest and maxMeanDistance are
+func NewNoiseFilterLoader(loader Loader, radius float64, minNeighbours int, kNearest int, maxMeanDistance float64) Loader {
+	if (radius <= 0 || minNeighbours <= 0) && (kNearest <= 0 || maxMeanDistance <= 0) {
+		return loader
+	}
+
+	return &NoiseFilterLoader{
+		Loader:          loader,
+		radius:          radius,
+		minNeighbours:   minNeighbours,
+		kNearest:        kNearest,
+		maxMeanDistance: maxMeanDistance,
+	}
+}
+
+func (l *NoiseFilterLoader) AddPoint(e *data.Point) {
+	l.Lock()
+	l.points = append(l.points, e)
+	l.Unlock()
+}
+
+func (l *NoiseFilterLoader) InitializeLoader() {
+	l.filter()
+	l.Loader.InitializeLoader()
+}
+
+// Returns the bounds of the cloud without the noise points as minX, maxX, minY, maxY, minZ, maxZ
+func (l *NoiseFilterLoader) GetBounds() []float64 {
+	l.filter()
+	return l.Loader.GetBounds()
+}
+
+// Returns the number of noise points discarded plus the ones discarded by the wrapped loader
+func (l *NoiseFilterLoader) GetNumberOfRejectedPoints() int64 {
+	return atomic.LoadInt64(&l.numberOfRejections) + l.Loader.GetNumberOfRejectedPoints()
+}
+
+// flags in parallel the noise points and passes the other ones to the wrapped loader. Runs only once
+func (l *NoiseFilterLoader) filter() {
+	l.filterOnce.Do(func() {
+		l.Lock()
+		defer l.Unlock()
+
+		keep := make([]bool, len(l.points))
+		for i := range keep {
+			keep[i] = true
+		}
+		if l.radius > 0 && l.minNeighbours > 0 {
+			index := newSpatialIndex(l.points, l.radius)
+			l.flagInParallel(keep, func(point *data.Point) bool {
+				return index.countNeighbours(point, l.radius, l.minNeighbours) >= l.minNeighbours
+			})
+		}
+		if l.kNearest > 0 && l.maxMeanDistance > 0 {
+			index := newSpatialIndex(l.points, l.maxMeanDistance)
+			l.flagInParallel(keep, func(point *data.Point) bool {
+				return index.getMeanNearestNeighboursDistance(point, l.kNearest, l.maxMeanDistance) <= l.maxMeanDistance
+			})
+		}
+
+		for i, point := range l.points {
+			if keep[i] {
+				l.Loader.AddPoint(point)
+			} else {
+				l.numberOfRejections++
+			}
+		}
+		l.points = nil
+	})
+}
+
+// clears the flags of the points that are still kept but do not satisfy the given condition, checking them in parallel
+func (l *NoiseFilterLoader) flagInParallel(keep []bool, condition func(point *data.Point) bool) {
+	forEachInParallel(len(l.points), func(i int) {
+		if keep[i] && !condition(l.points[i]) {
+			keep[i] = false
+		}
+	})
+}
+
+// Spatial hash that groups the points in cubic cells of the given size to speed up the neighbourhood searches
+type spatialIndex struct {
+	cellSize float64
+	cells    map[geoKey][]*data.Point
+}
+
+func newSpatialIndex(points []*data.Point, cellSize float64) *spatialIndex {
+	index := spatialIndex{
+		cellSize: cellSize,
+		cells:    make(map[geoKey][]*data.Point),
+	}
+	for _, point := range points {
+		key := index.getKey(point)
+		index.cells[key] = append(index.cells[key], point)
+	}
+
+	return &index
+}
+
+// returns the key of the cell containing the point
+func (index *spatialIndex) getKey(point *data.Point) geoKey {
+	return geoKey{
+		X: int(math.Floor(point.X / index.cellSize)),
+		Y: int(math.Floor(point.Y / index.cellSize)),
+		Z: int(math.Floor(point.Z / index.cellSize)),
+	}
+}
+
+// counts the points, other than the given one, within the given radius from it. The radius must not exceed the cell
+// size. Stops counting once the given limit is reached
+func (index *spatialIndex) countNeighbours(point *data.Point, radius float64, limit int) int {
+	radiusSquared := radius * radius
+	key := index.getKey(point)
+	count := 0
+	for x := key.X - 1; x <= key.X+1; x++ {
+		for y := key.Y - 1; y <= key.Y+1; y++ {
+			for z := key.Z - 1; z <= key.Z+1; z++ {
+				for _, other := range index.cells[geoKey{X: x, Y: y, Z: z}] {
+					if other != point && getSquaredDistance(point, other) <= radiusSquared {
+						count++
+						if count >= limit {
+							return count
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+// returns the mean distance of the point from its k nearest neighbours, searching rings of cells of increasing size
+// around it. Returns +Inf if the mean certainly exceeds the given max distance, which bounds the search
+func (index *spatialIndex) getMeanNearestNeighboursDistance(point *data.Point, k int, maxDistance float64) float64 {
+	key := index.getKey(point)
+	// if the k-th neighbour is farther than k times the max distance the mean exceeds the max distance
+	maxRing := int(math.Ceil(float64(k)*maxDistance/index.cellSize)) + 1
+
+	var distances []float64
+	for ring := 0; ring <= maxRing; ring++ {
+		for x := key.X - ring; x <= key.X+ring; x++ {
+			for y := key.Y - ring; y <= key.Y+ring; y++ {
+				for z := key.Z - ring; z <= key.Z+ring; z++ {
+					if !isOnRing(x-key.X, y-key.Y, z-key.Z, ring) {
+						continue
+					}
+					for _, other := range index.cells[geoKey{X: x, Y: y, Z: z}] {
+						if other != point {
+							distances = append(distances, math.Sqrt(getSquaredDistance(point, other)))
+						}
+					}
+				}
+			}
+		}
+
+		// all the points closer than the distance of the outer ring from the point have been found
+		if len(distances) >= k {
+			sort.Float64s(distances)
+			if distances[k-1] <= float64(ring)*index.cellSize {
+				var sum float64
+				for _, distance := range distances[:k] {
+					sum += distance
+				}
+				return sum / float64(k)
+			}
+		}
+	}
+
+	return math.Inf(1)
+}
+
+// checks if the cell at the given offset from the center lies on the surface of the cube of cells with the given radius
+func isOnRing(dx, dy, dz, ring int) bool {
+	return dx == ring || dx == -ring || dy == ring || dy == -ring || dz == ring || dz == -ring
+}
+
+func getSquaredDistance(a *data.Point, b *data.Point) float64 {
+	dx, dy, dz := a.X-b.X, a.Y-b.Y, a.Z-b.Z
+	return dx*dx + dy*dy + dz*dz
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
 )
 
@@ -15,13 +16,15 @@ type RandomBoxLoader struct {
 	sync.Mutex
 	Buckets                            map[geoKey]*safeElementList
 	Keys                               []*geoKey
+	random                             *rand.Rand
 	currentKeyIndex                    int64
 	minX, maxX, minY, maxY, minZ, maxZ float64
 }
 
-// Instances a new RandomBoxLoader
-func NewRandomBoxLoader() *RandomBoxLoader {
+// Instances a new RandomBoxLoader that shuffles the points with a random number generator initialized with the given seed
+func NewRandomBoxLoader(seed int64) *RandomBoxLoader {
 	return &RandomBoxLoader{
+		random:          rand.New(rand.NewSource(seed)),
 		Buckets:         make(map[geoKey]*safeElementList),
 		Keys:            make([]*geoKey, 0),
 		currentKeyIndex: 0,
@@ -71,13 +74,18 @@ func (eb *RandomBoxLoader) GetNext() (*data.Point, bool) {
 }
 
 // Initializes the structure to allow proper retrieval of points. Shuffles the box order and points in each of the boxes.
+// Boxes are sorted by key before being shuffled, so that the result only depends on the seed and on the insertion order
 func (eb *RandomBoxLoader) InitializeLoader() {
-	for i, b := range eb.Buckets {
+	for i := range eb.Buckets {
 		var j = i
 		eb.Keys = append(eb.Keys, &j)
-		rand.Shuffle(len(b.Elements), func(i, j int) { b.Elements[i], b.Elements[j] = b.Elements[j], b.Elements[i] })
 	}
-	rand.Shuffle(len(eb.Keys), func(i, j int) { eb.Keys[i], eb.Keys[j] = eb.Keys[j], eb.Keys[i] })
+	sort.Slice(eb.Keys, func(i, j int) bool { return eb.Keys[i].less(eb.Keys[j]) })
+	for _, key := range eb.Keys {
+		b := eb.Buckets[*key]
+		eb.random.Shuffle(len(b.Elements), func(i, j int) { b.Elements[i], b.Elements[j] = b.Elements[j], b.Elements[i] })
+	}
+	eb.random.Shuffle(len(eb.Keys), func(i, j int) { eb.Keys[i], eb.Keys[j] = eb.Keys[j], eb.Keys[i] })
 	eb.currentKeyIndex = 0
 }
 
@@ -85,6 +93,10 @@ func (eb *RandomBoxLoader) GetBounds() []float64 {
 	return []float64{eb.minX, eb.maxX, eb.minY, eb.maxY, eb.minZ, eb.maxZ}
 }
 
+func (eb *RandomBoxLoader) GetNumberOfRejectedPoints() int64 {
+	return 0
+}
+
 // Updates the data cloud bounds according  to the given additional element to insert
 func (eb *RandomBoxLoader) recomputeBoundsFromElement(element *data.Point) {
 	eb.minX = math.Min(float64(element.X), eb.minX)
@@ -12,13 +12,15 @@ import (
 type RandomLoader struct {
 	sync.Mutex
 	fullyRandomList                    []*data.Point
+	random                             *rand.Rand
 	currentKeyIndex                    int64
 	minX, maxX, minY, maxY, minZ, maxZ float64
 }
 
-// Instances a new RandomLoader
-func NewRandomLoader() *RandomLoader {
+// Instances a new RandomLoader that shuffles the points with a random number generator initialized with the given seed
+func NewRandomLoader(seed int64) *RandomLoader {
 	return &RandomLoader{
+		random:          rand.New(rand.NewSource(seed)),
 		currentKeyIndex: 0,
 		minX:            math.MaxFloat64,
 		minY:            math.MaxFloat64,
@@ -47,7 +49,7 @@ func (eb *RandomLoader) GetNext() (*data.Point, bool) {
 }
 
 func (eb *RandomLoader) InitializeLoader() {
-	rand.Shuffle(len(eb.fullyRandomList), func(i, j int) { eb.fullyRandomList[i], eb.fullyRandomList[j] = eb.fullyRandomList[j], eb.fullyRandomList[i] })
+	eb.random.Shuffle(len(eb.fullyRandomList), func(i, j int) { eb.fullyRandomList[i], eb.fullyRandomList[j] = eb.fullyRandomList[j], eb.fullyRandomList[i] })
 	eb.currentKeyIndex = -1
 }
 
@@ -64,3 +66,7 @@ func (eb *RandomLoader) recomputeBoundsFromElement(element *data.Point) {
 func (eb *RandomLoader) GetBounds() []float64 {
 	return []float64{eb.minX, eb.maxX, eb.minY, eb.maxY, eb.minZ, eb.maxZ}
 }
+
+func (eb *RandomLoader) GetNumberOfRejectedPoints() int64 {
+	return 0
+}
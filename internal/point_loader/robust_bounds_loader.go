@@ -0,0 +1,207 @@
+package point_loader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Max number of coordinates sampled to estimate the percentiles of the cloud. Percentiles are exact for clouds with
+// fewer points
+const PercentileSampleSize = 1 << 20
+
+// Number of independently locked shards the points are spread across, so that concurrent readers rarely wait
+const robustBoundsShards = 16
+
+// Wraps a Loader computing the bounds of the cloud while ignoring outliers. Bounds can be restricted to the given lower
+// and upper percentiles of the coordinates along each axis, estimated on a fixed size random sample of the points, and
+// to the given number of standard deviations from their mean. Points outside the percentile bounds are kept and stored
+// in the nodes closest to them, while points outside the standard deviation bounds are discarded when retrieving the
+// points
+type RobustBoundsLoader struct {
+	Loader
+	percentile         float64
+	sigma              float64
+	next               uint64
+	shards             [robustBoundsShards]robustBoundsShard
+	bounds             []float64
+	sigmaBounds        []float64
+	boundsOnce         sync.Once
+	numberOfRejections int64
+}
+
+// Statistics of the points added to one shard of a RobustBoundsLoader: a reservoir sample of their coordinates and
+// their running mean and variance
+type robustBoundsShard struct {
+	sample   [][3]float64
+	seen     int64
+	random   *rand.Rand
+	count    int64
+	mean, m2 [3]float64
+	sync.Mutex
+}
+
+// Wraps the given loader in a RobustBoundsLoader if percentile or sigma are greater than zero, returns it unchanged
+// otherwise. Percentile is expressed in percent, e.g. 0.1 bounds the cloud between the 0.1th and 99.9th percentiles
+func NewRobustBoundsLoader(loader Loader, percentile float64, sigma float64) Loader {
+	if percentile <= 0 && sigma <= 0 {
+		return loader
+	}
+
+	l := &RobustBoundsLoader{
+		Loader:     loader,
+		percentile: percentile,
+		sigma:      sigma,
+	}
+	for i := range l.shards {
+		// fixed seeds make the sample reproducible if the points are added in the same order
+		l.shards[i].random = rand.New(rand.NewSource(int64(i) + 1))
+	}
+
+	return l
+}
+
+func (l *RobustBoundsLoader) AddPoint(e *data.Point) {
+	shard := &l.shards[(atomic.AddUint64(&l.next, 1)-1)%robustBoundsShards]
+	coordinates := [3]float64{e.X, e.Y, e.Z}
+
+	shard.Lock()
+	if l.percentile > 0 {
+		// reservoir sampling as per Algorithm R
+		shard.seen++
+		if len(shard.sample) < PercentileSampleSize/robustBoundsShards {
+			shard.sample = append(shard.sample, coordinates)
+		} else if j := shard.random.Int63n(shard.seen); j < int64(len(shard.sample)) {
+			shard.sample[j] = coordinates
+		}
+	}
+	if l.sigma > 0 {
+		// running mean and variance as per Welford's algorithm
+		shard.count++
+		for i, value := range coordinates {
+			delta := value - shard.mean[i]
+			shard.mean[i] += delta / float64(shard.count)
+			shard.m2[i] += delta * (value - shard.mean[i])
+		}
+	}
+	shard.Unlock()
+
+	l.Loader.AddPoint(e)
+}
+
+// Returns the next point, discarding the ones farther than the given number of standard deviations from the mean
+func (l *RobustBoundsLoader) GetNext() (*data.Point, bool) {
+	l.GetBounds()
+	for {
+		point, hasNext := l.Loader.GetNext()
+		if point == nil || l.sigmaBounds == nil || isWithinBounds(point, l.sigmaBounds) {
+			return point, hasNext
+		}
+		atomic.AddInt64(&l.numberOfRejections, 1)
+		if !hasNext {
+			return nil, false
+		}
+	}
+}
+
+// Returns the bounds of the cloud excluding the outliers as minX, maxX, minY, maxY, minZ, maxZ
+func (l *RobustBoundsLoader) GetBounds() []float64 {
+	l.boundsOnce.Do(func() {
+		bounds := l.Loader.GetBounds()
+		if l.percentile > 0 {
+			l.clampToPercentiles(bounds)
+		}
+		if l.sigma > 0 {
+			l.sigmaBounds = l.getSigmaBounds()
+			if l.sigmaBounds != nil {
+				for i := 0; i < 3; i++ {
+					bounds[2*i] = math.Max(bounds[2*i], l.sigmaBounds[2*i])
+					bounds[2*i+1] = math.Min(bounds[2*i+1], l.sigmaBounds[2*i+1])
+				}
+			}
+		}
+		l.bounds = bounds
+	})
+
+	return l.bounds
+}
+
+// Returns the number of points discarded because outside the robust bounds plus the ones discarded by the wrapped loader
+func (l *RobustBoundsLoader) GetNumberOfRejectedPoints() int64 {
+	return atomic.LoadInt64(&l.numberOfRejections) + l.Loader.GetNumberOfRejectedPoints()
+}
+
+// restricts the given bounds to the percentiles of the sampled coordinates and releases the sample
+func (l *RobustBoundsLoader) clampToPercentiles(bounds []float64) {
+	var sample [][3]float64
+	for i := range l.shards {
+		sample = append(sample, l.shards[i].sample...)
+		l.shards[i].sample = nil
+	}
+	if len(sample) == 0 {
+		return
+	}
+
+	values := make([]float64, len(sample))
+	for i := 0; i < 3; i++ {
+		for j, coordinates := range sample {
+			values[j] = coordinates[i]
+		}
+		sort.Float64s(values)
+		bounds[2*i] = math.Max(bounds[2*i], getPercentile(values, l.percentile))
+		bounds[2*i+1] = math.Min(bounds[2*i+1], getPercentile(values, 100-l.percentile))
+	}
+}
+
+// returns the bounds within the given number of standard deviations from the mean coordinates, merging the statistics
+// of the shards as per Chan's parallel algorithm. Returns nil if less than two points have been added
+func (l *RobustBoundsLoader) getSigmaBounds() []float64 {
+	var count float64
+	var mean, m2 [3]float64
+	for i := range l.shards {
+		shard := &l.shards[i]
+		if shard.count == 0 {
+			continue
+		}
+		shardCount := float64(shard.count)
+		total := count + shardCount
+		for j := 0; j < 3; j++ {
+			delta := shard.mean[j] - mean[j]
+			mean[j] += delta * shardCount / total
+			m2[j] += shard.m2[j] + delta*delta*count*shardCount/total
+		}
+		count = total
+	}
+	if count < 2 {
+		return nil
+	}
+
+	bounds := make([]float64, 6)
+	for i := 0; i < 3; i++ {
+		deviation := math.Sqrt(m2[i] / (count - 1))
+		bounds[2*i] = mean[i] - l.sigma*deviation
+		bounds[2*i+1] = mean[i] + l.sigma*deviation
+	}
+
+	return bounds
+}
+
+// returns the given percentile, expressed in percent, of the given sorted values interpolating between the closest ranks
+func getPercentile(sortedValues []float64, percentile float64) float64 {
+	position := percentile / 100 * float64(len(sortedValues)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	fraction := position - float64(lower)
+
+	return sortedValues[lower] + (sortedValues[upper]-sortedValues[lower])*fraction
+}
+
+// checks if the point lies within the given bounds expressed as minX, maxX, minY, maxY, minZ, maxZ
+func isWithinBounds(point *data.Point, bounds []float64) bool {
+	return point.X >= bounds[0] && point.X <= bounds[1] &&
+		point.Y >= bounds[2] && point.Y <= bounds[3] &&
+		point.Z >= bounds[4] && point.Z <= bounds[5]
+}
@@ -65,3 +65,7 @@ func (eb *SequentialLoader) recomputeBoundsFromElement(element *data.Point) {
 func (eb *SequentialLoader) GetBounds() []float64 {
 	return []float64{eb.minX, eb.maxX, eb.minY, eb.maxY, eb.minZ, eb.maxZ}
 }
+
+func (eb *SequentialLoader) GetNumberOfRejectedPoints() int64 {
+	return 0
+}
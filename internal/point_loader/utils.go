@@ -3,6 +3,7 @@ package point_loader
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"math"
+	"runtime"
 	"sync"
 )
 // Unique spatial key structure for grouping points
@@ -12,6 +13,17 @@ type geoKey struct {
 	Z int
 }
 
+// Checks if the key comes before the given one, comparing X, then Y, then Z
+func (k *geoKey) less(other *geoKey) bool {
+	if k.X != other.X {
+		return k.X < other.X
+	}
+	if k.Y != other.Y {
+		return k.Y < other.Y
+	}
+	return k.Z < other.Z
+}
+
 // Mutexed list of pointers to points for concurrent usage
 type safeElementList struct {
 	sync.Mutex
@@ -53,3 +65,24 @@ func computeGeoKey(e *data.Point) geoKey {
 		Z: int(math.Floor(e.Z / 10e-1)),
 	}
 }
+
+// calls visit with each index in [0, count), splitting the indexes in a block per CPU visited in parallel
+func forEachInParallel(count int, visit func(i int)) {
+	numCPUs := runtime.NumCPU()
+	blockSize := int(math.Ceil(float64(count) / float64(numCPUs)))
+	var wg sync.WaitGroup
+	for start := 0; start < count; start += blockSize {
+		end := start + blockSize
+		if end > count {
+			end = count
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				visit(i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+}
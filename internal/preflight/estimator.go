@@ -0,0 +1,165 @@
+package preflight
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+)
+
+// Memory always needed by the tiler regardless of the size of the input, e.g. go runtime, projection and geoid
+// model data and the buffer used to read the las file points in chunks
+const baseMemoryOverhead int64 = 128 << 20
+
+// Bytes needed to store a single point in the pnts files: 3 float32 coordinates, 3 color bytes, intensity and
+// classification bytes
+const outputBytesPerPoint int64 = 17
+
+// Approximate peak heap usage per point for each of the tree algorithms, including the point itself, its references
+// in the loader and in the tree nodes, the data structures used to sample it and the garbage collector slack.
+// Values have been measured empirically and are meant to be conservative
+var memoryBytesPerPoint = map[tiler.Algorithm]int64{
+	tiler.Grid:      320,
+	tiler.Quadtree:  320,
+	tiler.KdTree:    160,
+	tiler.Random:    128,
+	tiler.RandomBox: 160,
+	tiler.Voxel:     384,
+	tiler.Poisson:   352,
+}
+
+// Per point memory usage assumed for algorithms not listed in memoryBytesPerPoint
+const defaultMemoryBytesPerPoint int64 = 320
+
+// Memory used to estimate the percentiles of the coordinates: the sampled coordinates, their merged copy and the
+// values of one axis sorted at a time
+const percentileSampleMemory = point_loader.PercentileSampleSize * (24 + 24 + 8)
+
+// Memory used by each pixel of the colorization raster, decoded in memory as RGB
+const rasterBytesPerPixel int64 = 3
+
+// Additional per point memory used to compute the local curvature: the reference buffered until all points are read
+// and the one in the spatial index searched for the neighbours. The attribute storing it is counted as the others
+const curvatureBytesPerPoint int64 = 40
+
+// Additional per point memory used by the noise filter: the reference buffered until all points are read, the flag
+// marking the points to keep and, for each of the radius and nearest neighbours filters, the reference in the spatial
+// index searched for the neighbours plus the slack of its cell slices
+const noiseFilterBytesPerPoint int64 = 48
+
+// Additional per point memory used to compute the height above ground: the reference buffered until all points are
+// read and the height kept to color them. The attribute storing it is counted as the others
+const heightAboveGroundBytesPerPoint int64 = 16
+
+// Memory used by each cell of the ground surface the heights above ground are measured from: its mean height, the
+// number of ground points falling in it and its filled flag
+const groundCellBytes int64 = 17
+
+// Additional per point memory used by each computed attribute: the float32 value in the attributes slice of the point
+// plus the slack of its backing array, which grows as the attributes are appended
+const attributeBytesPerPoint int64 = 8
+
+// Summary information about a point cloud, as available from the input file headers before any point is read
+type CloudInfo struct {
+	NumberOfPoints int                          // number of points declared in the file header
+	Bounds         *geometry.BoundingBox        // bounds declared in the file header, expressed in the input srid
+	CountPoints    func() (*PointCounts, error) // counts the points over the bounds, called only if the cloud has to be split
+}
+
+// Contains the estimated resources needed to process a point cloud
+type Estimate struct {
+	NumberOfPoints int   // number of points to process
+	PeakMemory     int64 // peak memory usage, in bytes
+	OutputSize     int64 // total size of the generated tileset on disk, in bytes
+}
+
+// Estimates the peak memory usage and the output size of the tileset generated from the given cloud with the given options
+func EstimateResources(info CloudInfo, opts *tiler.TilerOptions) *Estimate {
+	return &Estimate{
+		NumberOfPoints: info.NumberOfPoints,
+		PeakMemory:     getFixedMemory(opts) + int64(info.NumberOfPoints)*getMemoryBytesPerPoint(opts),
+		OutputSize:     int64(float64(int64(info.NumberOfPoints)*outputBytesPerPoint) * getOutputDuplicationFactor(info, opts)),
+	}
+}
+
+// returns the memory used regardless of the number of points with the given options
+func getFixedMemory(opts *tiler.TilerOptions) int64 {
+	memory := baseMemoryOverhead
+	if opts.BoundsPercentile > 0 {
+		memory += percentileSampleMemory
+	}
+	if opts.Colorization != nil {
+		memory += int64(opts.Colorization.Width) * int64(opts.Colorization.Height) * rasterBytesPerPixel
+	}
+
+	return memory
+}
+
+// returns the per point memory usage of the algorithm of the given options, plus the one of the enabled features
+// that keep additional data for each point
+func getMemoryBytesPerPoint(opts *tiler.TilerOptions) int64 {
+	value, ok := memoryBytesPerPoint[opts.Algorithm]
+	if !ok {
+		value = defaultMemoryBytesPerPoint
+	}
+	if opts.NoiseMinNeighbours > 0 || opts.NoiseKNearest > 0 {
+		value += noiseFilterBytesPerPoint
+	}
+	if opts.Curvature {
+		value += curvatureBytesPerPoint
+	}
+	if opts.HeightAboveGround {
+		// the ground surface is bounded assuming that all points are classified as ground
+		value += heightAboveGroundBytesPerPoint + point_loader.MaxGroundCellsPerPoint*groundCellBytes
+	}
+	value += int64(len(opts.GetAttributeNames())) * attributeBytesPerPoint
+
+	return value
+}
+
+// returns how many times, on average, a point is written in the output. In ADD refine mode each point is stored once,
+// in REPLACE mode it is also copied in the tiles of the levels below the one it belongs to, which on average are
+// half of the tree depth
+func getOutputDuplicationFactor(info CloudInfo, opts *tiler.TilerOptions) float64 {
+	// the voxel algorithm stores each original point once plus the synthesized points of the coarse levels, whose
+	// number shrinks roughly by a factor of four at each level for surface-like clouds
+	if opts.Algorithm == tiler.Voxel {
+		return 4.0 / 3.0
+	}
+
+	if opts.RefineMode != tiler.RefineModeReplace {
+		return 1
+	}
+
+	return math.Max(1, float64(estimateTreeDepth(info, opts))/2)
+}
+
+// roughly estimates the number of levels of the tree that will be generated
+func estimateTreeDepth(info CloudInfo, opts *tiler.TilerOptions) int {
+	if opts.Algorithm == tiler.Random || opts.Algorithm == tiler.RandomBox {
+		if opts.MaxNumPointsPerNode <= 0 || info.NumberOfPoints <= int(opts.MaxNumPointsPerNode) {
+			return 1
+		}
+		return int(math.Ceil(math.Log(float64(info.NumberOfPoints)/float64(opts.MaxNumPointsPerNode))/math.Log(8))) + 1
+	}
+
+	// kd-tree nodes are split in two halves until they hold at most the max number of points
+	if opts.Algorithm == tiler.KdTree {
+		if opts.MaxNumPointsPerNode <= 0 || info.NumberOfPoints <= int(opts.MaxNumPointsPerNode) {
+			return 1
+		}
+		return int(math.Ceil(math.Log2(float64(info.NumberOfPoints)/float64(opts.MaxNumPointsPerNode)))) + 1
+	}
+
+	if opts.CellMinSize <= 0 || opts.CellMaxSize <= opts.CellMinSize {
+		return 1
+	}
+
+	// cells halve their size at each level until they become smaller than the min cell size
+	depth := int(math.Ceil(math.Log2(opts.CellMaxSize/opts.CellMinSize))) + 1
+	if opts.GridMaxDepth > 0 && depth > opts.GridMaxDepth+1 {
+		return opts.GridMaxDepth + 1
+	}
+
+	return depth
+}
@@ -0,0 +1,255 @@
+package preflight
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"strconv"
+)
+
+// A unit of work in which the input cloud is processed. If the whole cloud fits in the memory budget a single job
+// covering all the points is planned, otherwise the cloud is split in jobs each covering a rectangle of its 2D extent
+// and holding at most the number of points that fit in the budget.
+type Job struct {
+	Name                   string                // name of the subfolder where the job output is stored, empty if the job covers the whole cloud
+	Extent                 *geometry.BoundingBox // extent of the job in the input srid, nil if the job covers the whole cloud
+	NumberOfPoints         int64                 // number of points counted in the job extent, 0 if the job covers the whole cloud
+	xMin, xMax, yMin, yMax float64               // bounds used to select points, open towards infinity on the cloud border
+}
+
+// Returns true if the given point, expressed in the input srid, has to be processed by the job. Extents of
+// adjacent jobs are half-open so that each point is processed exactly once.
+func (j *Job) Contains(x, y, z float64) bool {
+	return x >= j.xMin && x < j.xMax && y >= j.yMin && y < j.yMax
+}
+
+// Returns true if the job covers the whole input cloud
+func (j *Job) IsWholeCloud() bool {
+	return j.Extent == nil
+}
+
+// Estimates the resources needed to process the given cloud and plans the jobs needed to process it within the
+// memory budget specified in the options. If the budget is exceeded and automatic splitting is disabled an error is returned
+func PlanJobs(info CloudInfo, opts *tiler.TilerOptions) ([]*Job, *Estimate, error) {
+	estimate := EstimateResources(info, opts)
+
+	if opts.MaxMemory <= 0 {
+		return []*Job{newWholeCloudJob()}, estimate, nil
+	}
+
+	budget := int64(opts.MaxMemory) << 20
+	if estimate.PeakMemory <= budget {
+		return []*Job{newWholeCloudJob()}, estimate, nil
+	}
+
+	if !opts.AutoSplit {
+		return nil, estimate, fmt.Errorf(
+			"the estimated peak memory usage of %s exceeds the max-memory budget of %s. Enable auto-split to process the input in smaller jobs or increase the budget",
+			FormatBytes(estimate.PeakMemory),
+			FormatBytes(budget),
+		)
+	}
+
+	if usesNeighbourhoods(opts) {
+		return nil, estimate, fmt.Errorf(
+			"the estimated peak memory usage of %s exceeds the max-memory budget of %s, but the input cannot be split in smaller jobs as the noise filters, curvature and height above ground need the neighbours of the points across the job borders. Increase the budget",
+			FormatBytes(estimate.PeakMemory),
+			FormatBytes(budget),
+		)
+	}
+
+	pointsBudget := (budget - getFixedMemory(opts)) / getMemoryBytesPerPoint(opts)
+	if pointsBudget <= 0 {
+		return nil, estimate, fmt.Errorf(
+			"the max-memory budget of %s is too small, at least %s are needed",
+			FormatBytes(budget),
+			FormatBytes(getFixedMemory(opts)+getMemoryBytesPerPoint(opts)),
+		)
+	}
+
+	if info.CountPoints == nil {
+		return nil, estimate, errors.New("cannot split the input in smaller jobs as its points cannot be counted")
+	}
+	counts, err := info.CountPoints()
+	if err != nil {
+		return nil, estimate, err
+	}
+
+	return splitByCounts(counts, pointsBudget), estimate, nil
+}
+
+// Formats the given number of bytes in a human readable form
+func FormatBytes(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(bytes)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + units[i]
+}
+
+// checks if the options enable stages computed from the neighbours of each point, which each job would find only among
+// its own points, altering the results near the job borders
+func usesNeighbourhoods(opts *tiler.TilerOptions) bool {
+	return opts.NoiseMinNeighbours > 0 || opts.NoiseKNearest > 0 || opts.Curvature || opts.HeightAboveGround
+}
+
+func newWholeCloudJob() *Job {
+	return &Job{
+		xMin: math.Inf(-1),
+		xMax: math.Inf(1),
+		yMin: math.Inf(-1),
+		yMax: math.Inf(1),
+	}
+}
+
+// splits the extent of the given counts in jobs holding at most the given number of points, bisecting it recursively
+// along the longest side of each rectangle at the cell boundary closest to the median of its points. Rectangles made
+// of a single cell are not split further, even if they exceed the budget
+func splitByCounts(counts *PointCounts, pointsBudget int64) []*Job {
+	table := counts.getSummedAreaTable()
+	var jobs []*Job
+
+	var split func(r cellRange)
+	split = func(r cellRange) {
+		numberOfPoints := countPoints(table, r)
+		columns, rows := r.lastColumn-r.firstColumn, r.lastRow-r.firstRow
+		if numberOfPoints <= pointsBudget || (columns == 1 && rows == 1) {
+			jobs = append(jobs, newJob(counts, r, numberOfPoints, len(jobs)))
+			return
+		}
+
+		if rows == 1 || (columns > 1 && float64(columns)*counts.cellWidth >= float64(rows)*counts.cellHeight) {
+			k := findMedianBoundary(r.firstColumn, r.lastColumn, numberOfPoints, func(k int) int64 {
+				return countPoints(table, cellRange{r.firstColumn, k, r.firstRow, r.lastRow})
+			})
+			split(cellRange{r.firstColumn, k, r.firstRow, r.lastRow})
+			split(cellRange{k, r.lastColumn, r.firstRow, r.lastRow})
+		} else {
+			k := findMedianBoundary(r.firstRow, r.lastRow, numberOfPoints, func(k int) int64 {
+				return countPoints(table, cellRange{r.firstColumn, r.lastColumn, r.firstRow, k})
+			})
+			split(cellRange{r.firstColumn, r.lastColumn, r.firstRow, k})
+			split(cellRange{r.firstColumn, r.lastColumn, k, r.lastRow})
+		}
+	}
+	split(cellRange{0, countGridSize, 0, countGridSize})
+
+	return jobs
+}
+
+// returns the boundary strictly between first and last splitting the given number of points most evenly, given the
+// number of points before each boundary
+func findMedianBoundary(first, last int, numberOfPoints int64, countBefore func(k int) int64) int {
+	best, bestDifference := first+1, int64(math.MaxInt64)
+	for k := first + 1; k < last; k++ {
+		difference := 2*countBefore(k) - numberOfPoints
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference < bestDifference {
+			best, bestDifference = k, difference
+		}
+	}
+	return best
+}
+
+// returns the job covering the given range of cells of the counts grid
+func newJob(counts *PointCounts, r cellRange, numberOfPoints int64, index int) *Job {
+	bounds := counts.bounds
+	extent := geometry.NewBoundingBox(
+		bounds.Xmin+float64(r.firstColumn)*counts.cellWidth,
+		bounds.Xmin+float64(r.lastColumn)*counts.cellWidth,
+		bounds.Ymin+float64(r.firstRow)*counts.cellHeight,
+		bounds.Ymin+float64(r.lastRow)*counts.cellHeight,
+		bounds.Zmin,
+		bounds.Zmax,
+	)
+
+	return &Job{
+		Name:           "part_" + strconv.Itoa(index),
+		Extent:         extent,
+		NumberOfPoints: numberOfPoints,
+		xMin:           openIfFirst(extent.Xmin, r.firstColumn),
+		xMax:           openIfLast(extent.Xmax, r.lastColumn-1, countGridSize),
+		yMin:           openIfFirst(extent.Ymin, r.firstRow),
+		yMax:           openIfLast(extent.Ymax, r.lastRow-1, countGridSize),
+	}
+}
+
+// returns -Inf if the given index is the first one of the grid, otherwise the given value
+func openIfFirst(value float64, index int) float64 {
+	if index == 0 {
+		return math.Inf(-1)
+	}
+	return value
+}
+
+// returns +Inf if the given index is the last one of the grid, otherwise the given value
+func openIfLast(value float64, index int, count int) float64 {
+	if index == count-1 {
+		return math.Inf(1)
+	}
+	return value
+}
+
+// Finds the job a point belongs to among jobs covering adjacent extents, checking only the jobs overlapping the cell
+// of a regular grid over their extents the point falls in
+type JobLocator struct {
+	jobs   []*Job
+	bounds *geometry.BoundingBox
+	width  float64
+	height float64
+	cells  [][]int // indices of the jobs overlapping each cell
+}
+
+// Instantiates a JobLocator for the given jobs, which must not cover the whole cloud
+func NewJobLocator(jobs []*Job) *JobLocator {
+	bounds := geometry.NewBoundingBox(math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1), 0, 0)
+	for _, job := range jobs {
+		bounds = geometry.NewBoundingBox(
+			math.Min(bounds.Xmin, job.Extent.Xmin), math.Max(bounds.Xmax, job.Extent.Xmax),
+			math.Min(bounds.Ymin, job.Extent.Ymin), math.Max(bounds.Ymax, job.Extent.Ymax),
+			0, 0,
+		)
+	}
+
+	locator := &JobLocator{
+		jobs:   jobs,
+		bounds: bounds,
+		width:  math.Max(bounds.Xmax-bounds.Xmin, math.SmallestNonzeroFloat64) / countGridSize,
+		height: math.Max(bounds.Ymax-bounds.Ymin, math.SmallestNonzeroFloat64) / countGridSize,
+		cells:  make([][]int, countGridSize*countGridSize),
+	}
+	for row := 0; row < countGridSize; row++ {
+		// border cells extend towards infinity as points outside the bounds fall in them
+		yMin := openIfFirst(bounds.Ymin+float64(row)*locator.height, row)
+		yMax := openIfLast(bounds.Ymin+float64(row+1)*locator.height, row, countGridSize)
+		for column := 0; column < countGridSize; column++ {
+			xMin := openIfFirst(bounds.Xmin+float64(column)*locator.width, column)
+			xMax := openIfLast(bounds.Xmin+float64(column+1)*locator.width, column, countGridSize)
+			for i, job := range jobs {
+				if job.xMin <= xMax && job.xMax >= xMin && job.yMin <= yMax && job.yMax >= yMin {
+					locator.cells[row*countGridSize+column] = append(locator.cells[row*countGridSize+column], i)
+				}
+			}
+		}
+	}
+
+	return locator
+}
+
+// Returns the index of the job the given point, expressed in the input srid, belongs to, -1 if no job contains it
+func (l *JobLocator) Locate(x, y, z float64) int {
+	column, row := getGridIndex(x, l.bounds.Xmin, l.width), getGridIndex(y, l.bounds.Ymin, l.height)
+	for _, i := range l.cells[row*countGridSize+column] {
+		if l.jobs[i].Contains(x, y, z) {
+			return i
+		}
+	}
+	return -1
+}
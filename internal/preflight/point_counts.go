@@ -0,0 +1,81 @@
+package preflight
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+)
+
+// Number of cells along each axis of the grid the points are counted in
+const countGridSize = 256
+
+// Number of points falling in each cell of a regular grid over the 2D extent of a cloud, counted in a single pass over
+// its points. Points outside the extent are counted in the closest border cell
+type PointCounts struct {
+	bounds     *geometry.BoundingBox
+	cellWidth  float64
+	cellHeight float64
+	counts     []int64
+}
+
+// Instantiates an empty PointCounts over the given bounds, expressed in the input srid
+func NewPointCounts(bounds *geometry.BoundingBox) *PointCounts {
+	return &PointCounts{
+		bounds:     bounds,
+		cellWidth:  math.Max(bounds.Xmax-bounds.Xmin, math.SmallestNonzeroFloat64) / countGridSize,
+		cellHeight: math.Max(bounds.Ymax-bounds.Ymin, math.SmallestNonzeroFloat64) / countGridSize,
+		counts:     make([]int64, countGridSize*countGridSize),
+	}
+}
+
+// Counts a point with the given coordinates
+func (c *PointCounts) Add(x, y float64) {
+	column, row := getGridIndex(x, c.bounds.Xmin, c.cellWidth), getGridIndex(y, c.bounds.Ymin, c.cellHeight)
+	c.counts[row*countGridSize+column]++
+}
+
+// Returns the total number of points counted
+func (c *PointCounts) Total() int64 {
+	var total int64
+	for _, count := range c.counts {
+		total += count
+	}
+	return total
+}
+
+// returns the index of the cell of the grid the given coordinate falls in, clamped to the grid
+func getGridIndex(value, min, cellSize float64) int {
+	index := math.Floor((value - min) / cellSize)
+	if !(index >= 0) {
+		return 0
+	}
+	if index >= countGridSize {
+		return countGridSize - 1
+	}
+	return int(index)
+}
+
+// returns the summed area table of the counts, whose element (column, row) is the number of points in the cells
+// before the given column and row
+func (c *PointCounts) getSummedAreaTable() []int64 {
+	table := make([]int64, (countGridSize+1)*(countGridSize+1))
+	for row := 0; row < countGridSize; row++ {
+		var rowSum int64
+		for column := 0; column < countGridSize; column++ {
+			rowSum += c.counts[row*countGridSize+column]
+			table[(row+1)*(countGridSize+1)+column+1] = table[row*(countGridSize+1)+column+1] + rowSum
+		}
+	}
+	return table
+}
+
+// A range of cells of the counts grid, first included and last excluded
+type cellRange struct {
+	firstColumn, lastColumn, firstRow, lastRow int
+}
+
+// returns the number of points in the given range of cells according to the given summed area table
+func countPoints(table []int64, r cellRange) int64 {
+	const stride = countGridSize + 1
+	return table[r.lastRow*stride+r.lastColumn] - table[r.firstRow*stride+r.lastColumn] -
+		table[r.lastRow*stride+r.firstColumn] + table[r.firstRow*stride+r.firstColumn]
+}
@@ -0,0 +1,37 @@
+package raster
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"log"
+)
+
+// Colors points sampling a raster at their location
+type Colorizer struct {
+	raster              *Raster
+	coordinateConverter converters.CoordinateConverter
+	srid                int
+}
+
+// Builds a colorizer for points expressed in the given srid, reprojected to the srid of the raster with the given
+// coordinate converter
+func NewColorizer(raster *Raster, coordinateConverter converters.CoordinateConverter, srid int) *Colorizer {
+	return &Colorizer{
+		raster:              raster,
+		coordinateConverter: coordinateConverter,
+		srid:                srid,
+	}
+}
+
+// Returns the color of the raster at the location of the given point. Returns false if the point is outside the raster
+func (c *Colorizer) Colorize(x, y, z float64) (uint8, uint8, uint8, bool) {
+	if c.srid != c.raster.Srid {
+		coordinate, err := c.coordinateConverter.ConvertCoordinateSrid(c.srid, c.raster.Srid, geometry.Coordinate{X: x, Y: y, Z: z})
+		if err != nil {
+			log.Fatal(err)
+		}
+		x, y = coordinate.X, coordinate.Y
+	}
+
+	return c.raster.Sample(x, y)
+}
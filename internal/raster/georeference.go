@@ -0,0 +1,146 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Affine transform from image to model coordinates, in the same order used by GDAL:
+//
+//	x = t[0] + t[1] * column + t[2] * row
+//	y = t[3] + t[4] * column + t[5] * row
+//
+// where the top left corner of the top left pixel has column and row 0 and its center has column and row 0.5
+type Transform [6]float64
+
+// Returns the inverse transform, from model to image coordinates
+func (t Transform) Invert() (Transform, error) {
+	determinant := t[1]*t[5] - t[2]*t[4]
+	if determinant == 0 {
+		return Transform{}, errors.New("the raster transform is not invertible")
+	}
+
+	return Transform{
+		(t[2]*t[3] - t[0]*t[5]) / determinant,
+		t[5] / determinant,
+		-t[2] / determinant,
+		(t[0]*t[4] - t[1]*t[3]) / determinant,
+		-t[4] / determinant,
+		t[1] / determinant,
+	}, nil
+}
+
+// Applies the transform to the given coordinates
+func (t Transform) Apply(x, y float64) (float64, float64) {
+	return t[0] + t[1]*x + t[2]*y, t[3] + t[4]*x + t[5]*y
+}
+
+// reads the transform of a GeoTIFF from its model transformation tag or from its tiepoint and pixel scale tags
+func readGeoTiffTransform(directory *tiffDirectory) (Transform, bool) {
+	var transform Transform
+	if matrix := directory.getFloats(tagModelTransform); len(matrix) >= 8 {
+		transform = Transform{matrix[3], matrix[0], matrix[1], matrix[7], matrix[4], matrix[5]}
+	} else {
+		tiepoint := directory.getFloats(tagModelTiepoint)
+		scale := directory.getFloats(tagModelPixelScale)
+		if len(tiepoint) < 6 || len(scale) < 2 {
+			return Transform{}, false
+		}
+		transform = Transform{tiepoint[3] - tiepoint[0]*scale[0], scale[0], 0, tiepoint[4] + tiepoint[1]*scale[1], 0, -scale[1]}
+	}
+
+	if readGeoKey(directory, geoKeyRasterType) == rasterPixelIsPoint {
+		// the transform refers to the centers of the pixels
+		transform[0] -= (transform[1] + transform[2]) / 2
+		transform[3] -= (transform[4] + transform[5]) / 2
+	}
+
+	return transform, true
+}
+
+// reads the EPSG code of the coordinate system of a GeoTIFF, 0 if it is missing or user defined
+func readGeoTiffSrid(directory *tiffDirectory) int {
+	for _, key := range []int{geoKeyProjectedCSType, geoKeyGeographicType} {
+		if srid := readGeoKey(directory, key); srid > 0 && srid != userDefinedGeoKey {
+			return srid
+		}
+	}
+
+	return 0
+}
+
+// checks if a GeoTIFF declares geographic coordinates, either with its model type or, if missing, with a geographic
+// coordinate system and no projected one
+func readGeoTiffIsGeographic(directory *tiffDirectory) bool {
+	if modelType := readGeoKey(directory, geoKeyModelType); modelType != 0 {
+		return modelType == modelTypeGeographic
+	}
+
+	return readGeoKey(directory, geoKeyProjectedCSType) == 0 && readGeoKey(directory, geoKeyGeographicType) > 0
+}
+
+// reads the value of a short GeoTIFF key stored in the key directory, 0 if it is missing
+func readGeoKey(directory *tiffDirectory, key int) int {
+	keys := directory.getInts(tagGeoKeyDirectory)
+	for i := 4; i+3 < len(keys); i += 4 {
+		// keys stored in other tags, i.e. with a non zero location, are not short values
+		if int(keys[i]) == key && keys[i+1] == 0 {
+			return int(keys[i+3])
+		}
+	}
+
+	return 0
+}
+
+// reads the transform of the world file accompanying the given image, if any. World files can be named after the
+// image replacing its extension with the first and last letter of it followed by w, e.g. tfw for tif, appending w to
+// it, e.g. tifw, or replacing it with wld
+func readWorldFile(imagePath string) (Transform, bool, error) {
+	extension := filepath.Ext(imagePath)
+	base := strings.TrimSuffix(imagePath, extension)
+	var extensions []string
+	if len(extension) > 2 {
+		extensions = append(extensions, extension[:2]+extension[len(extension)-1:]+"w")
+	}
+	extensions = append(extensions, extension+"w", ".wld")
+
+	for _, worldFileExtension := range extensions {
+		for _, path := range []string{base + strings.ToLower(worldFileExtension), base + strings.ToUpper(worldFileExtension)} {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			transform, err := parseWorldFile(path)
+			return transform, err == nil, err
+		}
+	}
+
+	return Transform{}, false, nil
+}
+
+// parses a world file, whose six lines are the pixel size along x, two rotation terms, the pixel size along y and
+// the coordinates of the center of the top left pixel
+func parseWorldFile(path string) (Transform, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Transform{}, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) < 6 {
+		return Transform{}, fmt.Errorf("invalid world file %s, expected 6 values", path)
+	}
+	var values [6]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return Transform{}, fmt.Errorf("invalid world file %s value %q", path, fields[i])
+		}
+	}
+
+	a, d, b, e, c, f := values[0], values[1], values[2], values[3], values[4], values[5]
+	return Transform{c - (a+b)/2, a, b, f - (d+e)/2, d, e}, nil
+}
@@ -0,0 +1,68 @@
+package raster
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Georeferenced single band raster of numeric values, e.g. a terrain model or a geoid grid, decoded in memory
+type Grid struct {
+	Width      int
+	Height     int
+	Srid       int
+	Geographic bool      // true if the GeoTIFF keys declare geographic coordinates
+	Values     []float64 // values row by row starting from the top left pixel, NaN where no data is available
+	Transform  Transform // transform from image to model coordinates
+}
+
+// Opens a GeoTIFF storing numbers in its first band. Values equal to the no data value declared in the GDAL_NODATA
+// tag are replaced by NaN. The srid is 0 if it is missing or user defined
+func OpenGrid(path string) (*Grid, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	directory, err := readTiffDirectory(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	width, height, values, err := decodeTiffValues(file, directory)
+	if err != nil {
+		return nil, err
+	}
+	transform, georeferenced := readGeoTiffTransform(directory)
+	if !georeferenced {
+		return nil, errors.New("the grid is not georeferenced by GeoTIFF tags")
+	}
+
+	noData := strings.Trim(string(directory.getBytes(tagGDALNoData)), "\x00 ")
+	if noDataValue, err := strconv.ParseFloat(noData, 64); err == nil {
+		if directory.getInt(tagSampleFormat, sampleFormatUnsigned) == sampleFormatFloat && directory.getInt(tagBitsPerSample, 0) == 32 {
+			// float32 samples are widened exactly, hence they match the no data value only once rounded to float32
+			noDataValue = float64(float32(noDataValue))
+		}
+		for i, value := range values {
+			if value == noDataValue {
+				values[i] = math.NaN()
+			}
+		}
+	}
+
+	return &Grid{
+		Width:      width,
+		Height:     height,
+		Srid:       readGeoTiffSrid(directory),
+		Geographic: readGeoTiffIsGeographic(directory),
+		Values:     values,
+		Transform:  transform,
+	}, nil
+}
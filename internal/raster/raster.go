@@ -0,0 +1,149 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+)
+
+// Georeferenced RGB raster, decoded in memory
+type Raster struct {
+	Width     int
+	Height    int
+	Srid      int
+	pixels    []uint8
+	transform Transform
+	inverse   Transform
+}
+
+// Builds a raster from its RGB pixels, stored row by row starting from the top left one, the transform from image to
+// model coordinates and the EPSG code of the model coordinates
+func NewRaster(width int, height int, pixels []uint8, transform Transform, srid int) (*Raster, error) {
+	if width <= 0 || height <= 0 || len(pixels) != width*height*3 {
+		return nil, errors.New("invalid raster size")
+	}
+	inverse, err := transform.Invert()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Raster{
+		Width:     width,
+		Height:    height,
+		Srid:      srid,
+		pixels:    pixels,
+		transform: transform,
+		inverse:   inverse,
+	}, nil
+}
+
+// Opens a GeoTIFF or an image in any other supported format, i.e. TIFF, PNG, JPEG or GIF, georeferenced by a world
+// file. If srid is not 0 it overrides the srid read from the GeoTIFF, otherwise it is required for rasters georeferenced
+// by a world file or lacking an EPSG code
+func Open(path string, srid int) (*Raster, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var width, height int
+	var pixels []uint8
+	var transform Transform
+	var georeferenced bool
+	directory, err := readTiffDirectory(file, info.Size())
+	switch {
+	case err == errNotTiff:
+		img, _, err := image.Decode(file)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported raster format: %v", err)
+		}
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		pixels = make([]uint8, width*height*3)
+		copyImage(img, pixels, width, height, 0, 0)
+	case err != nil:
+		return nil, err
+	default:
+		width, height, pixels, err = decodeTiff(file, directory)
+		if err != nil {
+			return nil, err
+		}
+		transform, georeferenced = readGeoTiffTransform(directory)
+		if srid == 0 {
+			srid = readGeoTiffSrid(directory)
+		}
+	}
+
+	// world files take precedence over the GeoTIFF tags
+	worldFileTransform, found, err := readWorldFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		transform, georeferenced = worldFileTransform, true
+	}
+
+	if !georeferenced {
+		return nil, errors.New("the raster is not georeferenced, neither by GeoTIFF tags nor by a world file")
+	}
+	if srid == 0 {
+		return nil, errors.New("unknown raster srid, it must be given explicitly")
+	}
+
+	return NewRaster(width, height, pixels, transform, srid)
+}
+
+// Returns the color of the raster at the given model coordinates, interpolating the four closest pixels. Returns
+// false if the coordinates are outside the raster
+func (r *Raster) Sample(x, y float64) (uint8, uint8, uint8, bool) {
+	column, row := r.inverse.Apply(x, y)
+	if math.IsNaN(column) || math.IsNaN(row) || column < 0 || row < 0 || column > float64(r.Width) || row > float64(r.Height) {
+		return 0, 0, 0, false
+	}
+
+	// coordinates relative to the centers of the pixels
+	column, row = column-0.5, row-0.5
+	column0, row0 := math.Floor(column), math.Floor(row)
+	dx, dy := column-column0, row-row0
+	c0, r0 := r.clampColumn(int(column0)), r.clampRow(int(row0))
+	c1, r1 := r.clampColumn(int(column0)+1), r.clampRow(int(row0)+1)
+
+	var color [3]uint8
+	for i := 0; i < 3; i++ {
+		top := float64(r.pixels[(r0*r.Width+c0)*3+i])*(1-dx) + float64(r.pixels[(r0*r.Width+c1)*3+i])*dx
+		bottom := float64(r.pixels[(r1*r.Width+c0)*3+i])*(1-dx) + float64(r.pixels[(r1*r.Width+c1)*3+i])*dx
+		color[i] = uint8(math.Round(top*(1-dy) + bottom*dy))
+	}
+
+	return color[0], color[1], color[2], true
+}
+
+func (r *Raster) clampColumn(column int) int {
+	if column < 0 {
+		return 0
+	}
+	if column >= r.Width {
+		return r.Width - 1
+	}
+	return column
+}
+
+func (r *Raster) clampRow(row int) int {
+	if row < 0 {
+		return 0
+	}
+	if row >= r.Height {
+		return r.Height - 1
+	}
+	return row
+}
@@ -0,0 +1,249 @@
+package raster
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// TIFF tags used to decode the image and its georeference
+const (
+	tagImageWidth         = 256
+	tagImageLength        = 257
+	tagBitsPerSample      = 258
+	tagCompression        = 259
+	tagPhotometric        = 262
+	tagStripOffsets       = 273
+	tagSamplesPerPixel    = 277
+	tagRowsPerStrip       = 278
+	tagStripByteCounts    = 279
+	tagPlanarConfig       = 284
+	tagPredictor          = 317
+	tagColorMap           = 320
+	tagTileWidth          = 322
+	tagTileLength         = 323
+	tagTileOffsets        = 324
+	tagTileByteCounts     = 325
+	tagSampleFormat       = 339
+	tagJPEGTables         = 347
+	tagModelPixelScale    = 33550
+	tagModelTiepoint      = 33922
+	tagModelTransform     = 34264
+	tagGeoKeyDirectory    = 34735
+	tagGDALNoData         = 42113
+	geoKeyModelType       = 1024
+	geoKeyRasterType      = 1025
+	geoKeyGeographicType  = 2048
+	geoKeyProjectedCSType = 3072
+	modelTypeGeographic   = 2
+	rasterPixelIsPoint    = 2
+	userDefinedGeoKey     = 32767
+)
+
+// size in bytes of the values of each TIFF field type, 0 for unknown types
+var fieldTypeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4, 0, 0, 8, 8, 8}
+
+// values of a TIFF field. Integer fields are also stored as floats
+type tiffField struct {
+	ints   []uint64
+	floats []float64
+	bytes  []byte
+}
+
+// returned when reading a file that is not a TIFF, as opposed to a malformed TIFF
+var errNotTiff = errors.New("not a TIFF file")
+
+// first image file directory of a TIFF file, with the byte order and the size in bytes of the file
+type tiffDirectory struct {
+	byteOrder binary.ByteOrder
+	fields    map[uint16]*tiffField
+	size      int64
+}
+
+// reads the header and the first image file directory of a classic or BigTIFF file of the given size in bytes. Returns
+// errNotTiff if the file is not a TIFF and an error if the directory refers to data beyond the end of the file
+func readTiffDirectory(r io.ReaderAt, size int64) (*tiffDirectory, error) {
+	header := make([]byte, 16)
+	if _, err := r.ReadAt(header[:8], 0); err != nil {
+		return nil, errNotTiff
+	}
+
+	directory := tiffDirectory{fields: map[uint16]*tiffField{}, size: size}
+	switch string(header[:2]) {
+	case "II":
+		directory.byteOrder = binary.LittleEndian
+	case "MM":
+		directory.byteOrder = binary.BigEndian
+	default:
+		return nil, errNotTiff
+	}
+
+	var bigTiff bool
+	var offset uint64
+	switch directory.byteOrder.Uint16(header[2:4]) {
+	case 42:
+		offset = uint64(directory.byteOrder.Uint32(header[4:8]))
+	case 43:
+		bigTiff = true
+		if _, err := r.ReadAt(header, 0); err != nil {
+			return nil, err
+		}
+		offset = directory.byteOrder.Uint64(header[8:16])
+	default:
+		return nil, errNotTiff
+	}
+
+	// number of entries, size of each entry and size of the inline value of each entry
+	countSize, entrySize, inlineSize := 2, 12, 4
+	if bigTiff {
+		countSize, entrySize, inlineSize = 8, 20, 8
+	}
+
+	if !directory.isWithinFile(offset, uint64(countSize)) {
+		return nil, errors.New("invalid TIFF directory offset")
+	}
+	countBytes := make([]byte, countSize)
+	if _, err := r.ReadAt(countBytes, int64(offset)); err != nil {
+		return nil, err
+	}
+	count := uint64(directory.byteOrder.Uint16(countBytes[:2]))
+	if bigTiff {
+		count = directory.byteOrder.Uint64(countBytes)
+	}
+	if count > uint64(size)/uint64(entrySize) || !directory.isWithinFile(offset+uint64(countSize), count*uint64(entrySize)) {
+		return nil, fmt.Errorf("invalid TIFF directory with %d entries exceeding the file size", count)
+	}
+
+	entries := make([]byte, int(count)*entrySize)
+	if _, err := r.ReadAt(entries, int64(offset)+int64(countSize)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		entry := entries[i*entrySize : (i+1)*entrySize]
+		tag := directory.byteOrder.Uint16(entry[0:2])
+		fieldType := int(directory.byteOrder.Uint16(entry[2:4]))
+		var valuesCount uint64
+		var value []byte
+		if bigTiff {
+			valuesCount = directory.byteOrder.Uint64(entry[4:12])
+			value = entry[12:20]
+		} else {
+			valuesCount = uint64(directory.byteOrder.Uint32(entry[4:8]))
+			value = entry[8:12]
+		}
+		if fieldType >= len(fieldTypeSizes) || fieldTypeSizes[fieldType] == 0 {
+			// unknown field types are skipped as mandated by the specifications
+			continue
+		}
+
+		if valuesCount > uint64(size)/uint64(fieldTypeSizes[fieldType]) {
+			return nil, fmt.Errorf("invalid TIFF tag %d with %d values exceeding the file size", tag, valuesCount)
+		}
+		valuesSize := int(valuesCount) * fieldTypeSizes[fieldType]
+		data := value[:minInt(valuesSize, inlineSize)]
+		if valuesSize > inlineSize {
+			var valueOffset uint64
+			if bigTiff {
+				valueOffset = directory.byteOrder.Uint64(value)
+			} else {
+				valueOffset = uint64(directory.byteOrder.Uint32(value))
+			}
+			if !directory.isWithinFile(valueOffset, uint64(valuesSize)) {
+				return nil, fmt.Errorf("invalid TIFF tag %d with values beyond the end of the file", tag)
+			}
+			data = make([]byte, valuesSize)
+			if _, err := r.ReadAt(data, int64(valueOffset)); err != nil {
+				return nil, fmt.Errorf("cannot read TIFF tag %d: %v", tag, err)
+			}
+		}
+		directory.fields[tag] = directory.decodeField(fieldType, int(valuesCount), data)
+	}
+
+	return &directory, nil
+}
+
+// checks if the given number of bytes starting at the given offset lie within the file
+func (d *tiffDirectory) isWithinFile(offset uint64, length uint64) bool {
+	return offset <= uint64(d.size) && length <= uint64(d.size)-offset
+}
+
+// decodes the values of a field of the given type
+func (d *tiffDirectory) decodeField(fieldType int, count int, data []byte) *tiffField {
+	field := tiffField{bytes: data}
+	size := fieldTypeSizes[fieldType]
+	for i := 0; i < count; i++ {
+		value := data[i*size : (i+1)*size]
+		switch fieldType {
+		case 1, 2, 7:
+			field.ints = append(field.ints, uint64(value[0]))
+		case 6:
+			field.ints = append(field.ints, uint64(int8(value[0])))
+		case 3:
+			field.ints = append(field.ints, uint64(d.byteOrder.Uint16(value)))
+		case 8:
+			field.ints = append(field.ints, uint64(int16(d.byteOrder.Uint16(value))))
+		case 4, 13:
+			field.ints = append(field.ints, uint64(d.byteOrder.Uint32(value)))
+		case 9:
+			field.ints = append(field.ints, uint64(int32(d.byteOrder.Uint32(value))))
+		case 16, 17, 18:
+			field.ints = append(field.ints, d.byteOrder.Uint64(value))
+		case 5:
+			field.floats = append(field.floats, float64(d.byteOrder.Uint32(value))/float64(d.byteOrder.Uint32(value[4:])))
+		case 10:
+			field.floats = append(field.floats, float64(int32(d.byteOrder.Uint32(value)))/float64(int32(d.byteOrder.Uint32(value[4:]))))
+		case 11:
+			field.floats = append(field.floats, float64(math.Float32frombits(d.byteOrder.Uint32(value))))
+		case 12:
+			field.floats = append(field.floats, math.Float64frombits(d.byteOrder.Uint64(value)))
+		}
+	}
+	if field.floats == nil {
+		for _, value := range field.ints {
+			field.floats = append(field.floats, float64(value))
+		}
+	}
+
+	return &field
+}
+
+// returns the integer values of the given tag, nil if the tag is missing
+func (d *tiffDirectory) getInts(tag uint16) []uint64 {
+	if field, ok := d.fields[tag]; ok {
+		return field.ints
+	}
+	return nil
+}
+
+// returns the first integer value of the given tag, or the default value if the tag is missing
+func (d *tiffDirectory) getInt(tag uint16, defaultValue int) int {
+	if values := d.getInts(tag); len(values) > 0 {
+		return int(values[0])
+	}
+	return defaultValue
+}
+
+// returns the floating point values of the given tag, nil if the tag is missing
+func (d *tiffDirectory) getFloats(tag uint16) []float64 {
+	if field, ok := d.fields[tag]; ok {
+		return field.floats
+	}
+	return nil
+}
+
+// returns the raw bytes of the given tag, nil if the tag is missing
+func (d *tiffDirectory) getBytes(tag uint16) []byte {
+	if field, ok := d.fields[tag]; ok {
+		return field.bytes
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,544 @@
+package raster
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// TIFF compression schemes supported by the decoder
+const (
+	compressionNone     = 1
+	compressionLZW      = 5
+	compressionJPEG     = 7
+	compressionDeflate  = 8
+	compressionPackBits = 32773
+	compressionDeflate2 = 32946
+)
+
+// TIFF photometric interpretations supported by the decoder
+const (
+	photometricWhiteIsZero = 0
+	photometricBlackIsZero = 1
+	photometricRGB         = 2
+	photometricPalette     = 3
+)
+
+// TIFF sample formats
+const (
+	sampleFormatUnsigned = 1
+	sampleFormatSigned   = 2
+	sampleFormatFloat    = 3
+)
+
+// TIFF predictors supported by the decoder
+const (
+	predictorNone          = 1
+	predictorHorizontal    = 2
+	predictorFloatingPoint = 3
+)
+
+// decodes a TIFF image, stored in strips or tiles, to 8 bit RGB pixels or to the values of its first sample
+type tiffDecoder struct {
+	r             io.ReaderAt
+	directory     *tiffDirectory
+	width         int
+	height        int
+	bitsPerSample int
+	sampleFormat  int
+	samples       int
+	compression   int
+	photometric   int
+	planar        bool
+	predictor     int
+	colorMap      []uint64
+	chunkWidth    int
+	chunkHeight   int
+	offsets       []uint64
+	byteCounts    []uint64
+	pixels        []uint8
+	values        []float64
+}
+
+// decodes the image described by the given directory returning its width, height and RGB pixels, row by row
+func decodeTiff(r io.ReaderAt, directory *tiffDirectory) (int, int, []uint8, error) {
+	d := newTiffDecoder(r, directory)
+	if err := d.validate(); err != nil {
+		return 0, 0, nil, err
+	}
+	d.pixels = make([]uint8, d.width*d.height*3)
+	if err := d.decodeChunks(); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return d.width, d.height, d.pixels, nil
+}
+
+// decodes the first sample of the pixels of the image described by the given directory, e.g. the elevations of a
+// terrain model, returning its width, height and values, row by row. Samples can be integers or floating point numbers
+func decodeTiffValues(r io.ReaderAt, directory *tiffDirectory) (int, int, []float64, error) {
+	d := newTiffDecoder(r, directory)
+	if err := d.validateValues(); err != nil {
+		return 0, 0, nil, err
+	}
+	d.values = make([]float64, d.width*d.height)
+	if err := d.decodeChunks(); err != nil {
+		return 0, 0, nil, err
+	}
+
+	return d.width, d.height, d.values, nil
+}
+
+func newTiffDecoder(r io.ReaderAt, directory *tiffDirectory) *tiffDecoder {
+	return &tiffDecoder{
+		r:            r,
+		directory:    directory,
+		width:        directory.getInt(tagImageWidth, 0),
+		height:       directory.getInt(tagImageLength, 0),
+		sampleFormat: directory.getInt(tagSampleFormat, sampleFormatUnsigned),
+		samples:      directory.getInt(tagSamplesPerPixel, 1),
+		compression:  directory.getInt(tagCompression, compressionNone),
+		photometric:  directory.getInt(tagPhotometric, photometricBlackIsZero),
+		planar:       directory.getInt(tagPlanarConfig, 1) == 2,
+		predictor:    directory.getInt(tagPredictor, predictorNone),
+		colorMap:     directory.getInts(tagColorMap),
+	}
+}
+
+// decodes all the strips or tiles of the image
+func (d *tiffDecoder) decodeChunks() error {
+	directory := d.directory
+
+	if tileWidth := directory.getInt(tagTileWidth, 0); tileWidth > 0 {
+		d.chunkWidth = tileWidth
+		d.chunkHeight = directory.getInt(tagTileLength, 0)
+		d.offsets = directory.getInts(tagTileOffsets)
+		d.byteCounts = directory.getInts(tagTileByteCounts)
+	} else {
+		d.chunkWidth = d.width
+		d.chunkHeight = minInt(directory.getInt(tagRowsPerStrip, d.height), d.height)
+		d.offsets = directory.getInts(tagStripOffsets)
+		d.byteCounts = directory.getInts(tagStripByteCounts)
+	}
+	if d.chunkWidth <= 0 || d.chunkHeight <= 0 || len(d.offsets) == 0 || len(d.offsets) != len(d.byteCounts) {
+		return errors.New("invalid TIFF strips or tiles")
+	}
+
+	chunksAcross := (d.width + d.chunkWidth - 1) / d.chunkWidth
+	chunksDown := (d.height + d.chunkHeight - 1) / d.chunkHeight
+	chunksPerPlane := chunksAcross * chunksDown
+	for i := range d.offsets {
+		plane := i / chunksPerPlane
+		index := i % chunksPerPlane
+		if err := d.decodeChunk(i, plane, (index%chunksAcross)*d.chunkWidth, (index/chunksAcross)*d.chunkHeight); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checks that the image is in one of the supported formats
+func (d *tiffDecoder) validate() error {
+	if d.width <= 0 || d.height <= 0 {
+		return errors.New("invalid TIFF image size")
+	}
+	for _, bits := range d.directory.getInts(tagBitsPerSample) {
+		if bits != 8 && bits != 16 {
+			return fmt.Errorf("unsupported TIFF bits per sample %d, only 8 and 16 are supported", bits)
+		}
+		d.bitsPerSample = int(bits)
+	}
+	if d.bitsPerSample == 0 {
+		return errors.New("unsupported TIFF bits per sample 1, only 8 and 16 are supported")
+	}
+	if d.sampleFormat != sampleFormatUnsigned {
+		return fmt.Errorf("unsupported TIFF sample format %d, only unsigned integers are supported", d.sampleFormat)
+	}
+	if d.predictor != predictorNone && d.predictor != predictorHorizontal {
+		return fmt.Errorf("unsupported TIFF predictor %d", d.predictor)
+	}
+
+	switch d.compression {
+	case compressionNone, compressionLZW, compressionDeflate, compressionDeflate2, compressionPackBits:
+	case compressionJPEG:
+		return nil
+	default:
+		return fmt.Errorf("unsupported TIFF compression %d", d.compression)
+	}
+
+	switch d.photometric {
+	case photometricWhiteIsZero, photometricBlackIsZero:
+	case photometricRGB:
+		if d.samples < 3 {
+			return errors.New("invalid TIFF RGB image with less than 3 samples per pixel")
+		}
+	case photometricPalette:
+		if len(d.colorMap) != 3<<uint(d.bitsPerSample) {
+			return errors.New("invalid TIFF color map")
+		}
+	default:
+		return fmt.Errorf("unsupported TIFF photometric interpretation %d", d.photometric)
+	}
+
+	return nil
+}
+
+// checks that the image values are in one of the supported formats
+func (d *tiffDecoder) validateValues() error {
+	if d.width <= 0 || d.height <= 0 {
+		return errors.New("invalid TIFF image size")
+	}
+	for _, bits := range d.directory.getInts(tagBitsPerSample) {
+		d.bitsPerSample = int(bits)
+	}
+	switch d.sampleFormat {
+	case sampleFormatUnsigned, sampleFormatSigned:
+		if d.bitsPerSample != 8 && d.bitsPerSample != 16 && d.bitsPerSample != 32 {
+			return fmt.Errorf("unsupported TIFF integer bits per sample %d, only 8, 16 and 32 are supported", d.bitsPerSample)
+		}
+	case sampleFormatFloat:
+		if d.bitsPerSample != 32 && d.bitsPerSample != 64 {
+			return fmt.Errorf("unsupported TIFF floating point bits per sample %d, only 32 and 64 are supported", d.bitsPerSample)
+		}
+	default:
+		return fmt.Errorf("unsupported TIFF sample format %d", d.sampleFormat)
+	}
+	switch {
+	case d.predictor == predictorNone:
+	case d.predictor == predictorHorizontal && d.sampleFormat != sampleFormatFloat && d.bitsPerSample <= 16:
+	case d.predictor == predictorFloatingPoint && d.sampleFormat == sampleFormatFloat:
+	default:
+		return fmt.Errorf("unsupported TIFF predictor %d for %d bits samples", d.predictor, d.bitsPerSample)
+	}
+
+	switch d.compression {
+	case compressionNone, compressionLZW, compressionDeflate, compressionDeflate2, compressionPackBits:
+	default:
+		return fmt.Errorf("unsupported TIFF compression %d", d.compression)
+	}
+
+	return nil
+}
+
+// decodes the chunk with the given index, whose top left pixel is at the given column and row of the image. Planar
+// images store each sample in a separate chunk, the plane is the index of the sample stored in the chunk
+func (d *tiffDecoder) decodeChunk(index int, plane int, column int, row int) error {
+	if d.byteCounts[index] == 0 {
+		// missing chunks are left black
+		return nil
+	}
+	if !d.directory.isWithinFile(d.offsets[index], d.byteCounts[index]) {
+		return fmt.Errorf("invalid TIFF strip or tile %d beyond the end of the file", index)
+	}
+	data := make([]byte, d.byteCounts[index])
+	if _, err := d.r.ReadAt(data, int64(d.offsets[index])); err != nil && err != io.EOF {
+		return err
+	}
+
+	if d.compression == compressionJPEG {
+		return d.decodeJPEGChunk(data, column, row)
+	}
+
+	data, err := d.decompress(data)
+	if err != nil {
+		return err
+	}
+
+	samples := d.samples
+	if d.planar {
+		samples = 1
+	}
+	bytesPerSample := d.bitsPerSample / 8
+	rowLength := d.chunkWidth * samples * bytesPerSample
+	rows := minInt(d.chunkHeight, d.height-row)
+	if len(data) < rows*rowLength {
+		return errors.New("truncated TIFF strip or tile")
+	}
+	byteOrder := d.directory.byteOrder
+	switch d.predictor {
+	case predictorHorizontal:
+		d.undoHorizontalDifferencing(data, rows, rowLength, samples)
+	case predictorFloatingPoint:
+		undoFloatingPointDifferencing(data, rows, rowLength, samples, bytesPerSample)
+		byteOrder = binary.BigEndian
+	}
+
+	if d.values != nil {
+		for y := 0; y < rows; y++ {
+			for x := 0; x < d.chunkWidth && column+x < d.width; x++ {
+				if plane == 0 {
+					d.values[(row+y)*d.width+column+x] = d.getValue(data[y*rowLength+x*samples*bytesPerSample:], byteOrder)
+				}
+			}
+		}
+		return nil
+	}
+
+	for y := 0; y < rows; y++ {
+		for x := 0; x < d.chunkWidth && column+x < d.width; x++ {
+			pixel := (row+y)*d.width + column + x
+			for s := 0; s < samples; s++ {
+				offset := y*rowLength + (x*samples+s)*bytesPerSample
+				value := uint16(data[offset])
+				if bytesPerSample == 2 {
+					value = d.directory.byteOrder.Uint16(data[offset:])
+				}
+				d.setSample(pixel, plane+s, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// decodes a chunk compressed as a JPEG stream, merging it with the tables shared by all the chunks, if any
+func (d *tiffDecoder) decodeJPEGChunk(data []byte, column int, row int) error {
+	if tables := d.directory.getBytes(tagJPEGTables); len(tables) > 4 && len(data) > 2 {
+		// tables are stored as a stream with no image: its end marker and the start marker of the chunk are removed
+		data = append(append([]byte{}, tables[:len(tables)-2]...), data[2:]...)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot decode TIFF JPEG tile: %v", err)
+	}
+
+	copyImage(img, d.pixels, d.width, d.height, column, row)
+	return nil
+}
+
+// decompresses the data of a chunk
+func (d *tiffDecoder) decompress(data []byte) ([]byte, error) {
+	switch d.compression {
+	case compressionLZW:
+		return decodeLZW(data)
+	case compressionDeflate, compressionDeflate2:
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = reader.Close() }()
+		return ioutil.ReadAll(reader)
+	case compressionPackBits:
+		return decodePackBits(data), nil
+	}
+
+	return data, nil
+}
+
+// restores the values of the samples stored as differences from the same sample of the previous pixel of the row
+func (d *tiffDecoder) undoHorizontalDifferencing(data []byte, rows int, rowLength int, samples int) {
+	for y := 0; y < rows; y++ {
+		line := data[y*rowLength : (y+1)*rowLength]
+		if d.bitsPerSample == 8 {
+			for i := samples; i < len(line); i++ {
+				line[i] += line[i-samples]
+			}
+			continue
+		}
+		for i := samples * 2; i+1 < len(line); i += 2 {
+			value := d.directory.byteOrder.Uint16(line[i:]) + d.directory.byteOrder.Uint16(line[i-samples*2:])
+			d.directory.byteOrder.PutUint16(line[i:], value)
+		}
+	}
+}
+
+// restores the values of the floating point samples stored with the floating point predictor: the bytes of the samples
+// of a row are grouped from the most to the least significant and then differenced as with the horizontal predictor.
+// The restored samples are big endian
+func undoFloatingPointDifferencing(data []byte, rows int, rowLength int, samples int, bytesPerSample int) {
+	line := make([]byte, rowLength)
+	values := rowLength / bytesPerSample
+	for y := 0; y < rows; y++ {
+		row := data[y*rowLength : (y+1)*rowLength]
+		for i := samples; i < len(row); i++ {
+			row[i] += row[i-samples]
+		}
+		for i := 0; i < values; i++ {
+			for b := 0; b < bytesPerSample; b++ {
+				line[i*bytesPerSample+b] = row[b*values+i]
+			}
+		}
+		copy(row, line)
+	}
+}
+
+// decodes the sample at the start of the given data as a number
+func (d *tiffDecoder) getValue(data []byte, byteOrder binary.ByteOrder) float64 {
+	switch {
+	case d.sampleFormat == sampleFormatFloat && d.bitsPerSample == 32:
+		return float64(math.Float32frombits(byteOrder.Uint32(data)))
+	case d.sampleFormat == sampleFormatFloat:
+		return math.Float64frombits(byteOrder.Uint64(data))
+	case d.sampleFormat == sampleFormatSigned && d.bitsPerSample == 8:
+		return float64(int8(data[0]))
+	case d.sampleFormat == sampleFormatSigned && d.bitsPerSample == 16:
+		return float64(int16(byteOrder.Uint16(data)))
+	case d.sampleFormat == sampleFormatSigned:
+		return float64(int32(byteOrder.Uint32(data)))
+	case d.bitsPerSample == 8:
+		return float64(data[0])
+	case d.bitsPerSample == 16:
+		return float64(byteOrder.Uint16(data))
+	}
+	return float64(byteOrder.Uint32(data))
+}
+
+// stores the value of the given sample of a pixel, converting it to RGB according to the photometric interpretation.
+// Samples beyond the color ones, e.g. alpha, are ignored
+func (d *tiffDecoder) setSample(pixel int, sample int, value uint16) {
+	color := uint8(value)
+	if d.bitsPerSample == 16 {
+		color = uint8(value >> 8)
+	}
+
+	switch d.photometric {
+	case photometricRGB:
+		if sample < 3 {
+			d.pixels[pixel*3+sample] = color
+		}
+	case photometricPalette:
+		if sample == 0 {
+			entries := len(d.colorMap) / 3
+			d.pixels[pixel*3] = uint8(d.colorMap[value] >> 8)
+			d.pixels[pixel*3+1] = uint8(d.colorMap[entries+int(value)] >> 8)
+			d.pixels[pixel*3+2] = uint8(d.colorMap[2*entries+int(value)] >> 8)
+		}
+	default:
+		if sample == 0 {
+			if d.photometric == photometricWhiteIsZero {
+				color = 255 - color
+			}
+			d.pixels[pixel*3] = color
+			d.pixels[pixel*3+1] = color
+			d.pixels[pixel*3+2] = color
+		}
+	}
+}
+
+// copies the given image in the RGB pixels of a larger image at the given column and row, clipping it at its borders.
+// Colors are not premultiplied by their alpha, as in the TIFF images with extra samples
+func copyImage(img image.Image, pixels []uint8, width int, height int, column int, row int) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y && row+y-bounds.Min.Y < height; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X && column+x-bounds.Min.X < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixel := (row+y-bounds.Min.Y)*width + column + x - bounds.Min.X
+			pixels[pixel*3] = c.R
+			pixels[pixel*3+1] = c.G
+			pixels[pixel*3+2] = c.B
+		}
+	}
+}
+
+// decodes data compressed with the PackBits run length encoding
+func decodePackBits(data []byte) []byte {
+	var output []byte
+	for i := 0; i < len(data); {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			end := minInt(i+n+1, len(data))
+			output = append(output, data[i:end]...)
+			i = end
+		case n != -128 && i < len(data):
+			for j := 0; j < 1-n; j++ {
+				output = append(output, data[i])
+			}
+			i++
+		}
+	}
+
+	return output
+}
+
+// decodes data compressed with the TIFF variant of LZW, which uses codes of increasing width written from the most
+// significant bit and increases their width one code earlier than the standard variant
+func decodeLZW(data []byte) ([]byte, error) {
+	const clearCode, endCode, firstCode, maxCodes = 256, 257, 258, 4096
+	var prefixes [maxCodes]uint16
+	var suffixes [maxCodes]byte
+	var lengths [maxCodes]int
+	for i := 0; i < 256; i++ {
+		suffixes[i] = byte(i)
+		lengths[i] = 1
+	}
+
+	output := make([]byte, 0, len(data)*2)
+	next, width, previous := firstCode, uint(9), -1
+	var bits uint32
+	var bitsCount uint
+	for i := 0; ; {
+		for bitsCount < width && i < len(data) {
+			bits = bits<<8 | uint32(data[i])
+			bitsCount += 8
+			i++
+		}
+		if bitsCount < width {
+			// some encoders omit the end code
+			return output, nil
+		}
+		code := int(bits>>(bitsCount-width)) & (1<<width - 1)
+		bitsCount -= width
+
+		switch {
+		case code == endCode:
+			return output, nil
+		case code == clearCode:
+			next, width, previous = firstCode, 9, -1
+			continue
+		case previous == -1:
+			if code > 255 {
+				return nil, errors.New("invalid LZW data")
+			}
+			output = append(output, byte(code))
+			previous = code
+			continue
+		case code > next || (code == next && next == maxCodes):
+			return nil, errors.New("invalid LZW data")
+		}
+
+		start := len(output)
+		if code < next {
+			output = appendLZWString(output, code, &prefixes, &suffixes, &lengths)
+		} else {
+			// the code being defined: the string of the previous code followed by its first byte
+			output = appendLZWString(output, previous, &prefixes, &suffixes, &lengths)
+			output = append(output, output[start])
+		}
+
+		if next < maxCodes {
+			prefixes[next] = uint16(previous)
+			suffixes[next] = output[start]
+			lengths[next] = lengths[previous] + 1
+			next++
+		}
+		previous = code
+		if next+1 >= 1<<width && width < 12 {
+			width++
+		}
+	}
+}
+
+// appends the string of the given LZW code to the output
+func appendLZWString(output []byte, code int, prefixes *[4096]uint16, suffixes *[4096]byte, lengths *[4096]int) []byte {
+	length := lengths[code]
+	for i := 0; i < length; i++ {
+		output = append(output, 0)
+	}
+	for i := len(output) - 1; i >= len(output)-length; i-- {
+		output[i] = suffixes[code]
+		code = int(prefixes[code])
+	}
+
+	return output
+}
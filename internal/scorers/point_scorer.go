@@ -0,0 +1,95 @@
+package scorers
+
+import (
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"strings"
+)
+
+// Assigns an importance score to the points competing to represent a grid cell. The cell retains the point with the
+// highest score, ties are resolved in favour of the point closest to the cell center
+type PointScorer interface {
+	// Returns the score of the given point, given its distance in meters from the center of the cell
+	Score(point *data.Point, distanceFromCenter float64) float64
+}
+
+// Scores the points by their distance from the cell center, the closest one having the highest score
+type CenterScorer struct{}
+
+// Instantiates a new CenterScorer
+func NewCenterScorer() PointScorer {
+	return &CenterScorer{}
+}
+
+func (s *CenterScorer) Score(point *data.Point, distanceFromCenter float64) float64 {
+	return -distanceFromCenter
+}
+
+// Scores the points by the value of one of their attributes, the highest value having the highest score
+type AttributeScorer struct {
+	attribute func(point *data.Point) float64
+}
+
+// Instantiates a new AttributeScorer that scores the points by the value returned by the given function
+func NewAttributeScorer(attribute func(point *data.Point) float64) PointScorer {
+	return &AttributeScorer{
+		attribute: attribute,
+	}
+}
+
+func (s *AttributeScorer) Score(point *data.Point, distanceFromCenter float64) float64 {
+	return s.attribute(point)
+}
+
+// Prefix of the names of the scorers that score the points by the value of one of their computed attributes
+const attributeScorerPrefix = "attribute:"
+
+// Returns the scorer with the given name, which can be center, intensity, red, green, blue, luminance, curvature, to
+// prefer the points of the areas with more geometric detail, or attribute:NAME, to prefer the points with the highest
+// value of the computed attribute NAME. The attributes computed for the points are given in the order they are stored
+func ParsePointScorer(name string, attributeNames []string) (PointScorer, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	switch normalized {
+	case "", "center":
+		return NewCenterScorer(), nil
+	case "intensity":
+		return NewAttributeScorer(func(point *data.Point) float64 { return float64(point.Intensity) }), nil
+	case "red":
+		return NewAttributeScorer(func(point *data.Point) float64 { return float64(point.R) }), nil
+	case "green":
+		return NewAttributeScorer(func(point *data.Point) float64 { return float64(point.G) }), nil
+	case "blue":
+		return NewAttributeScorer(func(point *data.Point) float64 { return float64(point.B) }), nil
+	case "luminance":
+		return NewAttributeScorer(func(point *data.Point) float64 {
+			return 0.2126*float64(point.R) + 0.7152*float64(point.G) + 0.0722*float64(point.B)
+		}), nil
+	case "curvature":
+		return newComputedAttributeScorer(point_loader.CurvatureAttribute, attributeNames)
+	}
+
+	if strings.HasPrefix(normalized, attributeScorerPrefix) {
+		return newComputedAttributeScorer(strings.TrimSpace(strings.TrimSpace(name)[len(attributeScorerPrefix):]), attributeNames)
+	}
+
+	return nil, fmt.Errorf("unknown point scorer %q", name)
+}
+
+// returns an AttributeScorer scoring the points by the value of the computed attribute with the given name, compared
+// ignoring the case. Points lacking the attribute score zero
+func newComputedAttributeScorer(attribute string, attributeNames []string) (PointScorer, error) {
+	for i, name := range attributeNames {
+		if strings.EqualFold(name, attribute) {
+			index := i
+			return NewAttributeScorer(func(point *data.Point) float64 {
+				if index >= len(point.Attributes) {
+					return 0
+				}
+				return float64(point.Attributes[index])
+			}), nil
+		}
+	}
+
+	return nil, fmt.Errorf("attribute %q is not computed for the points", attribute)
+}
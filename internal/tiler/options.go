@@ -1,6 +1,19 @@
 package tiler
 
-import "strings"
+import (
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/crop"
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geoid"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scorers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/transform"
+	"strconv"
+	"strings"
+)
 
 type Algorithm string
 type RefineMode string
@@ -15,6 +28,24 @@ const (
 	// the selection will begin again from the first one. If one box becomes empty is removed and replaced with the last one in the set.
 	Random    Algorithm = "RANDOM"
 	RandomBox Algorithm = "RANDOMBOX"
+
+	// Voxel based algorithm that uses the grid settings. Coarse levels store one point per voxel, synthesized as the
+	// centroid of the voxel points with their average color and intensity and their most frequent classification.
+	// Leaves store the original points. Tiles always replace their parent content.
+	Voxel Algorithm = "VOXEL"
+
+	// Poisson-disk sampling algorithm that uses the grid settings. Each level stores points not closer than a min
+	// spacing, which starts from the max cell size at the root and halves at each level, producing a blue noise
+	// distribution of the points. Nodes with a spacing below the min cell size store all the points.
+	Poisson Algorithm = "POISSON"
+
+	// Grid algorithm that subdivides nodes along the X and Y axes only, in four children. The vertical extent of each
+	// node is taken from its points, producing shallower trees for flat datasets such as airborne surveys.
+	Quadtree Algorithm = "QUADTREE"
+
+	// Balanced kd-tree that splits nodes in two along the median of the longest axis of their points, until each tile
+	// holds at most the max number of points per node. Non leaf nodes store a random sample of their points.
+	KdTree Algorithm = "KDTREE"
 )
 
 const (
@@ -41,20 +72,98 @@ func ParseRefineMode(value string) RefineMode {
 	return ""
 }
 
+// Parses a comma separated list of classifications, each optionally followed by a colon and its priority, e.g. "14,15:2".
+// Classifications without an explicit priority get priority 1, the ones not listed have priority 0
+func ParseClassPriorities(value string) (map[uint8]int, error) {
+	priorities := make(map[uint8]int)
+	if strings.TrimSpace(value) == "" {
+		return priorities, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid class priority %q", item)
+		}
+		classification, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid classification %q", parts[0])
+		}
+		priority := 1
+		if len(parts) == 2 {
+			priority, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil || priority < 0 {
+				return nil, fmt.Errorf("invalid priority %q for classification %d", parts[1], classification)
+			}
+		}
+		priorities[uint8(classification)] = priority
+	}
+
+	return priorities, nil
+}
+
 // Contains the options needed for the tiling algorithm
 type TilerOptions struct {
-	Input                  string     // Input LAS file/folder
-	Output                 string     // Output Cesium Tileset folder
-	Srid                   int        // EPSG code for SRID of input LAS points
-	EightBitColors         bool       // if true assume that LAS uses 8bit color depth
-	ZOffset                float64    // Z Offset in meters to apply to points during conversion
-	MaxNumPointsPerNode    int32      // Maximum allowed number of points per node for Random and RandomBox Algorithms
-	EnableGeoidZCorrection bool       // Enables the conversion from geoid to ellipsoid height
-	FolderProcessing       bool       // Enables the processing of all LAS files in folder
-	Recursive              bool       // Recursive lookup of LAS files in subfolders
-	Silent                 bool       // Suppressess console messages
-	Algorithm              Algorithm  // Algorithm to use
-	CellMaxSize            float64    // Max cell size for grid algorithm
-	CellMinSize            float64    // Min cell size for grid algorithm
-	RefineMode             RefineMode // Refine mode to use to generate the tileset
+	Input                   string               // Input LAS file/folder
+	Output                  string               // Output Cesium Tileset folder
+	Srid                    int                  // EPSG code for SRID of input LAS points
+	EightBitColors          bool                 // if true assume that LAS uses 8bit color depth
+	ZOffset                 float64              // Z Offset in meters to apply to points during conversion
+	MaxNumPointsPerNode     int32                // Maximum allowed number of points per node for Random and RandomBox Algorithms
+	EnableGeoidZCorrection  bool                 // Enables the conversion from geoid to ellipsoid height
+	GeoidModel              string               // Spherical harmonic model used by EnableGeoidZCorrection, egm180 or the path of a coefficients file. Empty for egm180
+	GeoidDegree             int                  // Degree the spherical harmonic model is truncated at, 0 for the default one of the model
+	GeoidGrid               *geoid.Grid          // Geoid grid converting the heights above the geoid to ellipsoid heights, used in place of the spherical harmonic model. nil if none
+	FolderProcessing        bool                 // Enables the processing of all LAS files in folder
+	Recursive               bool                 // Recursive lookup of LAS files in subfolders
+	Silent                  bool                 // Suppressess console messages
+	Algorithm               Algorithm            // Algorithm to use
+	CellMaxSize             float64              // Max cell size for grid algorithm
+	CellMinSize             float64              // Min cell size for grid algorithm
+	GridMaxNumPointsPerNode int32                // Maximum allowed number of points per node for grid algorithm, 0 means unlimited
+	GridMaxDepth            int                  // Maximum depth of the tree for grid algorithm, 0 means unlimited
+	GridBulkBuild           bool                 // Builds the grid tree in bulk, replaying the sampling of each subtree in parallel
+	ClassPriorities         map[uint8]int        // Priority of the classifications when grid cells pick their point, 0 if not listed
+	PointScorer             scorers.PointScorer  // Scores the points competing for a grid cell, nil to keep the closest to the center
+	RefineMode              RefineMode           // Refine mode to use to generate the tileset
+	MaxMemory               int                  // Memory budget in MB, 0 means unlimited
+	AutoSplit               bool                 // Splits the input in smaller jobs if the memory budget would be exceeded
+	Deterministic           bool                 // Loads points in file order with a single worker so that the output is reproducible
+	Seed                    int64                // Seed of the random number generators used to shuffle and sample the points
+	BoundsPercentile        float64              // Lower percentile of the coordinates bounding the root tile, the upper is 100 minus it. 0 uses the full extent
+	BoundsSigma             float64              // Max distance of the points from the mean coordinates in standard deviations, 0 keeps all points
+	NoiseRadius             float64              // Radius in meters of the neighbourhood searched by the noise filter
+	NoiseMinNeighbours      int                  // Min number of neighbours within the noise radius to keep a point, 0 disables the filter
+	NoiseKNearest           int                  // Number of nearest neighbours whose mean distance is checked by the noise filter, 0 disables the filter
+	NoiseMaxMeanDistance    float64              // Max mean distance in meters of a point from its nearest neighbours to keep it
+	PointFilter             *PointFilter         // Selects the points to read from the las files, nil to read all of them
+	Crop                    *crop.Area           // Area outside which points are discarded while reading, nil to keep all of them
+	Expressions             *expression.Program  // Filters and computed attributes evaluated on the points while reading, nil if none
+	Colorization            *raster.Raster       // Raster the colors of the points are sampled from, nil to keep the colors of the las files
+	ColorMode               colors.Mode          // Computes the colors of the points not covered by the colorization raster
+	ColorRamp               *colors.Ramp         // Ramp of the elevation, intensity and height above ground color modes, nil to use the default one of the mode
+	ColorMin                float64              // Value mapped to the start of the color ramp. If equal to ColorMax the range is computed automatically
+	ColorMax                float64              // Value mapped to the end of the color ramp. If equal to ColorMin the range is computed automatically
+	HeightAboveGround       bool                 // Computes the height of the points above the ground points and stores it in the batch table
+	GroundCellSize          float64              // Size in meters of the cells of the terrain model the heights above ground are measured from
+	Curvature               bool                 // Computes the local curvature of the points and stores it in the batch table
+	CurvatureRadius         float64              // Radius in meters of the neighbourhood the local curvature of the points is computed on
+	Transform               *transform.Affine    // Transform applied to the coordinates read from the las files, yielding coordinates in Srid. nil if none
+	Placement               *geometry.LocalFrame // Local frame the input coordinates are expressed in, in meters, instead of Srid. nil if they are in Srid
+}
+
+// Returns the names of the attributes computed while reading and loading the points, in the order they are stored in
+// the attributes of the points
+func (opts *TilerOptions) GetAttributeNames() []string {
+	var names []string
+	if opts.Expressions != nil {
+		names = append(names, opts.Expressions.GetAttributeNames()...)
+	}
+	if opts.HeightAboveGround {
+		names = append(names, point_loader.HeightAboveGroundAttribute)
+	}
+	if opts.Curvature {
+		names = append(names, point_loader.CurvatureAttribute)
+	}
+	return names
 }
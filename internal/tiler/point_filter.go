@@ -0,0 +1,79 @@
+package tiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ReturnFilter string
+
+const (
+	AllReturns   ReturnFilter = "ALL"
+	FirstReturns ReturnFilter = "FIRST"
+	LastReturns  ReturnFilter = "LAST"
+	OnlyReturns  ReturnFilter = "ONLY"
+)
+
+// Selects the points of the las files to process according to their classification, return number and flags
+type PointFilter struct {
+	IncludedClasses  map[uint8]bool // If not empty only the points with these classifications are processed
+	ExcludedClasses  map[uint8]bool // Points with these classifications are discarded
+	Returns          ReturnFilter   // Returns to process, either all of them, the first, the last or the only ones
+	ExcludeWithheld  bool           // Discards the points flagged as withheld
+	ExcludeSynthetic bool           // Discards the points flagged as synthetic
+}
+
+// Checks if a point with the given attributes passes the filter. A nil filter accepts all points
+func (f *PointFilter) Accepts(classification uint8, returnNumber uint8, numberOfReturns uint8, withheld bool, synthetic bool) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.IncludedClasses) > 0 && !f.IncludedClasses[classification] {
+		return false
+	}
+	if f.ExcludedClasses[classification] {
+		return false
+	}
+	if (f.ExcludeWithheld && withheld) || (f.ExcludeSynthetic && synthetic) {
+		return false
+	}
+
+	switch f.Returns {
+	case FirstReturns:
+		return returnNumber <= 1
+	case LastReturns:
+		return returnNumber >= numberOfReturns
+	case OnlyReturns:
+		return numberOfReturns <= 1
+	}
+	return true
+}
+
+// Parses a return filter, either "all", "first", "last" or "only"
+func ParseReturnFilter(value string) (ReturnFilter, error) {
+	returns := ReturnFilter(strings.ToUpper(strings.TrimSpace(value)))
+	switch returns {
+	case AllReturns, FirstReturns, LastReturns, OnlyReturns:
+		return returns, nil
+	}
+	return "", fmt.Errorf("unknown returns %q, must be one of all, first, last or only", value)
+}
+
+// Parses a comma separated list of classifications, e.g. "2,6"
+func ParseClassList(value string) (map[uint8]bool, error) {
+	classes := make(map[uint8]bool)
+	if strings.TrimSpace(value) == "" {
+		return classes, nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		classification, err := strconv.ParseUint(strings.TrimSpace(item), 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid classification %q", item)
+		}
+		classes[uint8(classification)] = true
+	}
+
+	return classes, nil
+}
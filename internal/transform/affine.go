@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Conventions defining the sign of the rotations of a Helmert transform
+type Convention string
+
+const (
+	// Rotations of the position vector in a fixed frame, as in EPSG method 9606 and in the default of PROJ
+	PositionVector Convention = "POSITION-VECTOR"
+
+	// Rotations of the coordinate frame around a fixed point, as in EPSG method 9607. Rotations have opposite sign
+	// than in the position vector convention
+	CoordinateFrame Convention = "COORDINATE-FRAME"
+)
+
+const arcSecondsToRadians = math.Pi / (180 * 3600)
+
+// Affine transform of 3D coordinates, stored as the first three rows of a 4x4 matrix in row order. Applying it to
+// x,y,z returns the product of the matrix with the column vector x,y,z,1
+type Affine struct {
+	matrix [12]float64
+}
+
+// Builds an affine transform from the first three rows of its 4x4 matrix, given in row order
+func NewAffine(matrix [12]float64) *Affine {
+	return &Affine{matrix: matrix}
+}
+
+// Builds a 7-parameter Helmert transform from the translations in meters, the rotations in arc seconds and the scale
+// difference in parts per million, using the linearized rotation matrix of the EPSG methods 9606 and 9607
+func NewHelmert(tx, ty, tz, rx, ry, rz, ppm float64, convention Convention) *Affine {
+	rx, ry, rz = rx*arcSecondsToRadians, ry*arcSecondsToRadians, rz*arcSecondsToRadians
+	if convention == CoordinateFrame {
+		rx, ry, rz = -rx, -ry, -rz
+	}
+	m := 1 + ppm*1e-6
+
+	return NewAffine([12]float64{
+		m, -rz * m, ry * m, tx,
+		rz * m, m, -rx * m, ty,
+		-ry * m, rx * m, m, tz,
+	})
+}
+
+// Returns the first three rows of the 4x4 matrix of the transform in row order, as accepted by Parse
+func (a *Affine) String() string {
+	values := make([]string, len(a.matrix))
+	for i, value := range a.matrix {
+		values[i] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strings.Join(values, ",")
+}
+
+// Returns the transformed coordinates
+func (a *Affine) Apply(x, y, z float64) (float64, float64, float64) {
+	m := &a.matrix
+	return m[0]*x + m[1]*y + m[2]*z + m[3],
+		m[4]*x + m[5]*y + m[6]*z + m[7],
+		m[8]*x + m[9]*y + m[10]*z + m[11]
+}
+
+// Returns the smallest box containing the transformed corners of the given box
+func (a *Affine) ApplyToBounds(bounds *geometry.BoundingBox) *geometry.BoundingBox {
+	min := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, x := range []float64{bounds.Xmin, bounds.Xmax} {
+		for _, y := range []float64{bounds.Ymin, bounds.Ymax} {
+			for _, z := range []float64{bounds.Zmin, bounds.Zmax} {
+				tx, ty, tz := a.Apply(x, y, z)
+				for i, value := range []float64{tx, ty, tz} {
+					min[i] = math.Min(min[i], value)
+					max[i] = math.Max(max[i], value)
+				}
+			}
+		}
+	}
+
+	return geometry.NewBoundingBox(min[0], max[0], min[1], max[1], min[2], max[2])
+}
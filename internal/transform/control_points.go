@@ -0,0 +1,245 @@
+package transform
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Ground control point surveyed both in the local system of a scan and in the target reference system
+type ControlPoint struct {
+	Name   string
+	Local  geometry.Coordinate
+	Target geometry.Coordinate
+}
+
+// Reads the control points from a CSV file whose rows store the local x,y,z followed by the target x,y,z, optionally
+// preceded by the name of the point. Values can be separated by commas, semicolons or tabs. The first row is skipped
+// as a header only if none of its values is a number. Unnamed points are named after their row number
+func ReadControlPoints(filePath string) ([]ControlPoint, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(strings.NewReader(string(content)))
+	reader.Comma = detectSeparator(string(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var points []ControlPoint
+	for row, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) != 6 && len(record) != 7 {
+			return nil, fmt.Errorf("row %d: expected 6 or 7 values, got %d", row+1, len(record))
+		}
+		name := strconv.Itoa(row + 1)
+		if len(record) == 7 {
+			name, record = strings.TrimSpace(record[0]), record[1:]
+		}
+		if row == 0 && isHeader(record) {
+			continue
+		}
+		var values [6]float64
+		for i, value := range record {
+			values[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: invalid coordinate %q", row+1, strings.TrimSpace(value))
+			}
+		}
+		points = append(points, ControlPoint{
+			Name:   name,
+			Local:  geometry.Coordinate{X: values[0], Y: values[1], Z: values[2]},
+			Target: geometry.Coordinate{X: values[3], Y: values[4], Z: values[5]},
+		})
+	}
+
+	return points, nil
+}
+
+// checks if the given record is a header, i.e. none of its values is a number
+func isHeader(record []string) bool {
+	for _, value := range record {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the first of tab, semicolon and comma found in the given content, comma if none is found
+func detectSeparator(content string) rune {
+	for _, separator := range []rune{'\t', ';'} {
+		if strings.ContainsRune(content, separator) {
+			return separator
+		}
+	}
+	return ','
+}
+
+// Solves the similarity transform, made of a rotation, a uniform scale and a translation, mapping the local coordinates
+// of the given control points to their target coordinates with the least squared residuals. The rotation is computed
+// with the closed form quaternion solution by Horn, the scale and translation as per Umeyama. At least three control
+// points not lying on a line are needed
+func SolveSimilarity(points []ControlPoint) (*Affine, error) {
+	if len(points) < 3 {
+		return nil, fmt.Errorf("at least 3 control points are needed, got %d", len(points))
+	}
+
+	var localCentroid, targetCentroid [3]float64
+	for _, point := range points {
+		local, target := toArray(point.Local), toArray(point.Target)
+		for i := 0; i < 3; i++ {
+			localCentroid[i] += local[i] / float64(len(points))
+			targetCentroid[i] += target[i] / float64(len(points))
+		}
+	}
+
+	// cross covariance of the centered coordinates and spread of the local ones
+	var s [3][3]float64
+	var localSpread float64
+	for _, point := range points {
+		local, target := toArray(point.Local), toArray(point.Target)
+		for i := 0; i < 3; i++ {
+			local[i] -= localCentroid[i]
+			target[i] -= targetCentroid[i]
+			localSpread += local[i] * local[i]
+		}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				s[i][j] += local[i] * target[j]
+			}
+		}
+	}
+	if localSpread == 0 {
+		return nil, errors.New("the local coordinates of the control points are all equal")
+	}
+
+	n := [4][4]float64{
+		{s[0][0] + s[1][1] + s[2][2], s[1][2] - s[2][1], s[2][0] - s[0][2], s[0][1] - s[1][0]},
+		{s[1][2] - s[2][1], s[0][0] - s[1][1] - s[2][2], s[0][1] + s[1][0], s[2][0] + s[0][2]},
+		{s[2][0] - s[0][2], s[0][1] + s[1][0], -s[0][0] + s[1][1] - s[2][2], s[1][2] + s[2][1]},
+		{s[0][1] - s[1][0], s[2][0] + s[0][2], s[1][2] + s[2][1], -s[0][0] - s[1][1] + s[2][2]},
+	}
+	eigenvalues, eigenvectors := getSymmetricEigen(n)
+	largest, second := 0, -1
+	for i := 1; i < 4; i++ {
+		if eigenvalues[i] > eigenvalues[largest] {
+			largest = i
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if i != largest && (second < 0 || eigenvalues[i] > eigenvalues[second]) {
+			second = i
+		}
+	}
+	// the rotation around the line through collinear points is undetermined and the largest eigenvalue is repeated
+	if eigenvalues[largest]-eigenvalues[second] <= 1e-9*math.Abs(eigenvalues[largest]) {
+		return nil, errors.New("the control points lie on a line")
+	}
+
+	w, x, y, z := eigenvectors[0][largest], eigenvectors[1][largest], eigenvectors[2][largest], eigenvectors[3][largest]
+	rotation := [3][3]float64{
+		{w*w + x*x - y*y - z*z, 2 * (x*y - w*z), 2 * (x*z + w*y)},
+		{2 * (x*y + w*z), w*w - x*x + y*y - z*z, 2 * (y*z - w*x)},
+		{2 * (x*z - w*y), 2 * (y*z + w*x), w*w - x*x - y*y + z*z},
+	}
+
+	// trace of the rotation times the cross covariance, i.e. the sum of the dot products of the rotated local
+	// coordinates with the target ones
+	var dotProducts float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			dotProducts += rotation[i][j] * s[j][i]
+		}
+	}
+	scale := dotProducts / localSpread
+
+	var matrix [12]float64
+	for i := 0; i < 3; i++ {
+		translation := targetCentroid[i]
+		for j := 0; j < 3; j++ {
+			matrix[i*4+j] = scale * rotation[i][j]
+			translation -= scale * rotation[i][j] * localCentroid[j]
+		}
+		matrix[i*4+3] = translation
+	}
+
+	return NewAffine(matrix), nil
+}
+
+// Returns the distance between the target coordinates of the given control point and its transformed local ones
+func (a *Affine) GetResidual(point ControlPoint) float64 {
+	x, y, z := a.Apply(point.Local.X, point.Local.Y, point.Local.Z)
+	dx, dy, dz := x-point.Target.X, y-point.Target.Y, z-point.Target.Z
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+func toArray(coordinate geometry.Coordinate) [3]float64 {
+	return [3]float64{coordinate.X, coordinate.Y, coordinate.Z}
+}
+
+// returns the eigenvalues of the given symmetric matrix and the matrix whose columns are the corresponding
+// eigenvectors, computed with the cyclic Jacobi method
+func getSymmetricEigen(a [4][4]float64) ([4]float64, [4][4]float64) {
+	var v [4][4]float64
+	for i := range v {
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		var offDiagonal float64
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				offDiagonal += a[p][q] * a[p][q]
+			}
+		}
+		if offDiagonal == 0 {
+			break
+		}
+
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				// a = J^T a J, with J the rotation in the p,q plane
+				for k := 0; k < 4; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 4; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	return [4]float64{a[0][0], a[1][1], a[2][2], a[3][3]}, v
+}
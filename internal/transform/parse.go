@@ -0,0 +1,62 @@
+package transform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Parses a transform given as 7 Helmert parameters "tx,ty,tz,rx,ry,rz,ppm", with translations in meters, rotations
+// in arc seconds and scale difference in parts per million, or as the 12 or 16 values of an affine 4x4 matrix in row
+// order, whose last row must be 0,0,0,1 if given. Values can be separated by commas or white spaces. The value can
+// also be the path of a file containing them
+func Parse(value string, convention Convention) (*Affine, error) {
+	if _, err := os.Stat(value); err == nil {
+		content, err := ioutil.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		value = string(content)
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("invalid transform value %q", part)
+		}
+		values[i] = number
+	}
+
+	switch len(values) {
+	case 7:
+		return NewHelmert(values[0], values[1], values[2], values[3], values[4], values[5], values[6], convention), nil
+	case 16:
+		if values[12] != 0 || values[13] != 0 || values[14] != 0 || values[15] != 1 {
+			return nil, fmt.Errorf("invalid matrix, the last row must be 0,0,0,1 for an affine transform")
+		}
+		fallthrough
+	case 12:
+		var matrix [12]float64
+		copy(matrix[:], values)
+		return NewAffine(matrix), nil
+	}
+
+	return nil, fmt.Errorf("expected 7 Helmert parameters or 12 or 16 matrix values, got %d values", len(values))
+}
+
+// Returns the convention with the given name, which can be position-vector or coordinate-frame
+func ParseConvention(name string) (Convention, error) {
+	switch convention := Convention(strings.ToUpper(strings.TrimSpace(name))); convention {
+	case PositionVector, CoordinateFrame:
+		return convention, nil
+	}
+
+	return "", fmt.Errorf("unknown convention %q", name)
+}
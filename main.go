@@ -24,13 +24,25 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/crop"
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geoid"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scorers"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/internal/transform"
 	"github.com/mfbonfigli/gocesiumtiler/pkg"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
@@ -67,6 +79,19 @@ func main() {
 		return
 	}
 
+	// only solves the transform of the control points and reports its residuals
+	if *flags.ControlPointsCheck {
+		if *flags.ControlPoints == "" {
+			log.Fatal("Error parsing input parameters: gcp-check requires the gcp flag")
+		}
+		similarity, err := solveControlPointsTransform(*flags.ControlPoints)
+		if err != nil {
+			log.Fatal("Error parsing input parameters: ", err)
+		}
+		tools.LogOutput("> transform:", similarity.String())
+		return
+	}
+
 	// set logging and timestamp logging
 	if *flags.Silent {
 		tools.DisableLogger()
@@ -77,22 +102,115 @@ func main() {
 		tools.DisableLoggerTimestamp()
 	}
 
+	classPriorities, err := tiler.ParseClassPriorities(*flags.PriorityClasses)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: priority-classes: ", err)
+	}
+
+	pointFilter, err := parsePointFilter(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	cropArea, err := parseCropArea(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	expressions, err := parseExpressions(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	colorization, err := openColorizationRaster(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	geoidGrid, err := readGeoidGrid(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	colorMode, err := colors.ParseMode(*flags.ColorMode)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: color-mode: ", err)
+	}
+
+	colorRamp, err := colors.ParseRamp(*flags.ColorRamp)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: color-ramp: ", err)
+	}
+
+	// the range is computed automatically only if neither bound is given, hence given bounds cannot be equal
+	if (tools.IsFlagSet("color-min") || tools.IsFlagSet("color-max")) && *flags.ColorMin == *flags.ColorMax {
+		log.Fatal("Error parsing input parameters: color-min must be lower than color-max")
+	}
+
+	coordinateTransform, err := parseTransform(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
+	placement, err := parsePlacement(flags)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: ", err)
+	}
+
 	// Put args inside a TilerOptions struct
 	opts := tiler.TilerOptions{
-		Input:                  *flags.Input,
-		Output:                 *flags.Output,
-		Srid:                   *flags.Srid,
-		EightBitColors:         *flags.EightBitColors,
-		ZOffset:                *flags.ZOffset,
-		MaxNumPointsPerNode:    int32(*flags.MaxNumPts),
-		EnableGeoidZCorrection: *flags.ZGeoidCorrection,
-		FolderProcessing:       *flags.FolderProcessing,
-		Recursive:              *flags.RecursiveFolderProcessing,
-		Silent:                 *flags.Silent,
-		Algorithm:              tiler.Algorithm(strings.ToUpper(*flags.Algorithm)),
-		CellMinSize:            *flags.GridCellMinSize,
-		CellMaxSize:            *flags.GridCellMaxSize,
-		RefineMode:             tiler.ParseRefineMode(*flags.RefineMode),
+		Input:                   *flags.Input,
+		Output:                  *flags.Output,
+		Srid:                    *flags.Srid,
+		EightBitColors:          *flags.EightBitColors,
+		ZOffset:                 *flags.ZOffset,
+		MaxNumPointsPerNode:     int32(*flags.MaxNumPts),
+		EnableGeoidZCorrection:  *flags.ZGeoidCorrection,
+		GeoidModel:              *flags.GeoidModel,
+		GeoidDegree:             *flags.GeoidDegree,
+		GeoidGrid:               geoidGrid,
+		FolderProcessing:        *flags.FolderProcessing,
+		Recursive:               *flags.RecursiveFolderProcessing,
+		Silent:                  *flags.Silent,
+		Algorithm:               tiler.Algorithm(strings.ToUpper(*flags.Algorithm)),
+		CellMinSize:             *flags.GridCellMinSize,
+		CellMaxSize:             *flags.GridCellMaxSize,
+		GridMaxNumPointsPerNode: int32(*flags.GridMaxNumPts),
+		GridMaxDepth:            *flags.GridMaxDepth,
+		GridBulkBuild:           *flags.GridBulkBuild,
+		ClassPriorities:         classPriorities,
+		RefineMode:              tiler.ParseRefineMode(*flags.RefineMode),
+		MaxMemory:               *flags.MaxMemory,
+		AutoSplit:               *flags.AutoSplit,
+		Deterministic:           *flags.Deterministic,
+		Seed:                    time.Now().UnixNano(),
+		BoundsPercentile:        *flags.BoundsPercentile,
+		BoundsSigma:             *flags.OutlierSigma,
+		NoiseRadius:             *flags.NoiseRadius,
+		NoiseMinNeighbours:      *flags.NoiseMinNeighbours,
+		NoiseKNearest:           *flags.NoiseKNearest,
+		NoiseMaxMeanDistance:    *flags.NoiseMaxMeanDistance,
+		PointFilter:             pointFilter,
+		Crop:                    cropArea,
+		Expressions:             expressions,
+		Colorization:            colorization,
+		ColorMode:               colorMode,
+		ColorRamp:               colorRamp,
+		ColorMin:                *flags.ColorMin,
+		ColorMax:                *flags.ColorMax,
+		HeightAboveGround:       *flags.HeightAboveGround || colorMode == colors.ModeHeightAboveGround,
+		GroundCellSize:          *flags.GroundCellSize,
+		Curvature:               strings.EqualFold(strings.TrimSpace(*flags.GridScore), "curvature"),
+		CurvatureRadius:         *flags.CurvatureRadius,
+		Transform:               coordinateTransform,
+		Placement:               placement,
+	}
+	if opts.Deterministic {
+		opts.Seed = int64(*flags.Seed)
+	}
+	opts.PointScorer, err = scorers.ParsePointScorer(*flags.GridScore, opts.GetAttributeNames())
+	if err != nil {
+		log.Fatal("Error parsing input parameters: grid-score: ", err)
 	}
 
 	// Validate TilerOptions
@@ -102,7 +220,7 @@ func main() {
 
 	// Starts the tiler
 	// defer timeTrack(time.Now(), "tiler")
-	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts)).RunTiler(&opts)
+	err = pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts)).RunTiler(&opts)
 
 	if err != nil {
 		log.Fatal("Error while tiling: ", err)
@@ -125,13 +243,284 @@ func validateOptions(opts *tiler.TilerOptions) (string, bool) {
 		return "grid-max-size parameter cannot be lower than grid-min-size parameter", false
 	}
 
+	if opts.Algorithm == tiler.KdTree && opts.MaxNumPointsPerNode <= 0 {
+		return "maxpts must be greater than zero for the KdTree algorithm", false
+	}
+
+	if opts.GridMaxNumPointsPerNode < 0 {
+		return "grid-max-points cannot be negative", false
+	}
+
+	if opts.GridMaxDepth < 0 {
+		return "grid-max-depth cannot be negative", false
+	}
+
 	if opts.RefineMode == "" {
 		return "refine-mode should be either ADD or REPLACE", false
 	}
 
+	if opts.MaxMemory < 0 {
+		return "max-memory cannot be negative", false
+	}
+
+	if opts.BoundsPercentile < 0 || opts.BoundsPercentile >= 50 {
+		return "bounds-percentile must be between 0 and 50", false
+	}
+
+	if opts.BoundsSigma < 0 {
+		return "outlier-sigma cannot be negative", false
+	}
+
+	if opts.NoiseMinNeighbours < 0 || opts.NoiseKNearest < 0 {
+		return "noise-min-neighbours and noise-knn cannot be negative", false
+	}
+
+	noiseFilterEnabled := opts.NoiseMinNeighbours > 0 || opts.NoiseKNearest > 0
+	if noiseFilterEnabled && (opts.NoiseRadius <= 0 || opts.NoiseMaxMeanDistance <= 0) {
+		return "noise-radius and noise-knn-max-distance must be greater than zero", false
+	}
+
+	if noiseFilterEnabled && (opts.Algorithm == tiler.Random || opts.Algorithm == tiler.RandomBox) {
+		return "noise filters are not supported by the Random and RandomBox algorithms", false
+	}
+
+	if opts.ColorMin > opts.ColorMax {
+		return "color-min cannot be greater than color-max", false
+	}
+
+	if opts.HeightAboveGround && opts.GroundCellSize <= 0 {
+		return "ground-cell-size must be greater than zero", false
+	}
+
+	if opts.Curvature && opts.CurvatureRadius <= 0 {
+		return "curvature-radius must be greater than zero", false
+	}
+
+	if opts.Curvature && (opts.Algorithm == tiler.Random || opts.Algorithm == tiler.RandomBox) {
+		return "the curvature grid-score is not supported by the Random and RandomBox algorithms", false
+	}
+
+	if opts.HeightAboveGround && (opts.Algorithm == tiler.Random || opts.Algorithm == tiler.RandomBox) {
+		return "height-above-ground is not supported by the Random and RandomBox algorithms", false
+	}
+
+	if opts.GeoidDegree < 0 {
+		return "geoid-degree cannot be negative", false
+	}
+
+	customGeoidModel := opts.GeoidDegree != 0 || (opts.GeoidModel != "" && !strings.EqualFold(opts.GeoidModel, gh_offset_calculator.DefaultModel))
+	if customGeoidModel && (!opts.EnableGeoidZCorrection || opts.GeoidGrid != nil) {
+		return "geoid-model and geoid-degree can only be used together with geoid and not with geoid-grid", false
+	}
+
+	if opts.Placement != nil {
+		if opts.Algorithm == tiler.Random || opts.Algorithm == tiler.RandomBox {
+			return "local-origin is not supported by the Random and RandomBox algorithms", false
+		}
+		if opts.EnableGeoidZCorrection || opts.GeoidGrid != nil || opts.Colorization != nil {
+			return "local-origin cannot be used together with geoid, geoid-grid and colorize", false
+		}
+		if opts.Crop != nil && opts.Crop.Srid != opts.Srid {
+			return "local-origin cannot be used together with crop-srid", false
+		}
+	}
+
+	if opts.HeightAboveGround && opts.Expressions != nil {
+		for _, name := range opts.Expressions.GetAttributeNames() {
+			if strings.EqualFold(name, point_loader.HeightAboveGroundAttribute) {
+				return "expressions cannot define the " + point_loader.HeightAboveGroundAttribute + " attribute", false
+			}
+		}
+	}
+
+	if opts.Curvature && opts.Expressions != nil {
+		for _, name := range opts.Expressions.GetAttributeNames() {
+			if strings.EqualFold(name, point_loader.CurvatureAttribute) {
+				return "expressions cannot define the " + point_loader.CurvatureAttribute + " attribute", false
+			}
+		}
+	}
+
 	return "", true
 }
 
+// Builds the filter selecting the points to read from the las files
+func parsePointFilter(flags tools.Flags) (*tiler.PointFilter, error) {
+	includedClasses, err := tiler.ParseClassList(*flags.IncludeClasses)
+	if err != nil {
+		return nil, fmt.Errorf("include-classes: %v", err)
+	}
+	excludedClasses, err := tiler.ParseClassList(*flags.ExcludeClasses)
+	if err != nil {
+		return nil, fmt.Errorf("exclude-classes: %v", err)
+	}
+	returns, err := tiler.ParseReturnFilter(*flags.Returns)
+	if err != nil {
+		return nil, fmt.Errorf("returns: %v", err)
+	}
+
+	return &tiler.PointFilter{
+		IncludedClasses:  includedClasses,
+		ExcludedClasses:  excludedClasses,
+		Returns:          returns,
+		ExcludeWithheld:  *flags.ExcludeWithheld,
+		ExcludeSynthetic: *flags.ExcludeSynthetic,
+	}, nil
+}
+
+// Builds the area to crop the points to, if any, expressed in the crop srid
+func parseCropArea(flags tools.Flags) (*crop.Area, error) {
+	srid := *flags.CropSrid
+	if srid == 0 {
+		srid = *flags.Srid
+	}
+
+	if *flags.CropBox != "" && *flags.CropPolygon != "" {
+		return nil, fmt.Errorf("crop-box and crop-polygon cannot be used together")
+	}
+	if *flags.CropBox != "" {
+		area, err := crop.ParseBox(*flags.CropBox, srid)
+		if err != nil {
+			return nil, fmt.Errorf("crop-box: %v", err)
+		}
+		return area, nil
+	}
+	if *flags.CropPolygon != "" {
+		area, err := crop.ParsePolygon(*flags.CropPolygon, srid)
+		if err != nil {
+			return nil, fmt.Errorf("crop-polygon: %v", err)
+		}
+		return area, nil
+	}
+
+	return nil, nil
+}
+
+// Builds the program of filters and computed attributes, if any. The statements of the expressions file come before
+// the ones given on the command line
+func parseExpressions(flags tools.Flags) (*expression.Program, error) {
+	source := *flags.Expressions
+	if *flags.ExpressionsFile != "" {
+		content, err := ioutil.ReadFile(*flags.ExpressionsFile)
+		if err != nil {
+			return nil, fmt.Errorf("expressions-file: %v", err)
+		}
+		source = string(content) + "\n" + source
+	}
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+
+	program, err := expression.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("expressions: %v", err)
+	}
+
+	return program, nil
+}
+
+// Builds the transform to apply to the coordinates of the input points, if any, either parsing it or solving it from
+// the ground control points
+func parseTransform(flags tools.Flags) (*transform.Affine, error) {
+	convention, err := transform.ParseConvention(*flags.TransformConvention)
+	if err != nil {
+		return nil, fmt.Errorf("transform-convention: %v", err)
+	}
+	if *flags.ControlPoints != "" {
+		if strings.TrimSpace(*flags.Transform) != "" {
+			return nil, fmt.Errorf("transform and gcp cannot be used together")
+		}
+		return solveControlPointsTransform(*flags.ControlPoints)
+	}
+	if strings.TrimSpace(*flags.Transform) == "" {
+		return nil, nil
+	}
+
+	coordinateTransform, err := transform.Parse(*flags.Transform, convention)
+	if err != nil {
+		return nil, fmt.Errorf("transform: %v", err)
+	}
+
+	return coordinateTransform, nil
+}
+
+// Builds the local frame the input coordinates are placed in, if any
+func parsePlacement(flags tools.Flags) (*geometry.LocalFrame, error) {
+	if strings.TrimSpace(*flags.LocalOrigin) == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(*flags.LocalOrigin, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("local-origin: expected longitude,latitude,height, got %q", *flags.LocalOrigin)
+	}
+	var values [3]float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("local-origin: invalid value %q", part)
+		}
+		values[i] = value
+	}
+	if math.Abs(values[0]) > 180 || math.Abs(values[1]) > 90 {
+		return nil, fmt.Errorf("local-origin: longitude or latitude out of range")
+	}
+
+	return geometry.NewLocalFrameWithHeading(values[0], values[1], values[2], *flags.LocalHeading), nil
+}
+
+// Solves the similarity transform best fitting the ground control points stored in the given file and logs its
+// residuals
+func solveControlPointsTransform(filePath string) (*transform.Affine, error) {
+	points, err := transform.ReadControlPoints(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: %v", err)
+	}
+	similarity, err := transform.SolveSimilarity(points)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: %v", err)
+	}
+
+	tools.LogOutput("> ground control points residuals:")
+	var sumOfSquares float64
+	for _, point := range points {
+		residual := similarity.GetResidual(point)
+		sumOfSquares += residual * residual
+		tools.LogOutput("  ", point.Name, strconv.FormatFloat(residual, 'f', 4, 64))
+	}
+	tools.LogOutput("   RMS", strconv.FormatFloat(math.Sqrt(sumOfSquares/float64(len(points))), 'f', 4, 64))
+
+	return similarity, nil
+}
+
+// Opens the raster to sample the colors of the points from, if any
+func openColorizationRaster(flags tools.Flags) (*raster.Raster, error) {
+	if *flags.Colorize == "" {
+		return nil, nil
+	}
+
+	colorization, err := raster.Open(*flags.Colorize, *flags.ColorizeSrid)
+	if err != nil {
+		return nil, fmt.Errorf("colorize: %v", err)
+	}
+
+	return colorization, nil
+}
+
+// Reads the geoid grid to convert the elevations above the geoid with, if any
+func readGeoidGrid(flags tools.Flags) (*geoid.Grid, error) {
+	if *flags.GeoidGrid == "" {
+		return nil, nil
+	}
+
+	grid, err := geoid.ReadGrid(*flags.GeoidGrid)
+	if err != nil {
+		return nil, fmt.Errorf("geoid-grid: %v", err)
+	}
+
+	return grid, nil
+}
+
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
 	tools.LogOutput(fmt.Sprintf("%s took %s", name, elapsed))
@@ -4,12 +4,16 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/geoid_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/grid_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/pipeline_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/kd_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/poisson_tree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/random_trees"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/voxel_tree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager"
 	"log"
@@ -23,8 +27,7 @@ type StandardAlgorithmManager struct {
 
 func NewAlgorithmManager(opts *tiler.TilerOptions) algorithm_manager.AlgorithmManager {
 	coordinateConverter := proj4_coordinate_converter.NewProj4CoordinateConverter()
-	ellipsoidToGeoidOffsetCalculator := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculator(coordinateConverter)
-	elevationCorrectionAlgorithm := evaluateElevationCorrectionAlgorithm(opts, ellipsoidToGeoidOffsetCalculator, coordinateConverter)
+	elevationCorrectionAlgorithm := evaluateElevationCorrectionAlgorithm(opts, coordinateConverter)
 
 	algorithmManager := &StandardAlgorithmManager{
 		options:             opts,
@@ -47,12 +50,19 @@ func (am *StandardAlgorithmManager) GetCoordinateConverterAlgorithm() converters
 	return am.coordinateConverter
 }
 
-func evaluateElevationCorrectionAlgorithm(options *tiler.TilerOptions, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator, converter converters.CoordinateConverter) converters.ElevationCorrector {
+func evaluateElevationCorrectionAlgorithm(options *tiler.TilerOptions, converter converters.CoordinateConverter) converters.ElevationCorrector {
 	var elevationCorrectors []converters.ElevationCorrector
 	elevationCorrectors = append(elevationCorrectors, offset_elevation_corrector.NewOffsetElevationCorrector(options.ZOffset))
 
-	if options.EnableGeoidZCorrection {
-		elevationCorrectors = append(elevationCorrectors, geoid_elevation_corrector.NewGeoidElevationCorrector(options.Srid, ellipsoidToGeoidOffsetCalculator))
+	if options.GeoidGrid != nil {
+		elevationCorrectors = append(elevationCorrectors, grid_elevation_corrector.NewGridElevationCorrector(options.GeoidGrid))
+	} else if options.EnableGeoidZCorrection {
+		ellipsoidToGeoidOffsetCalculator, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, options.GeoidModel, options.GeoidDegree)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// the trees correct the elevations after converting the coordinates to EPSG:4326
+		elevationCorrectors = append(elevationCorrectors, geoid_elevation_corrector.NewGeoidElevationCorrector(4326, ellipsoidToGeoidOffsetCalculator))
 	}
 
 	return pipeline_elevation_corrector.NewPipelineElevationCorrector(elevationCorrectors)
@@ -61,7 +71,15 @@ func evaluateElevationCorrectionAlgorithm(options *tiler.TilerOptions, ellipsoid
 func evaluateTreeAlgorithm(options *tiler.TilerOptions, converter converters.CoordinateConverter, elevationCorrection converters.ElevationCorrector) octree.ITree {
 	switch options.Algorithm {
 	case tiler.Grid:
-		return grid_tree.NewGridTree(converter, elevationCorrection, options.CellMaxSize, options.CellMinSize)
+		return grid_tree.NewGridTree(options, converter, elevationCorrection)
+	case tiler.Quadtree:
+		return grid_tree.NewQuadTree(options, converter, elevationCorrection)
+	case tiler.KdTree:
+		return kd_tree.NewKdTree(options, converter, elevationCorrection)
+	case tiler.Voxel:
+		return voxel_tree.NewVoxelTree(options, converter, elevationCorrection)
+	case tiler.Poisson:
+		return poisson_tree.NewPoissonTree(options, converter, elevationCorrection)
 	case tiler.RandomBox:
 		return random_trees.NewBoxedRandomTree(options, converter, elevationCorrection)
 	case tiler.Random:
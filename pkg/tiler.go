@@ -3,13 +3,22 @@ package pkg
 import (
 	"errors"
 	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/crop"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/io"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager"
 	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
 	"log"
+	"math"
+	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -39,45 +48,286 @@ func (tiler *Tiler) RunTiler(opts *tiler.TilerOptions) error {
 	// Prepare list of files to process
 	lasFiles := tiler.fileFinder.GetLasFilesToProcess(opts)
 
+	cropArea, err := tiler.getCropArea(opts)
+	if err != nil {
+		return err
+	}
+
+	colorize, err := tiler.getColorizer(lasFiles, opts)
+	if err != nil {
+		return err
+	}
+
 	// load las points in octree buffer
+	exported := false
 	for i, filePath := range lasFiles {
-		// Define point_loader strategy
-		var tree = tiler.algorithmManager.GetTreeAlgorithm()
 		tools.LogOutput("Processing file " + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(lasFiles)))
-		tiler.processLasFile(filePath, opts, tree)
+		fileExported, err := tiler.processLasFile(filePath, opts, cropArea, colorize)
+		if err != nil {
+			return err
+		}
+		exported = exported || fileExported
 	}
 	tiler.algorithmManager.GetCoordinateConverterAlgorithm().Cleanup()
 
+	if !exported {
+		return errors.New("no point passes the crop area and the filters, no tileset has been exported")
+	}
+
 	return nil
 }
 
-func (tiler *Tiler) processLasFile(filePath string, opts *tiler.TilerOptions, tree octree.ITree) {
-	// Create empty octree
-	tiler.readLasData(filePath, opts, tree)
-	tiler.prepareDataStructure(tree)
-	tiler.exportToCesiumTileset(tree, opts, getFilenameWithoutExtension(filePath))
+// Returns the crop area of the options, if any, reprojected to the srid of the input points
+func (tiler *Tiler) getCropArea(opts *tiler.TilerOptions) (*crop.Area, error) {
+	if opts.Crop == nil {
+		return nil, nil
+	}
+
+	return opts.Crop.Reproject(tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.Srid)
+}
+
+// Processes the given las file returning whether a tileset has been exported for it, which is not the case if no point
+// passes the crop area and the filters
+func (tiler *Tiler) processLasFile(filePath string, opts *tiler.TilerOptions, cropArea *crop.Area, colorize lidario.PointColorizer) (bool, error) {
+	jobs, bounds, err := tiler.planJobs(filePath, opts)
+	if err != nil {
+		return false, err
+	}
+
+	fileName := getFilenameWithoutExtension(filePath)
+	exported := true
+	if len(jobs) == 1 && jobs[0].IsWholeCloud() {
+		// Define point_loader strategy
+		var tree = tiler.algorithmManager.GetTreeAlgorithm()
+		anchorLocalFrame(tree, bounds, opts)
+		if tiler.readLasData(filePath, opts, tree, getCropFilter(cropArea), colorize) == 0 {
+			exported = false
+		} else {
+			tiler.prepareDataStructure(tree)
+			tiler.exportToCesiumTileset(tree, opts, fileName)
+		}
+	} else {
+		exported, err = tiler.processJobs(filePath, opts, jobs, fileName, cropArea, colorize)
+		if err != nil {
+			return false, err
+		}
+	}
 
+	if !exported {
+		tools.LogOutput("> WARNING: no point of", filepath.Base(filePath), "passes the crop area and the filters, skipping it")
+		return false, nil
+	}
 	tools.LogOutput("> done processing", filepath.Base(filePath))
+	return true, nil
+}
+
+// Returns a function accepting the points within the given crop area, nil to accept all of them if it is nil
+func getCropFilter(cropArea *crop.Area) func(x, y, z float64) bool {
+	if cropArea == nil {
+		return nil
+	}
+	return cropArea.Contains
+}
+
+// Estimates the resources needed to process the given las file, reading only its header, and plans the jobs needed
+// to process it within the memory budget. Returns also the bounds of the file as declared in its header
+func (tiler *Tiler) planJobs(filePath string, opts *tiler.TilerOptions) ([]*preflight.Job, *geometry.BoundingBox, error) {
+	header, err := lidario.ReadLasHeader(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := getHeaderBounds(header, opts)
+	info := preflight.CloudInfo{
+		NumberOfPoints: header.NumberPoints,
+		Bounds:         bounds,
+		CountPoints: func() (*preflight.PointCounts, error) {
+			tools.LogOutput("> counting points to split the input...")
+			counts := preflight.NewPointCounts(bounds)
+			err := lidario.ScanLasFile(filePath, opts.Transform, func(x, y, z float64, record []byte) error {
+				counts.Add(x, y)
+				return nil
+			})
+			return counts, err
+		},
+	}
+	jobs, estimate, err := preflight.PlanJobs(info, opts)
+	tools.LogOutput(
+		"> estimated resources:", strconv.Itoa(estimate.NumberOfPoints), "points,",
+		preflight.FormatBytes(estimate.PeakMemory), "peak memory,",
+		preflight.FormatBytes(estimate.OutputSize), "output size",
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(jobs) > 1 {
+		tools.LogOutput("> input exceeds the memory budget, splitting it in", strconv.Itoa(len(jobs)), "jobs")
+	}
+
+	return jobs, info.Bounds, nil
 }
 
-func (tiler *Tiler) readLasData(filePath string, opts *tiler.TilerOptions, tree octree.ITree) {
+// Processes each job in a separate tree exporting it as a tileset in its own subfolder, then writes a tileset.json
+// file referencing all of them. The points of the las file are first written in a temporary las file for each job, so
+// that the input is read only once whatever the number of jobs. Returns false if all jobs are empty, in which case no
+// tileset is written
+func (tiler *Tiler) processJobs(filePath string, opts *tiler.TilerOptions, jobs []*preflight.Job, fileName string, cropArea *crop.Area, colorize lidario.PointColorizer) (bool, error) {
+	partsFolder, err := ioutil.TempDir(opts.Output, "parts")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.RemoveAll(partsFolder) }()
+
+	tools.LogOutput("> writing the points of each job in a temporary file...")
+	partPaths := make([]string, len(jobs))
+	for i, job := range jobs {
+		partPaths[i] = path.Join(partsFolder, job.Name+".las")
+	}
+	if _, err := lidario.SplitLasFile(filePath, opts.Transform, partPaths, preflight.NewJobLocator(jobs).Locate); err != nil {
+		return false, err
+	}
+
+	var children []io.Child
+	refineMode := opts.RefineMode
+	for i, job := range jobs {
+		tools.LogOutput("> processing job " + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(jobs)))
+		var tree = tiler.algorithmManager.GetTreeAlgorithm()
+		anchorLocalFrame(tree, job.Extent, opts)
+		numberOfPoints := tiler.readLasData(partPaths[i], opts, tree, getCropFilter(cropArea), colorize)
+		_ = os.Remove(partPaths[i])
+		if numberOfPoints == 0 {
+			tools.LogOutput("> job contains no points, skipping it")
+			continue
+		}
+		tiler.prepareDataStructure(tree)
+		tiler.exportToCesiumTileset(tree, opts, path.Join(fileName, job.Name))
+
+		child, err := io.NewExternalTilesetChild(tree.GetRootNode(), job.Name, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.RefineMode)
+		if err != nil {
+			return false, err
+		}
+		children = append(children, *child)
+		refineMode = io.GetNodeRefineMode(tree.GetRootNode(), opts.RefineMode)
+	}
+	if len(children) == 0 {
+		return false, nil
+	}
+
+	return true, io.WriteCompositeTilesetJson(path.Join(opts.Output, fileName), children, refineMode)
+}
+
+// Anchors the local frame of the tree, if it uses one, at the center of the given bounds, expressed in the input srid.
+// If the points are placed in a local frame the tree uses it instead
+func anchorLocalFrame(tree octree.ITree, bounds *geometry.BoundingBox, opts *tiler.TilerOptions) {
+	if localFrameTree, ok := tree.(octree.ILocalFrameTree); ok {
+		if opts.Placement != nil {
+			localFrameTree.SetPlacement(opts.Placement)
+			return
+		}
+		localFrameTree.SetFrameOrigin(&geometry.Coordinate{X: bounds.Xmid, Y: bounds.Ymid, Z: bounds.Zmid}, opts.Srid)
+	}
+}
+
+// Reads the points of the las file into the tree and returns the number of points stored, i.e. the ones that passed
+// the crop area and all the filters
+func (tiler *Tiler) readLasData(filePath string, opts *tiler.TilerOptions, tree octree.ITree, accept func(x, y, z float64) bool, colorize lidario.PointColorizer) int64 {
 	// Reading files
 	tools.LogOutput("> reading data from las file...", filepath.Base(filePath))
-	err := readLas(filePath, opts, tree)
+	numberOfPoints, err := readLas(filePath, opts, tree, accept, colorize)
 
 	if err != nil {
 		log.Fatal(err)
 	}
+	return numberOfPoints
 }
 
-func (tiler *Tiler) prepareDataStructure(octree octree.ITree) {
+// Returns the function computing the colors of the points of the given las files, nil to keep the stored ones. Points
+// are colored sampling the colorization raster, if any, and then according to the color mode. The automatic range of
+// the color mode is computed once over all the files so that adjacent files share the same colors
+func (tiler *Tiler) getColorizer(lasFiles []string, opts *tiler.TilerOptions) (lidario.PointColorizer, error) {
+	var rasterColorizer *raster.Colorizer
+	if opts.Colorization != nil {
+		rasterColorizer = raster.NewColorizer(opts.Colorization, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.Srid)
+	}
+
+	var modeColorizer *colors.ModeColorizer
+	// the height above ground is known only once all points are loaded, hence its colors are computed by the loader
+	if opts.ColorMode != "" && opts.ColorMode != colors.ModeLas && opts.ColorMode != colors.ModeHeightAboveGround {
+		min, max := opts.ColorMin, opts.ColorMax
+		if min == max {
+			var err error
+			min, max, err = getAutomaticColorRange(lasFiles, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		modeColorizer = colors.NewModeColorizer(opts.ColorMode, opts.ColorRamp, min, max)
+	}
+
+	if rasterColorizer == nil && modeColorizer == nil {
+		return nil, nil
+	}
+
+	return func(x, y, z float64, intensity uint16, classification uint8) (uint8, uint8, uint8, bool) {
+		if rasterColorizer != nil {
+			if r, g, b, ok := rasterColorizer.Colorize(x, y, z); ok {
+				return r, g, b, true
+			}
+		}
+		if modeColorizer != nil {
+			r, g, b := modeColorizer.Colorize(z, intensity, classification)
+			return r, g, b, true
+		}
+		return 0, 0, 0, false
+	}, nil
+}
+
+// Returns the range of the elevation and intensity color modes computed from the given las files: the elevations
+// declared in their headers or the intensities of their points, read in a preliminary pass
+func getAutomaticColorRange(lasFiles []string, opts *tiler.TilerOptions) (float64, float64, error) {
+	switch opts.ColorMode {
+	case colors.ModeElevation:
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, filePath := range lasFiles {
+			header, err := lidario.ReadLasHeader(filePath)
+			if err != nil {
+				return 0, 0, err
+			}
+			bounds := getHeaderBounds(header, opts)
+			min, max = math.Min(min, bounds.Zmin), math.Max(max, bounds.Zmax)
+		}
+		return min, max, nil
+	case colors.ModeIntensity:
+		tools.LogOutput("Computing the intensity range of the color ramp...")
+		var histogram [colors.IntensityLevels]int64
+		for _, filePath := range lasFiles {
+			err := lidario.ScanLasFile(filePath, nil, func(x, y, z float64, record []byte) error {
+				histogram[lidario.ReadRecordIntensity(record)]++
+				return nil
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+		min, max := colors.GetIntensityRange(&histogram)
+		return min, max, nil
+	}
+
+	return 0, 0, nil
+}
+
+func (tiler *Tiler) prepareDataStructure(tree octree.ITree) {
 	// Build tree hierarchical structure
 	tools.LogOutput("> building data structure...")
-	err := octree.Build()
+	err := tree.Build()
 
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	if outlierRejectingTree, ok := tree.(octree.IOutlierRejectingTree); ok && outlierRejectingTree.GetNumberOfRejectedPoints() > 0 {
+		tools.LogOutput("> discarded", strconv.FormatInt(outlierRejectingTree.GetNumberOfRejectedPoints(), 10), "outlier points")
+	}
 }
 
 func (tiler *Tiler) exportToCesiumTileset(octree octree.ITree, opts *tiler.TilerOptions, fileName string) {
@@ -88,23 +338,40 @@ func (tiler *Tiler) exportToCesiumTileset(octree octree.ITree, opts *tiler.Tiler
 	}
 }
 
+// Returns the bounds declared in the given las header, transformed with the transform of the options if any
+func getHeaderBounds(header *lidario.LasHeader, opts *tiler.TilerOptions) *geometry.BoundingBox {
+	bounds := geometry.NewBoundingBox(header.MinX, header.MaxX, header.MinY, header.MaxY, header.MinZ, header.MaxZ)
+	if opts.Transform != nil {
+		bounds = opts.Transform.ApplyToBounds(bounds)
+	}
+	return bounds
+}
+
 func getFilenameWithoutExtension(filePath string) string {
 	nameWext := filepath.Base(filePath)
 	extension := filepath.Ext(nameWext)
 	return nameWext[0 : len(nameWext)-len(extension)]
 }
 
-// Reads the given las file and preloads data in a list of Point
-func readLas(file string, opts *tiler.TilerOptions, tree octree.ITree) error {
+// Reads the given las file and preloads data in a list of Point. If accept is not nil only the points for which it
+// returns true are loaded. If colorize is not nil the points are colored with the color it returns, if any
+func readLas(file string, opts *tiler.TilerOptions, tree octree.ITree, accept func(x, y, z float64) bool, colorize lidario.PointColorizer) (int64, error) {
 	var lf *lidario.LasFile
 	var err error
-	var lasFileLoader = lidario.NewLasFileLoader(tree)
+	var lasFileLoader = lidario.NewLasFileLoader(tree, lidario.LasReaderOptions{
+		Transform:   opts.Transform,
+		Accept:      accept,
+		Filter:      opts.PointFilter,
+		Colorize:    colorize,
+		Expressions: opts.Expressions,
+		Ordered:     opts.Deterministic,
+	})
 	lf, err = lasFileLoader.LoadLasFile(file, opts.Srid, opts.EightBitColors)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() { _ = lf.Close() }()
-	return nil
+	return lasFileLoader.NumberOfPoints(), nil
 }
 
 // Exports the data cloud represented by the given built octree into 3D tiles data structure according to the options
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRampInterpolatesBetweenStops(t *testing.T) {
+	expectations := []struct {
+		position float64
+		color    [3]uint8
+	}{
+		{-1, [3]uint8{0, 0, 255}},
+		{0, [3]uint8{0, 0, 255}},
+		{0.125, [3]uint8{0, 128, 255}},
+		{0.5, [3]uint8{0, 255, 0}},
+		{1, [3]uint8{255, 0, 0}},
+		{2, [3]uint8{255, 0, 0}},
+	}
+	for _, expectation := range expectations {
+		if r, g, b := colors.Rainbow.GetColor(expectation.position); [3]uint8{r, g, b} != expectation.color {
+			t.Errorf("Expected color %v at %f, got %v", expectation.color, expectation.position, [3]uint8{r, g, b})
+		}
+	}
+}
+
+func TestParseRampAndMode(t *testing.T) {
+	if ramp, err := colors.ParseRamp("Viridis"); err != nil || ramp != colors.Viridis {
+		t.Errorf("Expected viridis ramp, got %v %v", ramp, err)
+	}
+	if ramp, err := colors.ParseRamp(""); err != nil || ramp != nil {
+		t.Errorf("Expected nil ramp, got %v %v", ramp, err)
+	}
+	if _, err := colors.ParseRamp("sunset"); err == nil {
+		t.Errorf("Expected error parsing unknown ramp")
+	}
+	if mode, err := colors.ParseMode("elevation"); err != nil || mode != colors.ModeElevation {
+		t.Errorf("Expected elevation mode, got %v %v", mode, err)
+	}
+	if mode, err := colors.ParseMode(""); err != nil || mode != colors.ModeLas {
+		t.Errorf("Expected las mode, got %v %v", mode, err)
+	}
+	if _, err := colors.ParseMode("height"); err == nil {
+		t.Errorf("Expected error parsing unknown mode")
+	}
+}
+
+func TestModeColorizer(t *testing.T) {
+	elevation := colors.NewModeColorizer(colors.ModeElevation, colors.Grayscale, 100, 200)
+	if r, g, b := elevation.Colorize(150, 0, 0); r != 128 || g != 128 || b != 128 {
+		t.Errorf("Expected color 128,128,128, got %d,%d,%d", r, g, b)
+	}
+	if r, _, _ := elevation.Colorize(250, 0, 0); r != 255 {
+		t.Errorf("Expected elevation above the range to be clamped, got %d", r)
+	}
+
+	intensity := colors.NewModeColorizer(colors.ModeIntensity, nil, 0, 255)
+	if r, g, b := intensity.Colorize(1000, 51, 0); r != 51 || g != 51 || b != 51 {
+		t.Errorf("Expected color 51,51,51, got %d,%d,%d", r, g, b)
+	}
+
+	classification := colors.NewModeColorizer(colors.ModeClassification, nil, 0, 0)
+	if r, g, b := classification.Colorize(0, 0, 2); r != 166 || g != 118 || b != 60 {
+		t.Errorf("Expected ground color 166,118,60, got %d,%d,%d", r, g, b)
+	}
+	r1, g1, b1 := classification.Colorize(0, 0, 64)
+	r2, g2, b2 := classification.Colorize(0, 0, 65)
+	if [3]uint8{r1, g1, b1} == [3]uint8{r2, g2, b2} {
+		t.Errorf("Expected distinct colors for user defined classes")
+	}
+}
+
+func TestIntensityRangeExcludesTheExtremePercentiles(t *testing.T) {
+	var histogram [colors.IntensityLevels]int64
+	for i := 40; i < 60; i++ {
+		histogram[i*256] = 10
+	}
+	// a few very bright returns, less than one percent of the points
+	histogram[65535] = 1
+
+	if min, max := colors.GetIntensityRange(&histogram); min != 40*256 || max != 59*256 {
+		t.Errorf("Expected range %d-%d, got %f-%f", 40*256, 59*256, min, max)
+	}
+
+	var empty [colors.IntensityLevels]int64
+	if min, max := colors.GetIntensityRange(&empty); min != 0 || max != 65535 {
+		t.Errorf("Expected range 0-65535 for no points, got %f-%f", min, max)
+	}
+}
+
+func TestIntensityRangeOfIntensitiesBelow256SpansTheWholeRamp(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "las*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "input.las")
+	writeTestLas(t, filePath, [][3]float64{{1, 1, 1}, {2, 2, 2}, {3, 3, 3}, {4, 4, 4}}, []uint16{10, 100, 200, 250})
+
+	var histogram [colors.IntensityLevels]int64
+	err := lidario.ScanLasFile(filePath, nil, func(x, y, z float64, record []byte) error {
+		histogram[lidario.ReadRecordIntensity(record)]++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	min, max := colors.GetIntensityRange(&histogram)
+	if min != 10 || max != 250 {
+		t.Fatalf("Expected range 10-250, got %f-%f", min, max)
+	}
+
+	colorizer := colors.NewModeColorizer(colors.ModeIntensity, nil, min, max)
+	if r, _, _ := colorizer.Colorize(0, 10, 0); r != 0 {
+		t.Errorf("Expected the lowest intensity at the start of the ramp, got %d", r)
+	}
+	if r, _, _ := colorizer.Colorize(0, 130, 0); r != 128 {
+		t.Errorf("Expected the middle intensity at the middle of the ramp, got %d", r)
+	}
+	if r, _, _ := colorizer.Colorize(0, 250, 0); r != 255 {
+		t.Errorf("Expected the highest intensity at the end of the ramp, got %d", r)
+	}
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/crop"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"testing"
+)
+
+func TestCropBoxContains(t *testing.T) {
+	area, err := crop.ParseBox("0,0,10,20,1,2", 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !area.Contains(5, 15, 1.5) {
+		t.Errorf("Expected point within the box to be contained")
+	}
+	if area.Contains(5, 15, 3) {
+		t.Errorf("Expected point above the box not to be contained")
+	}
+	if area.Contains(11, 15, 1.5) {
+		t.Errorf("Expected point outside the box not to be contained")
+	}
+}
+
+func TestCropBoxInvalid(t *testing.T) {
+	for _, value := range []string{"0,0,10", "0,0,a,10", "10,0,0,10", "0,0,10,10,2,1"} {
+		if _, err := crop.ParseBox(value, 4326); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+func TestCropWKTPolygonWithHole(t *testing.T) {
+	area, err := crop.ParsePolygon("POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4))", 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !area.Contains(2, 2, 100) {
+		t.Errorf("Expected point within the polygon to be contained")
+	}
+	if area.Contains(5, 5, 0) {
+		t.Errorf("Expected point within the hole not to be contained")
+	}
+	if area.Contains(12, 5, 0) {
+		t.Errorf("Expected point outside the polygon not to be contained")
+	}
+}
+
+func TestCropWKTMultiPolygon(t *testing.T) {
+	area, err := crop.ParsePolygon("MULTIPOLYGON Z (((0 0 1, 1 0 1, 1 1 1, 0 0 1)), ((5 5 1, 6 5 1, 6 6 1, 5 6 1, 5 5 1)))", 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !area.Contains(0.9, 0.1, 0) || !area.Contains(5.5, 5.5, 0) {
+		t.Errorf("Expected points within the polygons to be contained")
+	}
+	if area.Contains(0.1, 0.9, 0) || area.Contains(3, 3, 0) {
+		t.Errorf("Expected points outside the polygons not to be contained")
+	}
+}
+
+func TestCropGeoJSONFeatureCollection(t *testing.T) {
+	geoJSON := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [10, 10], [0, 0]]]}}
+	]}`
+	area, err := crop.ParsePolygon(geoJSON, 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !area.Contains(8, 2, 0) {
+		t.Errorf("Expected point within the polygon to be contained")
+	}
+	if area.Contains(2, 8, 0) {
+		t.Errorf("Expected point outside the polygon not to be contained")
+	}
+}
+
+func TestCropPolygonInvalid(t *testing.T) {
+	for _, value := range []string{"POINT (1 2)", "POLYGON ((0 0, 1 a, 1 1))", "POLYGON ((0 0, 1 1)", `{"type": "Point", "coordinates": [1, 2]}`} {
+		if _, err := crop.ParsePolygon(value, 4326); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+func TestCropAreaReproject(t *testing.T) {
+	area, err := crop.ParseBox("14.9,41.3,14.91,41.31", 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	reprojected, err := area.Reproject(coordinateConverter, 32633)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// EPSG:32633 coordinates of 14.902954, 41.343825 and of 14.905, 41.305
+	if reprojected.Contains(491880.85, 4576930.54, 0) {
+		t.Errorf("Expected point outside the area not to be contained")
+	}
+	center, _ := coordinateConverter.ConvertCoordinateSrid(4326, 32633, geometry.Coordinate{X: 14.905, Y: 41.305})
+	if !reprojected.Contains(center.X, center.Y, 0) {
+		t.Errorf("Expected point within the area to be contained")
+	}
+}
@@ -0,0 +1,49 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"testing"
+)
+
+func TestCurvatureLoaderPrefersEdgesToFlatAreas(t *testing.T) {
+	loader := point_loader.NewCurvatureLoader(point_loader.NewSequentialLoader(), 1)
+	// an horizontal floor meeting a vertical wall along the x = 0 line, sampled every 20 centimeters
+	for i := 0; i <= 30; i++ {
+		for j := 0; j <= 30; j++ {
+			loader.AddPoint(data.NewPoint(float64(i)*0.2, float64(j)*0.2, 0, 0, 0, 0, 0, 0))
+			if i > 0 {
+				loader.AddPoint(data.NewPoint(0, float64(j)*0.2, float64(i)*0.2, 0, 0, 0, 0, 0))
+			}
+		}
+	}
+	floor := data.NewPoint(4.1, 3.1, 0, 0, 0, 0, 0, 0)
+	edge := data.NewPoint(0.1, 3.1, 0.1, 0, 0, 0, 0, 0)
+	isolated := data.NewPoint(50, 50, 50, 0, 0, 0, 0, 0)
+	isolated.Attributes = []float32{42}
+	for _, point := range []*data.Point{floor, edge, isolated} {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+
+	if len(floor.Attributes) != 1 || floor.Attributes[0] > 1e-6 {
+		t.Errorf("Expected zero curvature on the floor, got %v", floor.Attributes)
+	}
+	if len(edge.Attributes) != 1 || edge.Attributes[0] < 0.05 || edge.Attributes[0] > 1.0/3 {
+		t.Errorf("Expected a curvature between 0.05 and 1/3 on the edge, got %v", edge.Attributes)
+	}
+	if len(isolated.Attributes) != 2 || isolated.Attributes[0] != 42 || isolated.Attributes[1] != 0 {
+		t.Errorf("Expected zero curvature appended to the attributes of the isolated point, got %v", isolated.Attributes)
+	}
+
+	count := 0
+	for point, hasNext := loader.GetNext(); point != nil; point, hasNext = loader.GetNext() {
+		count++
+		if !hasNext {
+			break
+		}
+	}
+	if count != 31*31+30*31+3 {
+		t.Errorf("Expected %d points, got %d", 31*31+30*31+3, count)
+	}
+}
@@ -8,6 +8,16 @@ import (
 	"testing"
 )
 
+// offset calculator linear in longitude and latitude, counting its calls
+type linearOffsetCalculator struct {
+	calls int
+}
+
+func (c *linearOffsetCalculator) GetEllipsoidToGeoidOffset(lat, lon float64, sourceSrid int) (float64, error) {
+	c.calls++
+	return 40 + 2*lon + 3*lat, nil
+}
+
 func TestBufferedElevationConverter(t *testing.T) {
 	var bufferedElevationConverter = geoid_offset.NewEllipsoidToGeoidBufferedCalculator(
 		360/(6371000*math.Pi*2),
@@ -27,3 +37,53 @@ func TestBufferedElevationConverter(t *testing.T) {
 		)
 	}
 }
+
+func TestBufferedElevationConverterMetricCells(t *testing.T) {
+	calculator := &linearOffsetCalculator{}
+	var bufferedElevationConverter = geoid_offset.NewEllipsoidToGeoidBufferedCalculatorWithUnit(
+		100,
+		geoid_offset.Meters,
+		proj4_coordinate_converter.NewProj4CoordinateConverter(),
+		calculator,
+	)
+
+	// a linear function is interpolated exactly
+	for _, point := range [][2]float64{{12.4, 41.9}, {12.40051, 41.90037}, {12.39987, 41.89932}} {
+		output, err := bufferedElevationConverter.GetEllipsoidToGeoidOffset(point[0], point[1], 4326)
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err.Error())
+		}
+		expected := 40 + 2*point[0] + 3*point[1]
+		if math.Abs(expected-output) > 1E-6 {
+			t.Errorf("Expected offset at %f,%f = %.6f, got %.6f", point[0], point[1], expected, output)
+		}
+	}
+
+	// points in the same cell reuse the cached offsets of its corners
+	calls := calculator.calls
+	if _, err := bufferedElevationConverter.GetEllipsoidToGeoidOffset(12.400511, 41.900371, 4326); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if calculator.calls != calls {
+		t.Errorf("Expected no new offset computation, got %d", calculator.calls-calls)
+	}
+}
+
+func TestBufferedElevationConverterConvertsToWGS84(t *testing.T) {
+	var bufferedElevationConverter = geoid_offset.NewEllipsoidToGeoidBufferedCalculatorWithUnit(
+		100,
+		geoid_offset.Meters,
+		proj4_coordinate_converter.NewProj4CoordinateConverter(),
+		&linearOffsetCalculator{},
+	)
+
+	// 491880.85, 4576930.54 in EPSG:32633 is 14.902954, 41.343825 in EPSG:4326
+	expected := 40 + 2*14.902954 + 3*41.343825
+	output, err := bufferedElevationConverter.GetEllipsoidToGeoidOffset(491880.85, 4576930.54, 32633)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if math.Abs(expected-output) > 1E-3 {
+		t.Errorf("Expected offset %.3f, got %.3f", expected, output)
+	}
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
+	"strings"
+	"testing"
+)
+
+func TestExpressionAttributesAreComputedInOrder(t *testing.T) {
+	program, err := expression.Parse("HeightBand = floor(Z / 10)\nDouble = heightband * 2 # refers to the previous attribute")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	names := program.GetAttributeNames()
+	if len(names) != 2 || names[0] != "HeightBand" || names[1] != "Double" {
+		t.Errorf("Expected attribute names [HeightBand Double], got %v", names)
+	}
+
+	attributes, accepted := program.Evaluate(&expression.Values{Z: 27.5})
+	if !accepted {
+		t.Errorf("Expected point to be accepted by a program without filters")
+	}
+	if len(attributes) != 2 || attributes[0] != 2 || attributes[1] != 4 {
+		t.Errorf("Expected attributes [2 4], got %v", attributes)
+	}
+}
+
+func TestExpressionFilters(t *testing.T) {
+	program, err := expression.Parse("Classification == 2 && (Z > 10 || ReturnNumber == NumberOfReturns); !(Intensity < 5)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectations := []struct {
+		values   expression.Values
+		accepted bool
+	}{
+		{expression.Values{Classification: 2, Z: 11, ReturnNumber: 1, NumberOfReturns: 2, Intensity: 5}, true},
+		{expression.Values{Classification: 2, Z: 1, ReturnNumber: 2, NumberOfReturns: 2, Intensity: 5}, true},
+		{expression.Values{Classification: 2, Z: 1, ReturnNumber: 1, NumberOfReturns: 2, Intensity: 5}, false},
+		{expression.Values{Classification: 6, Z: 11, ReturnNumber: 1, NumberOfReturns: 1, Intensity: 5}, false},
+		{expression.Values{Classification: 2, Z: 11, ReturnNumber: 1, NumberOfReturns: 1, Intensity: 4}, false},
+	}
+
+	for _, expectation := range expectations {
+		attributes, accepted := program.Evaluate(&expectation.values)
+		if accepted != expectation.accepted {
+			t.Errorf("Expected accepted = %t for %+v, got %t", expectation.accepted, expectation.values, accepted)
+		}
+		if attributes != nil {
+			t.Errorf("Expected nil attributes, got %v", attributes)
+		}
+	}
+}
+
+func TestExpressionOperatorsAndFunctions(t *testing.T) {
+	expectations := []struct {
+		source   string
+		expected float32
+	}{
+		{"V = 1 + 2 * 3", 7},
+		{"V = (1 + 2) * 3", 9},
+		{"V = -2 - -3", 1},
+		{"V = 10 - 4 - 3", 3},
+		{"V = 7 % 4", 3},
+		{"V = 1 < 2 && 2 <= 2 && 3 >= 4 == false", 1},
+		{"V = abs(-1.5) + ceil(0.2) + round(1.5)", 4.5},
+		{"V = pow(2, 3) + sqrt(16) + min(1, 2) + max(1, 2)", 15},
+		{"V = clamp(R, 10, 20) + clamp(G, 10, 20)", 30},
+		{"V = 2.5e1", 25},
+	}
+
+	for _, expectation := range expectations {
+		program, err := expression.Parse(expectation.source)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", expectation.source, err)
+			continue
+		}
+		attributes, _ := program.Evaluate(&expression.Values{R: 5, G: 200})
+		if attributes[0] != expectation.expected {
+			t.Errorf("Expected %q = %f, got %f", expectation.source, expectation.expected, attributes[0])
+		}
+	}
+}
+
+func TestExpressionScanDataVariables(t *testing.T) {
+	program, err := expression.Parse("GpsTime > 100 && ScanAngle < -10 && PointSourceID == 7 && UserData == 3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, accepted := program.Evaluate(&expression.Values{GpsTime: 101, ScanAngle: -12.5, PointSourceID: 7, UserData: 3}); !accepted {
+		t.Errorf("Expected the point to be accepted")
+	}
+	if _, accepted := program.Evaluate(&expression.Values{GpsTime: 99, ScanAngle: -12.5, PointSourceID: 7, UserData: 3}); accepted {
+		t.Errorf("Expected the point to be rejected")
+	}
+}
+
+func TestExpressionNonAsciiCharactersAreRejected(t *testing.T) {
+	sources := []string{"Zé > 1", "é > 1", "Z > 1 ²", "Z\u00a0> 1"}
+
+	for _, source := range sources {
+		_, err := expression.Parse(source)
+		if err == nil || !strings.Contains(err.Error(), "unexpected character") {
+			t.Errorf("Expected an unexpected character error parsing %q, got %v", source, err)
+		}
+	}
+}
+
+func TestExpressionParseErrors(t *testing.T) {
+	sources := []string{
+		"Z >",
+		"(Z > 1",
+		"Unknown > 1",
+		"foo(Z) > 1",
+		"floor(Z, 1) > 1",
+		"Z > 1 1",
+		"Z $ 1",
+		"Z = 1",
+		"A = 1; a = 2",
+	}
+
+	for _, source := range sources {
+		if _, err := expression.Parse(source); err == nil {
+			t.Errorf("Expected error parsing %q", source)
+		}
+	}
+}
@@ -394,4 +394,853 @@ func TestRefineModeFlagDefaultIsAdd(t *testing.T) {
 	if *flags.RefineMode != expected {
 		t.Errorf("Expected Output = %s, got %s", expected, *flags.RefineMode)
 	}
-}
\ No newline at end of file
+}
+func TestMaxMemoryFlagIsParsed(t *testing.T) {
+	expected := 2048
+	os.Args = []string{"gocesiumtiler", "-max-memory=2048"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.MaxMemory != expected {
+		t.Errorf("Expected MaxMemory = %d, got %d", expected, *flags.MaxMemory)
+	}
+}
+
+func TestMaxMemoryDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.MaxMemory != expected {
+		t.Errorf("Expected MaxMemory = %d, got %d", expected, *flags.MaxMemory)
+	}
+}
+
+func TestAutoSplitFlagIsParsed(t *testing.T) {
+	expected := true
+	os.Args = []string{"gocesiumtiler", "-auto-split"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.AutoSplit {
+		t.Errorf("Expected AutoSplit = %t, got %t", expected, *flags.AutoSplit)
+	}
+}
+
+func TestAutoSplitDefaultIsFalse(t *testing.T) {
+	expected := false
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.AutoSplit {
+		t.Errorf("Expected AutoSplit = %t, got %t", expected, *flags.AutoSplit)
+	}
+}
+
+func TestGridMaxPointsFlagIsParsed(t *testing.T) {
+	expected := 100000
+	os.Args = []string{"gocesiumtiler", "-grid-max-points=100000"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridMaxNumPts != expected {
+		t.Errorf("Expected GridMaxNumPts = %d, got %d", expected, *flags.GridMaxNumPts)
+	}
+}
+
+func TestGridMaxPointsDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridMaxNumPts != expected {
+		t.Errorf("Expected GridMaxNumPts = %d, got %d", expected, *flags.GridMaxNumPts)
+	}
+}
+
+func TestGridMaxDepthFlagIsParsed(t *testing.T) {
+	expected := 12
+	os.Args = []string{"gocesiumtiler", "-grid-max-depth=12"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridMaxDepth != expected {
+		t.Errorf("Expected GridMaxDepth = %d, got %d", expected, *flags.GridMaxDepth)
+	}
+}
+
+func TestGridMaxDepthDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridMaxDepth != expected {
+		t.Errorf("Expected GridMaxDepth = %d, got %d", expected, *flags.GridMaxDepth)
+	}
+}
+
+func TestDeterministicFlagIsParsed(t *testing.T) {
+	expected := true
+	os.Args = []string{"gocesiumtiler", "-deterministic"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Deterministic {
+		t.Errorf("Expected Deterministic = %t, got %t", expected, *flags.Deterministic)
+	}
+}
+
+func TestDeterministicDefaultIsFalse(t *testing.T) {
+	expected := false
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Deterministic {
+		t.Errorf("Expected Deterministic = %t, got %t", expected, *flags.Deterministic)
+	}
+}
+
+func TestSeedFlagIsParsed(t *testing.T) {
+	expected := 42
+	os.Args = []string{"gocesiumtiler", "-seed=42"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Seed != expected {
+		t.Errorf("Expected Seed = %d, got %d", expected, *flags.Seed)
+	}
+}
+
+func TestSeedDefaultIsOne(t *testing.T) {
+	expected := 1
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Seed != expected {
+		t.Errorf("Expected Seed = %d, got %d", expected, *flags.Seed)
+	}
+}
+
+func TestPriorityClassesFlagIsParsed(t *testing.T) {
+	expected := "14:2,15"
+	os.Args = []string{"gocesiumtiler", "-priority-classes=14:2,15"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.PriorityClasses != expected {
+		t.Errorf("Expected PriorityClasses = %s, got %s", expected, *flags.PriorityClasses)
+	}
+}
+
+func TestPriorityClassesDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.PriorityClasses != expected {
+		t.Errorf("Expected PriorityClasses = %s, got %s", expected, *flags.PriorityClasses)
+	}
+}
+
+func TestGridScoreFlagIsParsed(t *testing.T) {
+	expected := "intensity"
+	os.Args = []string{"gocesiumtiler", "-grid-score=intensity"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridScore != expected {
+		t.Errorf("Expected GridScore = %s, got %s", expected, *flags.GridScore)
+	}
+}
+
+func TestGridScoreDefaultIsCenter(t *testing.T) {
+	expected := "center"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridScore != expected {
+		t.Errorf("Expected GridScore = %s, got %s", expected, *flags.GridScore)
+	}
+}
+
+func TestCurvatureRadiusFlagIsParsed(t *testing.T) {
+	expected := 2.5
+	os.Args = []string{"gocesiumtiler", "-curvature-radius=2.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CurvatureRadius != expected {
+		t.Errorf("Expected CurvatureRadius = %f, got %f", expected, *flags.CurvatureRadius)
+	}
+}
+
+func TestCurvatureRadiusDefaultIsOne(t *testing.T) {
+	expected := 1.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CurvatureRadius != expected {
+		t.Errorf("Expected CurvatureRadius = %f, got %f", expected, *flags.CurvatureRadius)
+	}
+}
+
+func TestBoundsPercentileFlagIsParsed(t *testing.T) {
+	expected := 0.5
+	os.Args = []string{"gocesiumtiler", "-bounds-percentile=0.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.BoundsPercentile != expected {
+		t.Errorf("Expected BoundsPercentile = %f, got %f", expected, *flags.BoundsPercentile)
+	}
+}
+
+func TestBoundsPercentileDefaultIsZero(t *testing.T) {
+	expected := 0.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.BoundsPercentile != expected {
+		t.Errorf("Expected BoundsPercentile = %f, got %f", expected, *flags.BoundsPercentile)
+	}
+}
+
+func TestOutlierSigmaFlagIsParsed(t *testing.T) {
+	expected := 4.0
+	os.Args = []string{"gocesiumtiler", "-outlier-sigma=4"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.OutlierSigma != expected {
+		t.Errorf("Expected OutlierSigma = %f, got %f", expected, *flags.OutlierSigma)
+	}
+}
+
+func TestOutlierSigmaDefaultIsZero(t *testing.T) {
+	expected := 0.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.OutlierSigma != expected {
+		t.Errorf("Expected OutlierSigma = %f, got %f", expected, *flags.OutlierSigma)
+	}
+}
+
+func TestNoiseRadiusFlagIsParsed(t *testing.T) {
+	expected := 0.5
+	os.Args = []string{"gocesiumtiler", "-noise-radius=0.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseRadius != expected {
+		t.Errorf("Expected NoiseRadius = %f, got %f", expected, *flags.NoiseRadius)
+	}
+}
+
+func TestNoiseRadiusDefaultIsOne(t *testing.T) {
+	expected := 1.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseRadius != expected {
+		t.Errorf("Expected NoiseRadius = %f, got %f", expected, *flags.NoiseRadius)
+	}
+}
+
+func TestNoiseMinNeighboursFlagIsParsed(t *testing.T) {
+	expected := 4
+	os.Args = []string{"gocesiumtiler", "-noise-min-neighbours=4"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseMinNeighbours != expected {
+		t.Errorf("Expected NoiseMinNeighbours = %d, got %d", expected, *flags.NoiseMinNeighbours)
+	}
+}
+
+func TestNoiseMinNeighboursDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseMinNeighbours != expected {
+		t.Errorf("Expected NoiseMinNeighbours = %d, got %d", expected, *flags.NoiseMinNeighbours)
+	}
+}
+
+func TestNoiseKNearestFlagIsParsed(t *testing.T) {
+	expected := 8
+	os.Args = []string{"gocesiumtiler", "-noise-knn=8"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseKNearest != expected {
+		t.Errorf("Expected NoiseKNearest = %d, got %d", expected, *flags.NoiseKNearest)
+	}
+}
+
+func TestNoiseKNearestDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseKNearest != expected {
+		t.Errorf("Expected NoiseKNearest = %d, got %d", expected, *flags.NoiseKNearest)
+	}
+}
+
+func TestNoiseMaxMeanDistanceFlagIsParsed(t *testing.T) {
+	expected := 2.5
+	os.Args = []string{"gocesiumtiler", "-noise-knn-max-distance=2.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseMaxMeanDistance != expected {
+		t.Errorf("Expected NoiseMaxMeanDistance = %f, got %f", expected, *flags.NoiseMaxMeanDistance)
+	}
+}
+
+func TestNoiseMaxMeanDistanceDefaultIsOne(t *testing.T) {
+	expected := 1.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.NoiseMaxMeanDistance != expected {
+		t.Errorf("Expected NoiseMaxMeanDistance = %f, got %f", expected, *flags.NoiseMaxMeanDistance)
+	}
+}
+
+func TestIncludeClassesFlagIsParsed(t *testing.T) {
+	expected := "2,6"
+	os.Args = []string{"gocesiumtiler", "-include-classes=2,6"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.IncludeClasses != expected {
+		t.Errorf("Expected IncludeClasses = %s, got %s", expected, *flags.IncludeClasses)
+	}
+}
+
+func TestIncludeClassesDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.IncludeClasses != expected {
+		t.Errorf("Expected IncludeClasses = %s, got %s", expected, *flags.IncludeClasses)
+	}
+}
+
+func TestExcludeClassesFlagIsParsed(t *testing.T) {
+	expected := "7,18"
+	os.Args = []string{"gocesiumtiler", "-exclude-classes=7,18"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeClasses != expected {
+		t.Errorf("Expected ExcludeClasses = %s, got %s", expected, *flags.ExcludeClasses)
+	}
+}
+
+func TestExcludeClassesDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeClasses != expected {
+		t.Errorf("Expected ExcludeClasses = %s, got %s", expected, *flags.ExcludeClasses)
+	}
+}
+
+func TestReturnsFlagIsParsed(t *testing.T) {
+	expected := "last"
+	os.Args = []string{"gocesiumtiler", "-returns=last"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Returns != expected {
+		t.Errorf("Expected Returns = %s, got %s", expected, *flags.Returns)
+	}
+}
+
+func TestReturnsDefaultIsAll(t *testing.T) {
+	expected := "all"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Returns != expected {
+		t.Errorf("Expected Returns = %s, got %s", expected, *flags.Returns)
+	}
+}
+
+func TestExcludeWithheldFlagIsParsed(t *testing.T) {
+	expected := true
+	os.Args = []string{"gocesiumtiler", "-exclude-withheld"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeWithheld != expected {
+		t.Errorf("Expected ExcludeWithheld = %t, got %t", expected, *flags.ExcludeWithheld)
+	}
+}
+
+func TestExcludeWithheldDefaultIsFalse(t *testing.T) {
+	expected := false
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeWithheld != expected {
+		t.Errorf("Expected ExcludeWithheld = %t, got %t", expected, *flags.ExcludeWithheld)
+	}
+}
+
+func TestExcludeSyntheticFlagIsParsed(t *testing.T) {
+	expected := true
+	os.Args = []string{"gocesiumtiler", "-exclude-synthetic"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeSynthetic != expected {
+		t.Errorf("Expected ExcludeSynthetic = %t, got %t", expected, *flags.ExcludeSynthetic)
+	}
+}
+
+func TestExcludeSyntheticDefaultIsFalse(t *testing.T) {
+	expected := false
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExcludeSynthetic != expected {
+		t.Errorf("Expected ExcludeSynthetic = %t, got %t", expected, *flags.ExcludeSynthetic)
+	}
+}
+
+func TestCropBoxFlagIsParsed(t *testing.T) {
+	expected := "0,0,10,10"
+	os.Args = []string{"gocesiumtiler", "-crop-box=0,0,10,10"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropBox != expected {
+		t.Errorf("Expected CropBox = %s, got %s", expected, *flags.CropBox)
+	}
+}
+
+func TestCropBoxDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropBox != expected {
+		t.Errorf("Expected CropBox = %s, got %s", expected, *flags.CropBox)
+	}
+}
+
+func TestCropPolygonFlagIsParsed(t *testing.T) {
+	expected := "area.geojson"
+	os.Args = []string{"gocesiumtiler", "-crop-polygon=area.geojson"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropPolygon != expected {
+		t.Errorf("Expected CropPolygon = %s, got %s", expected, *flags.CropPolygon)
+	}
+}
+
+func TestCropPolygonDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropPolygon != expected {
+		t.Errorf("Expected CropPolygon = %s, got %s", expected, *flags.CropPolygon)
+	}
+}
+
+func TestCropSridFlagIsParsed(t *testing.T) {
+	expected := 32633
+	os.Args = []string{"gocesiumtiler", "-crop-srid=32633"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropSrid != expected {
+		t.Errorf("Expected CropSrid = %d, got %d", expected, *flags.CropSrid)
+	}
+}
+
+func TestCropSridDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CropSrid != expected {
+		t.Errorf("Expected CropSrid = %d, got %d", expected, *flags.CropSrid)
+	}
+}
+
+func TestExpressionsFlagIsParsed(t *testing.T) {
+	expected := "Z > 10; HeightBand = floor(Z / 10)"
+	os.Args = []string{"gocesiumtiler", "-expressions=Z > 10; HeightBand = floor(Z / 10)"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Expressions != expected {
+		t.Errorf("Expected Expressions = %s, got %s", expected, *flags.Expressions)
+	}
+}
+
+func TestExpressionsDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Expressions != expected {
+		t.Errorf("Expected Expressions = %s, got %s", expected, *flags.Expressions)
+	}
+}
+
+func TestExpressionsFileFlagIsParsed(t *testing.T) {
+	expected := "expressions.txt"
+	os.Args = []string{"gocesiumtiler", "-expressions-file=expressions.txt"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExpressionsFile != expected {
+		t.Errorf("Expected ExpressionsFile = %s, got %s", expected, *flags.ExpressionsFile)
+	}
+}
+
+func TestExpressionsFileDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExpressionsFile != expected {
+		t.Errorf("Expected ExpressionsFile = %s, got %s", expected, *flags.ExpressionsFile)
+	}
+}
+
+func TestColorizeFlagIsParsed(t *testing.T) {
+	expected := "ortho.tif"
+	os.Args = []string{"gocesiumtiler", "-colorize=ortho.tif"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Colorize != expected {
+		t.Errorf("Expected Colorize = %s, got %s", expected, *flags.Colorize)
+	}
+}
+
+func TestColorizeDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Colorize != expected {
+		t.Errorf("Expected Colorize = %s, got %s", expected, *flags.Colorize)
+	}
+}
+
+func TestColorizeSridFlagIsParsed(t *testing.T) {
+	expected := 32633
+	os.Args = []string{"gocesiumtiler", "-colorize-srid=32633"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorizeSrid != expected {
+		t.Errorf("Expected ColorizeSrid = %d, got %d", expected, *flags.ColorizeSrid)
+	}
+}
+
+func TestColorizeSridDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorizeSrid != expected {
+		t.Errorf("Expected ColorizeSrid = %d, got %d", expected, *flags.ColorizeSrid)
+	}
+}
+
+func TestColorModeFlagIsParsed(t *testing.T) {
+	expected := "elevation"
+	os.Args = []string{"gocesiumtiler", "-color-mode=elevation"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMode != expected {
+		t.Errorf("Expected ColorMode = %s, got %s", expected, *flags.ColorMode)
+	}
+}
+
+func TestColorModeDefaultIsLas(t *testing.T) {
+	expected := "las"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMode != expected {
+		t.Errorf("Expected ColorMode = %s, got %s", expected, *flags.ColorMode)
+	}
+}
+
+func TestColorRampFlagIsParsed(t *testing.T) {
+	expected := "viridis"
+	os.Args = []string{"gocesiumtiler", "-color-ramp=viridis"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorRamp != expected {
+		t.Errorf("Expected ColorRamp = %s, got %s", expected, *flags.ColorRamp)
+	}
+}
+
+func TestColorRampDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorRamp != expected {
+		t.Errorf("Expected ColorRamp = %s, got %s", expected, *flags.ColorRamp)
+	}
+}
+
+func TestColorMinFlagIsParsed(t *testing.T) {
+	expected := -12.5
+	os.Args = []string{"gocesiumtiler", "-color-min=-12.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMin != expected {
+		t.Errorf("Expected ColorMin = %f, got %f", expected, *flags.ColorMin)
+	}
+}
+
+func TestColorMinDefaultIsZero(t *testing.T) {
+	expected := 0.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMin != expected {
+		t.Errorf("Expected ColorMin = %f, got %f", expected, *flags.ColorMin)
+	}
+}
+
+func TestColorMaxFlagIsParsed(t *testing.T) {
+	expected := 500.0
+	os.Args = []string{"gocesiumtiler", "-color-max=500"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMax != expected {
+		t.Errorf("Expected ColorMax = %f, got %f", expected, *flags.ColorMax)
+	}
+}
+
+func TestColorMaxDefaultIsZero(t *testing.T) {
+	expected := 0.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ColorMax != expected {
+		t.Errorf("Expected ColorMax = %f, got %f", expected, *flags.ColorMax)
+	}
+}
+
+func TestIsFlagSetReportsOnlyTheGivenFlags(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-color-max=0"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	tools.ParseFlags()
+	if !tools.IsFlagSet("color-max") {
+		t.Errorf("Expected color-max to be set")
+	}
+	if tools.IsFlagSet("color-min") {
+		t.Errorf("Expected color-min not to be set")
+	}
+}
+
+func TestHeightAboveGroundFlagIsParsed(t *testing.T) {
+	expected := true
+	os.Args = []string{"gocesiumtiler", "-height-above-ground"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.HeightAboveGround != expected {
+		t.Errorf("Expected HeightAboveGround = %t, got %t", expected, *flags.HeightAboveGround)
+	}
+}
+
+func TestHeightAboveGroundDefaultIsFalse(t *testing.T) {
+	expected := false
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.HeightAboveGround != expected {
+		t.Errorf("Expected HeightAboveGround = %t, got %t", expected, *flags.HeightAboveGround)
+	}
+}
+
+func TestGroundCellSizeFlagIsParsed(t *testing.T) {
+	expected := 2.5
+	os.Args = []string{"gocesiumtiler", "-ground-cell-size=2.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GroundCellSize != expected {
+		t.Errorf("Expected GroundCellSize = %f, got %f", expected, *flags.GroundCellSize)
+	}
+}
+
+func TestGroundCellSizeDefaultIsOne(t *testing.T) {
+	expected := 1.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GroundCellSize != expected {
+		t.Errorf("Expected GroundCellSize = %f, got %f", expected, *flags.GroundCellSize)
+	}
+}
+
+func TestTransformFlagIsParsed(t *testing.T) {
+	expected := "0,0,4.5,0,0,0.554,0.219"
+	os.Args = []string{"gocesiumtiler", "-transform=0,0,4.5,0,0,0.554,0.219"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Transform != expected {
+		t.Errorf("Expected Transform = %s, got %s", expected, *flags.Transform)
+	}
+}
+
+func TestTransformDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Transform != expected {
+		t.Errorf("Expected Transform = %s, got %s", expected, *flags.Transform)
+	}
+}
+
+func TestTransformConventionFlagIsParsed(t *testing.T) {
+	expected := "coordinate-frame"
+	os.Args = []string{"gocesiumtiler", "-transform-convention=coordinate-frame"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.TransformConvention != expected {
+		t.Errorf("Expected TransformConvention = %s, got %s", expected, *flags.TransformConvention)
+	}
+}
+
+func TestTransformConventionDefaultIsPositionVector(t *testing.T) {
+	expected := "position-vector"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.TransformConvention != expected {
+		t.Errorf("Expected TransformConvention = %s, got %s", expected, *flags.TransformConvention)
+	}
+}
+
+func TestControlPointsFlagIsParsed(t *testing.T) {
+	expected := "gcp.csv"
+	os.Args = []string{"gocesiumtiler", "-gcp=gcp.csv"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ControlPoints != expected {
+		t.Errorf("Expected ControlPoints = %s, got %s", expected, *flags.ControlPoints)
+	}
+}
+
+func TestControlPointsCheckFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-gcp=gcp.csv", "-gcp-check"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ControlPointsCheck {
+		t.Errorf("Expected ControlPointsCheck = true, got false")
+	}
+}
+
+func TestControlPointsCheckDefaultIsFalse(t *testing.T) {
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ControlPointsCheck {
+		t.Errorf("Expected ControlPointsCheck = false, got true")
+	}
+}
+
+func TestControlPointsDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ControlPoints != expected {
+		t.Errorf("Expected ControlPoints = %s, got %s", expected, *flags.ControlPoints)
+	}
+}
+
+func TestLocalOriginFlagIsParsed(t *testing.T) {
+	expected := "14.25,41.5,120"
+	os.Args = []string{"gocesiumtiler", "-local-origin=14.25,41.5,120"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.LocalOrigin != expected {
+		t.Errorf("Expected LocalOrigin = %s, got %s", expected, *flags.LocalOrigin)
+	}
+}
+
+func TestLocalOriginDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.LocalOrigin != expected {
+		t.Errorf("Expected LocalOrigin = %s, got %s", expected, *flags.LocalOrigin)
+	}
+}
+
+func TestLocalHeadingFlagIsParsed(t *testing.T) {
+	expected := 32.5
+	os.Args = []string{"gocesiumtiler", "-local-heading=32.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.LocalHeading != expected {
+		t.Errorf("Expected LocalHeading = %f, got %f", expected, *flags.LocalHeading)
+	}
+}
+
+func TestLocalHeadingDefaultIsZero(t *testing.T) {
+	expected := 0.0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.LocalHeading != expected {
+		t.Errorf("Expected LocalHeading = %f, got %f", expected, *flags.LocalHeading)
+	}
+}
+
+func TestGeoidGridFlagIsParsed(t *testing.T) {
+	expected := "us_nga_egm96_15.tif"
+	os.Args = []string{"gocesiumtiler", "-geoid-grid=us_nga_egm96_15.tif"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidGrid != expected {
+		t.Errorf("Expected GeoidGrid = %s, got %s", expected, *flags.GeoidGrid)
+	}
+}
+
+func TestGeoidGridDefaultIsEmpty(t *testing.T) {
+	expected := ""
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidGrid != expected {
+		t.Errorf("Expected GeoidGrid = %s, got %s", expected, *flags.GeoidGrid)
+	}
+}
+
+func TestGeoidModelFlagIsParsed(t *testing.T) {
+	expected := "EGM2008_to2190_TideFree"
+	os.Args = []string{"gocesiumtiler", "-geoid-model=EGM2008_to2190_TideFree"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidModel != expected {
+		t.Errorf("Expected GeoidModel = %s, got %s", expected, *flags.GeoidModel)
+	}
+}
+
+func TestGeoidModelDefaultIsEgm180(t *testing.T) {
+	expected := "egm180"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidModel != expected {
+		t.Errorf("Expected GeoidModel = %s, got %s", expected, *flags.GeoidModel)
+	}
+}
+
+func TestGeoidDegreeFlagIsParsed(t *testing.T) {
+	expected := 360
+	os.Args = []string{"gocesiumtiler", "-geoid-degree=360"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidDegree != expected {
+		t.Errorf("Expected GeoidDegree = %d, got %d", expected, *flags.GeoidDegree)
+	}
+}
+
+func TestGeoidDegreeDefaultIsZero(t *testing.T) {
+	expected := 0
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GeoidDegree != expected {
+		t.Errorf("Expected GeoidDegree = %d, got %d", expected, *flags.GeoidDegree)
+	}
+}
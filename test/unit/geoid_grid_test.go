@@ -0,0 +1,214 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/grid_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geoid"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+type undulationExpectation struct {
+	lon, lat   float64
+	undulation float64
+	inGrid     bool
+}
+
+// expectations on a grid of 1 degree spacing with south west node at 10,40 and nodes 1,2,3 in the south row and 4,5
+// and no data in the north one
+var testGridExpectations = []undulationExpectation{
+	{10, 40, 1, true},
+	{11, 41, 5, true},
+	{12, 41, 0, false},
+	{10.5, 40.5, 3, true},
+	{10.25, 40, 1.25, true},
+	{11.5, 40.5, 10.0 / 3, true},
+	{370, 40, 1, true},
+	{-350, 41, 4, true},
+	{9.5, 40, 0, false},
+	{10, 41.5, 0, false},
+	{12.5, 40, 0, false},
+}
+
+func checkUndulations(t *testing.T, grid *geoid.Grid, expectations []undulationExpectation) {
+	for _, expectation := range expectations {
+		undulation, ok := grid.GetUndulation(expectation.lon, expectation.lat)
+		if ok != expectation.inGrid || (ok && math.Abs(undulation-expectation.undulation) > 1e-6) {
+			t.Errorf("Expected undulation at %f,%f = %f %t, got %f %t", expectation.lon, expectation.lat, expectation.undulation, expectation.inGrid, undulation, ok)
+		}
+	}
+}
+
+func TestGeoidGridReadGtx(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "geoid*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "geoid.gtx")
+
+	content := make([]byte, 40)
+	for i, value := range []float64{40, 10, 1, 1} {
+		binary.BigEndian.PutUint64(content[i*8:], math.Float64bits(value))
+	}
+	binary.BigEndian.PutUint32(content[32:], 2)
+	binary.BigEndian.PutUint32(content[36:], 3)
+	for _, value := range []float32{1, 2, 3, 4, 5, -88.8888} {
+		content = append(content, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(content[len(content)-4:], math.Float32bits(value))
+	}
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	grid, err := geoid.ReadGrid(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkUndulations(t, grid, testGridExpectations)
+}
+
+func TestGeoidGridReadByn(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "geoid*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "geoid.byn")
+
+	content := make([]byte, 80)
+	// bounds in thousandths of arc seconds
+	for i, value := range []int32{40 * 3600000, 41 * 3600000, 10 * 3600000, 12 * 3600000} {
+		binary.LittleEndian.PutUint32(content[i*4:], uint32(value))
+	}
+	binary.LittleEndian.PutUint16(content[16:], 3600)
+	binary.LittleEndian.PutUint16(content[18:], 3600)
+	binary.LittleEndian.PutUint64(content[24:], math.Float64bits(1000))
+	binary.LittleEndian.PutUint16(content[32:], 2)
+	binary.LittleEndian.PutUint16(content[44:], 1)
+	binary.LittleEndian.PutUint16(content[46:], 1)
+	// rows from the north
+	for _, value := range []int16{4000, 5000, 32767, 1000, 2000, 3000} {
+		content = append(content, 0, 0)
+		binary.LittleEndian.PutUint16(content[len(content)-2:], uint16(value))
+	}
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	grid, err := geoid.ReadGrid(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkUndulations(t, grid, testGridExpectations)
+}
+
+func TestGeoidGridReadFloatGeoTiff(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "geoid*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "geoid.tif")
+	writeTestGeoidGeoTiff(t, filePath, -9999, "-9999", 4326)
+
+	grid, err := geoid.ReadGrid(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkUndulations(t, grid, testGridExpectations)
+}
+
+func TestGeoidGridReadFloatGeoTiffWithNoDataNotRepresentableInFloat32(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "geoid*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "geoid.tif")
+	writeTestGeoidGeoTiff(t, filePath, -88.8888, "-88.8888", 4326)
+
+	grid, err := geoid.ReadGrid(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkUndulations(t, grid, testGridExpectations)
+}
+
+func TestGeoidGridReadProjectedGeoTiffReturnsError(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "geoid*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "geoid.tif")
+	writeTestGeoidGeoTiff(t, filePath, -9999, "-9999", 32633)
+
+	if _, err := geoid.ReadGrid(filePath); err == nil {
+		t.Errorf("Expected error reading a geoid grid in projected coordinates")
+	}
+}
+
+// writes a float32 GeoTIFF geoid grid with the test undulations, the last node of the northern row storing the given
+// no data value, in the given geographic or projected srid
+func writeTestGeoidGeoTiff(t *testing.T, filePath string, noData float32, noDataTag string, srid uint16) {
+	// rows from the north, stored with the floating point predictor: bytes grouped from the most significant and
+	// differenced along each row
+	var data []byte
+	for _, row := range [][]float32{{4, 5, noData}, {1, 2, 3}} {
+		line := make([]byte, 12)
+		for i, value := range row {
+			bits := math.Float32bits(value)
+			for b := 0; b < 4; b++ {
+				line[b*3+i] = byte(bits >> uint(24-8*b))
+			}
+		}
+		for i := len(line) - 1; i > 0; i-- {
+			line[i] -= line[i-1]
+		}
+		data = append(data, line...)
+	}
+	geoKey := uint16(2048)
+	if srid != 4326 {
+		geoKey = 3072
+	}
+	writeTestTiff(t, filePath, data, []testTiffTag{
+		{tag: 256, shorts: []uint16{3}},
+		{tag: 257, shorts: []uint16{2}},
+		{tag: 258, shorts: []uint16{32}},
+		{tag: 259, shorts: []uint16{1}},
+		{tag: 262, shorts: []uint16{1}},
+		{tag: 277, shorts: []uint16{1}},
+		{tag: 278, shorts: []uint16{2}},
+		{tag: 317, shorts: []uint16{3}},
+		{tag: 339, shorts: []uint16{3}},
+		{tag: 33550, doubles: []float64{1, 1, 0}},
+		{tag: 33922, doubles: []float64{0, 0, 0, 9.5, 41.5, 0}},
+		{tag: 34735, shorts: []uint16{1, 1, 0, 1, geoKey, 0, 1, srid}},
+		{tag: 42113, ascii: noDataTag},
+	})
+}
+
+func TestGeoidGridReadUnsupportedFormat(t *testing.T) {
+	if _, err := geoid.ReadGrid("geoid.asc"); err == nil {
+		t.Errorf("Expected error reading an unsupported geoid grid format")
+	}
+}
+
+func TestGeoidGridWrapsAroundTheGlobe(t *testing.T) {
+	// nodes every 90 degrees from -180 to 90, the node at 180 being the one at -180
+	grid, err := geoid.NewGrid(-180, -90, 90, 180, 4, 2, []float32{0, 1, 2, 3, 0, 1, 2, 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	checkUndulations(t, grid, []undulationExpectation{
+		{135, 0, 1.5, true},
+		{180, 0, 0, true},
+		{-200, 45, 2.0 / 3, true},
+		{-45, 90, 1.5, true},
+		{0, 91, 0, false},
+	})
+}
+
+func TestGridElevationCorrector(t *testing.T) {
+	grid, err := geoid.NewGrid(10, 40, 1, 1, 2, 2, []float32{40, 42, 44, 46})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	corrector := grid_elevation_corrector.NewGridElevationCorrector(grid)
+
+	expected := 53.0
+	output := corrector.CorrectElevation(10.5, 40.5, 10.0)
+	if math.Abs(expected-output) > 1e-6 {
+		t.Errorf("Expected Z:%.3f, got Z:%.3f", expected, output)
+	}
+}
@@ -3,7 +3,13 @@ package unit
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
 	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +48,77 @@ func TestGetEllipsoidToGeoidZOffsetFrom4326Correct(t *testing.T) {
 		)
 	}
 }
+
+func TestGetEllipsoidToGeoidZOffsetWithDefaultModel(t *testing.T) {
+	calculator, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(proj4_coordinate_converter.NewProj4CoordinateConverter(), "EGM180", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+
+	expected := 48.95
+	output, err := calculator.GetEllipsoidToGeoidOffset(41.343825, 14.902954, 4326)
+	if err != nil {
+		t.Errorf("Unexpected error occurred: %s", err.Error())
+	}
+	if math.Abs(expected-output) > 1E-3 {
+		t.Errorf("Expected X:%.3f, got X:%.3f", expected, output)
+	}
+}
+
+func TestGetEllipsoidToGeoidZOffsetWithModelFile(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "egm*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "egm.txt")
+
+	// the egm180 coefficients up to degree 10 with the Fortran exponents and the standard deviations of EGM2008 files
+	content, err := ioutil.ReadFile(path.Join(tools.GetRootFolder(), "assets", "egm180.nor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 4 {
+			if degree, _ := strconv.Atoi(fields[0]); degree <= 10 {
+				lines = append(lines, strings.Join(fields, " ")+" 0.1D-11 0.1D-11")
+			}
+		}
+	}
+	modelFile := strings.ReplaceAll(strings.Join(lines, "\n"), "E", "D")
+	if err := ioutil.WriteFile(filePath, []byte("0 0 1.0D+00 0.0D+00\n"+modelFile), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	converter := proj4_coordinate_converter.NewProj4CoordinateConverter()
+	fromFile, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, filePath, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	truncated, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, "egm180", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	full, _ := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, "egm180", 180)
+
+	for _, coordinates := range [][2]float64{{41.343825, 14.902954}, {-33.9, 151.2}, {64.1, -21.9}} {
+		expected, _ := truncated.GetEllipsoidToGeoidOffset(coordinates[0], coordinates[1], 4326)
+		output, _ := fromFile.GetEllipsoidToGeoidOffset(coordinates[0], coordinates[1], 4326)
+		if math.Abs(expected-output) > 1E-6 {
+			t.Errorf("Expected X:%.6f, got X:%.6f", expected, output)
+		}
+		fullOutput, _ := full.GetEllipsoidToGeoidOffset(coordinates[0], coordinates[1], 4326)
+		if math.Abs(fullOutput-output) < 1E-2 {
+			t.Errorf("Expected the model truncated at degree 10 to differ from the full one, got %.6f and %.6f", output, fullOutput)
+		}
+	}
+}
+
+func TestGetEllipsoidToGeoidZOffsetWithInvalidModel(t *testing.T) {
+	converter := proj4_coordinate_converter.NewProj4CoordinateConverter()
+	if _, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, "egm180", 181); err == nil {
+		t.Errorf("Expected error truncating the model at a degree higher than its max one")
+	}
+	if _, err := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculatorForModel(converter, "missing.egm", 0); err == nil {
+		t.Errorf("Expected error loading a missing model")
+	}
+}
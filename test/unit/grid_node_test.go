@@ -4,6 +4,8 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scorers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"math"
 	"testing"
 )
@@ -11,9 +13,10 @@ import (
 func TestGridNodeAddDataPointSinglePoint(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -42,9 +45,10 @@ func TestGridNodeAddDataPointSinglePoint(t *testing.T) {
 func TestGridNodeAddDataPointMultiplePoints(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		10.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -75,26 +79,34 @@ func TestGridNodeAddDataPointMultiplePoints(t *testing.T) {
 	}
 }
 
-func TestGridNodeGetInternalSrid(t *testing.T) {
+func TestGridNodeToWGS84Cartesian(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
-		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(-10, 10, -10, 10, -10, 10),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
-	if node.GetInternalSrid() != 3395 {
-		t.Errorf("Expected Internal Srid %d, got %d returned", 3395, node.GetInternalSrid())
+	// the origin of the local frame, expected to match the EPSG:4978 coordinates of lon 14, lat 41, height 0
+	ecef, err := node.ToWGS84Cartesian(geometry.Coordinate{X: 0, Y: 0, Z: 0}, &mockCoordinateConverter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if math.Abs(ecef.X-4677398.86) > 0.01 || math.Abs(ecef.Y-1166206.52) > 0.01 || math.Abs(ecef.Z-4162423.20) > 0.01 {
+		t.Errorf("Expected coordinate (%f, %f, %f), got (%f, %f, %f)", 4677398.86, 1166206.52, 4162423.20, ecef.X, ecef.Y, ecef.Z)
 	}
 }
 
 func TestGridNodeGetIsRootTrue(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -106,9 +118,10 @@ func TestGridNodeGetIsRootTrue(t *testing.T) {
 func TestGridNodeGetIsRootFalse(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		false,
 	)
 
@@ -118,28 +131,39 @@ func TestGridNodeGetIsRootFalse(t *testing.T) {
 }
 
 func TestGridNodeGetBoundingBoxRegion(t *testing.T) {
-	inputRegion := geometry.NewBoundingBox(14, 15, 41, 42, 1, 2)
 	node := grid_tree.NewGridNode(
 		nil,
-		inputRegion,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(-100, 100, -100, 100, 1, 2),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		false,
 	)
 
 	region, _ := node.GetBoundingBoxRegion(&mockCoordinateConverter{})
 
-	if region != inputRegion {
-		t.Errorf("Expected region equal to node bounding box")
+	toRadians := math.Pi / 180
+	if region.Xmin >= 14*toRadians || region.Ymin <= 14*toRadians || region.Xmax >= 41*toRadians || region.Ymax <= 41*toRadians {
+		t.Errorf("Expected region to contain the frame origin, got %v", region.GetAsArray())
+	}
+
+	// 100 meters along the meridian correspond to about 0.0009 degrees at latitude 41
+	if math.Abs((region.Ymax-region.Xmax)/toRadians-0.0018) > 0.0001 {
+		t.Errorf("Expected region latitude span of %f degrees, got %f", 0.0018, (region.Ymax-region.Xmax)/toRadians)
+	}
+
+	if region.Zmin < 0.99 || region.Zmax < 2 {
+		t.Errorf("Expected region heights to enclose the node box heights, got %f, %f", region.Zmin, region.Zmax)
 	}
 }
 
 func TestGridNodeGetChildren(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		5.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -182,9 +206,10 @@ func TestGridNodeGetChildren(t *testing.T) {
 func TestGridNodeGetPoints(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -215,9 +240,10 @@ func TestGridNodeGetPoints(t *testing.T) {
 func TestGridNodeGetTotalNumberOfPoints(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		1.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
 		true,
 	)
 
@@ -240,9 +266,10 @@ func TestGridNodeGetTotalNumberOfPoints(t *testing.T) {
 func TestGridNodeGetNumberOfPoints(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		true,
 	)
 
@@ -269,9 +296,10 @@ func TestGridNodeGetNumberOfPoints(t *testing.T) {
 func TestGridNodeIsLeaf(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		true,
 	)
 
@@ -295,9 +323,10 @@ func TestGridNodeIsLeaf(t *testing.T) {
 func TestGridNodeIsInitialized(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		true,
 	)
 
@@ -316,9 +345,10 @@ func TestGridNodeIsInitialized(t *testing.T) {
 func TestGridNodeComputeGeometricError(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		false,
 	)
 
@@ -331,9 +361,10 @@ func TestGridNodeComputeGeometricError(t *testing.T) {
 func TestRootGridNodeComputeGeometricError(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 16, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		true,
 	)
 
@@ -346,9 +377,10 @@ func TestRootGridNodeComputeGeometricError(t *testing.T) {
 func TestGridNodeGetParent(t *testing.T) {
 	node := grid_tree.NewGridNode(
 		nil,
+		geometry.NewLocalFrame(14, 41, 0),
 		geometry.NewBoundingBox(14, 15, 41, 42, 1, 2),
 		1.0,
-		0.5,
+		&tiler.TilerOptions{CellMinSize: 0.5},
 		true,
 	)
 
@@ -368,3 +400,165 @@ func TestGridNodeGetParent(t *testing.T) {
 		t.Errorf("Unexpected parent node")
 	}
 }
+
+func TestGridNodeMaxNumberOfPointsPushesPointsToChildren(t *testing.T) {
+	node := grid_tree.NewGridNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		0.5,
+		&tiler.TilerOptions{CellMinSize: 1.0, GridMaxNumPointsPerNode: 2},
+		true,
+	)
+
+	// all points fall in the same cell, which stores all of them as its size is below the min cell size
+	for i := 0; i < 5; i++ {
+		node.AddDataPoint(data.NewPoint(1+float64(i)*0.01, 1, 1, 0, 0, 0, 0, 0))
+	}
+
+	node.(*grid_tree.GridNode).BuildPoints()
+
+	if len(node.GetPoints()) != 2 {
+		t.Errorf("Expected %d points in the node, got %d", 2, len(node.GetPoints()))
+	}
+
+	if node.TotalNumberOfPoints() != 5 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 5, node.TotalNumberOfPoints())
+	}
+
+	if len(node.GetChildren()[0].GetPoints()) != 2 {
+		t.Errorf("Expected %d points in the first child, got %d", 2, len(node.GetChildren()[0].GetPoints()))
+	}
+
+	if node.IsLeaf() {
+		t.Errorf("Expected node not to be a leaf")
+	}
+}
+
+func TestGridNodeMaxDepthStoresAllPoints(t *testing.T) {
+	node := grid_tree.NewGridNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		0.5,
+		&tiler.TilerOptions{CellMinSize: 1.0, GridMaxNumPointsPerNode: 2, GridMaxDepth: 1},
+		true,
+	)
+
+	for i := 0; i < 10; i++ {
+		node.AddDataPoint(data.NewPoint(1, 1, 1, 0, 0, 0, 0, 0))
+	}
+
+	node.(*grid_tree.GridNode).BuildPoints()
+
+	child := node.GetChildren()[0]
+	if len(child.GetPoints()) != 8 {
+		t.Errorf("Expected %d points in the child at max depth, got %d", 8, len(child.GetPoints()))
+	}
+
+	for _, grandChild := range child.GetChildren() {
+		if grandChild != nil {
+			t.Errorf("Expected node at max depth to have no children")
+		}
+	}
+}
+
+func TestGridNodeMaxNumberOfPointsWithCoincidentPointsTerminates(t *testing.T) {
+	node := grid_tree.NewGridNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		0.5,
+		&tiler.TilerOptions{CellMinSize: 1.0, GridMaxNumPointsPerNode: 1},
+		true,
+	)
+
+	for i := 0; i < 100; i++ {
+		node.AddDataPoint(data.NewPoint(1, 1, 1, 0, 0, 0, 0, 0))
+	}
+
+	if node.TotalNumberOfPoints() != 100 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 100, node.TotalNumberOfPoints())
+	}
+}
+
+func TestGridNodeTieBreakDoesNotDependOnInsertionOrder(t *testing.T) {
+	build := func(points ...*data.Point) []*data.Point {
+		node := grid_tree.NewGridNode(
+			nil,
+			geometry.NewLocalFrame(14, 41, 0),
+			geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+			10.0,
+			&tiler.TilerOptions{CellMinSize: 1.0, Deterministic: true},
+			true,
+		)
+		for _, point := range points {
+			node.AddDataPoint(point)
+		}
+		node.(*grid_tree.GridNode).BuildPoints()
+		return node.GetPoints()
+	}
+
+	// both points are 1 meter far from the cell center
+	point := data.NewPoint(4, 5, 5, 0, 0, 0, 0, 0)
+	point2 := data.NewPoint(6, 5, 5, 0, 0, 0, 0, 0)
+
+	first := build(point, point2)
+	second := build(point2, point)
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("Expected one point stored in the node")
+	}
+	if first[0] != point || second[0] != point {
+		t.Errorf("Expected the point with the lowest coordinates to be kept regardless of the insertion order")
+	}
+}
+
+func TestGridNodePrefersPriorityClasses(t *testing.T) {
+	node := grid_tree.NewGridNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		10.0,
+		&tiler.TilerOptions{CellMinSize: 1.0, ClassPriorities: map[uint8]int{14: 1}},
+		true,
+	)
+
+	ground := data.NewPoint(5, 5, 5, 0, 0, 0, 0, 2)
+	wire := data.NewPoint(9, 9, 9, 0, 0, 0, 0, 14)
+	farGround := data.NewPoint(1, 1, 1, 0, 0, 0, 0, 2)
+
+	node.AddDataPoint(ground)
+	node.AddDataPoint(wire)
+	node.AddDataPoint(farGround)
+	node.(*grid_tree.GridNode).BuildPoints()
+
+	if len(node.GetPoints()) != 1 || node.GetPoints()[0] != wire {
+		t.Errorf("Expected the priority class point to be stored in the node")
+	}
+	if node.TotalNumberOfPoints() != 3 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 3, node.TotalNumberOfPoints())
+	}
+}
+
+func TestGridNodeKeepsPointWithHighestScore(t *testing.T) {
+	scorer, _ := scorers.ParsePointScorer("intensity", nil)
+	node := grid_tree.NewGridNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		10.0,
+		&tiler.TilerOptions{CellMinSize: 1.0, PointScorer: scorer},
+		true,
+	)
+
+	center := data.NewPoint(5, 5, 5, 0, 0, 0, 10, 0)
+	bright := data.NewPoint(9, 9, 9, 0, 0, 0, 200, 0)
+
+	node.AddDataPoint(center)
+	node.AddDataPoint(bright)
+	node.(*grid_tree.GridNode).BuildPoints()
+
+	if len(node.GetPoints()) != 1 || node.GetPoints()[0] != bright {
+		t.Errorf("Expected the point with the highest intensity to be stored in the node")
+	}
+}
@@ -2,7 +2,11 @@ package unit
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sort"
 	"testing"
 )
 
@@ -24,10 +28,9 @@ func (m *mockCoordinateConverter) Cleanup() {}
 
 func TestTreeAddPointSuccess(t *testing.T) {
 	tree := grid_tree.NewGridTree(
+		&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.1},
 		&mockCoordinateConverter{},
 		&mockElevationCorrector{},
-		5.0,
-		0.1,
 	)
 
 	x := 14.0
@@ -45,7 +48,7 @@ func TestTreeAddPointSuccess(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, nil, 4326)
 
 	point, hasMore := tree.(*grid_tree.GridTree).Loader.GetNext()
 
@@ -53,19 +56,37 @@ func TestTreeAddPointSuccess(t *testing.T) {
 		t.Errorf("Only one point loaded, GetNext should return false")
 	}
 
-	if point.X != x || point.Y != y || point.Z != 2.4 ||
+	// the local frame is anchored at the first point added, hence its local coordinates are all zero
+	if math.Abs(point.X) > 1e-6 || math.Abs(point.Y) > 1e-6 || math.Abs(point.Z) > 1e-6 ||
 		point.R != r || point.G != g || point.B != b ||
 		point.Intensity != i || point.Classification != c {
 		t.Errorf("Wrong point data found")
 	}
 }
 
+func TestTreeAddPointWithFrameOrigin(t *testing.T) {
+	tree := grid_tree.NewGridTree(
+		&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.1},
+		&mockCoordinateConverter{},
+		&mockElevationCorrector{},
+	)
+
+	tree.(*grid_tree.GridTree).SetFrameOrigin(&geometry.Coordinate{X: 14, Y: 41, Z: 0}, 4326)
+	tree.AddPoint(&geometry.Coordinate{X: 14, Y: 41.001, Z: 1.5}, 0, 0, 0, 0, 0, nil, 4326)
+
+	point, _ := tree.(*grid_tree.GridTree).Loader.GetNext()
+
+	// 0.001 degrees of latitude at latitude 41 are about 111.05 meters, elevation is doubled by the mock corrector
+	if math.Abs(point.X) > 1e-6 || math.Abs(point.Y-111.05) > 0.01 || math.Abs(point.Z-3) > 0.01 {
+		t.Errorf("Expected local coordinates (%f, %f, %f), got (%f, %f, %f)", 0.0, 111.05, 3.0, point.X, point.Y, point.Z)
+	}
+}
+
 func TestTreeBuildSuccess(t *testing.T) {
 	tree := grid_tree.NewGridTree(
+		&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.1},
 		&mockCoordinateConverter{},
 		&mockElevationCorrector{},
-		5.0,
-		0.1,
 	)
 
 	x := 14.0
@@ -83,7 +104,7 @@ func TestTreeBuildSuccess(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, nil, 4326)
 
 	err := tree.Build()
 
@@ -102,10 +123,9 @@ func TestTreeBuildSuccess(t *testing.T) {
 
 func TestGetRootNode(t *testing.T) {
 	tree := grid_tree.NewGridTree(
+		&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.1},
 		&mockCoordinateConverter{},
 		&mockElevationCorrector{},
-		5.0,
-		0.1,
 	)
 
 	x := 14.0
@@ -123,7 +143,7 @@ func TestGetRootNode(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, nil, 4326)
 
 	err := tree.Build()
 
@@ -144,3 +164,116 @@ func TestGetRootNode(t *testing.T) {
 
 // TODO add test to evaluate safety against race conditions while adding points,
 //  especially check against gridCell being correctly write locked when points slice is edited
+
+func TestTreeBulkBuildSamplesAsStreamingBuild(t *testing.T) {
+	assertBulkBuildSamplesAsStreamingBuild(t, 0)
+}
+
+func TestTreeBulkBuildSamplesAsStreamingBuildWithMaxNumberOfPoints(t *testing.T) {
+	assertBulkBuildSamplesAsStreamingBuild(t, 20)
+}
+
+func assertBulkBuildSamplesAsStreamingBuild(t *testing.T, maxNumPointsPerNode int32) {
+	build := func(bulk bool) octree.ITree {
+		tree := grid_tree.NewGridTree(
+			&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.5, GridMaxNumPointsPerNode: maxNumPointsPerNode, Deterministic: true, GridBulkBuild: bulk},
+			&mockCoordinateConverter{},
+			&mockElevationCorrector{},
+		)
+		for i := 0; i < 2000; i++ {
+			// pseudo random points in a box of about 30x30x10 meters
+			x := 14 + math.Mod(float64(i)*0.61803398875, 1)*0.0003
+			y := 41 + math.Mod(float64(i)*0.41421356237, 1)*0.0003
+			z := math.Mod(float64(i)*0.73205080757, 1) * 5
+			tree.AddPoint(&geometry.Coordinate{X: x, Y: y, Z: z}, 0, 0, 0, 0, 0, nil, 4326)
+		}
+		if err := tree.Build(); err != nil {
+			t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+		}
+		return tree
+	}
+
+	var compare func(path string, streaming octree.INode, bulk octree.INode)
+	compare = func(path string, streaming octree.INode, bulk octree.INode) {
+		streamingPoints := sortedCoordinates(streaming)
+		bulkPoints := sortedCoordinates(bulk)
+		if len(streamingPoints) != len(bulkPoints) {
+			t.Fatalf("Node %s stores %d points with the streaming build and %d with the bulk build", path, len(streamingPoints), len(bulkPoints))
+		}
+		for i := range streamingPoints {
+			if streamingPoints[i] != bulkPoints[i] {
+				t.Fatalf("Node %s stores different points with the streaming and the bulk build", path)
+			}
+		}
+		for i, child := range streaming.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				compare(path+"/"+string(rune('0'+i)), child, bulk.GetChildren()[i])
+			}
+		}
+	}
+
+	streaming := build(false)
+	bulk := build(true)
+	if bulk.GetRootNode().TotalNumberOfPoints() != 2000 {
+		t.Fatalf("Expected %d points in the tree, got %d", 2000, bulk.GetRootNode().TotalNumberOfPoints())
+	}
+	compare("root", streaming.GetRootNode(), bulk.GetRootNode())
+}
+
+func TestTreeWithPercentileBoundsKeepsOutliersWithinTheTileBounds(t *testing.T) {
+	tree := grid_tree.NewGridTree(
+		&tiler.TilerOptions{CellMaxSize: 5.0, CellMinSize: 0.5, BoundsPercentile: 1, Deterministic: true},
+		&mockCoordinateConverter{},
+		&mockElevationCorrector{},
+	)
+	for i := 0; i < 2000; i++ {
+		x := 14 + math.Mod(float64(i)*0.61803398875, 1)*0.0003
+		y := 41 + math.Mod(float64(i)*0.41421356237, 1)*0.0003
+		z := math.Mod(float64(i)*0.73205080757, 1) * 5
+		tree.AddPoint(&geometry.Coordinate{X: x, Y: y, Z: z}, 0, 0, 0, 0, 0, nil, 4326)
+	}
+	// a point 3 km above the cloud
+	tree.AddPoint(&geometry.Coordinate{X: 14.0001, Y: 41.0001, Z: 3000}, 0, 0, 0, 0, 0, nil, 4326)
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	if tree.GetRootNode().TotalNumberOfPoints() != 2001 {
+		t.Errorf("Expected %d points in the tree, got %d", 2001, tree.GetRootNode().TotalNumberOfPoints())
+	}
+	var check func(node octree.INode)
+	check = func(node octree.INode) {
+		box := node.GetBoundingBox()
+		for _, point := range node.GetPoints() {
+			if point.X < box.Xmin || point.X > box.Xmax || point.Y < box.Ymin || point.Y > box.Ymax || point.Z < box.Zmin || point.Z > box.Zmax {
+				t.Errorf("Point %v lies outside the bounding box of its node %v", point, box)
+			}
+		}
+		for _, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				childBox := child.GetBoundingBox()
+				if childBox.Zmax > box.Zmax || childBox.Zmin < box.Zmin {
+					t.Errorf("Bounding box %v of a child is not enclosed by the one of its parent %v", childBox, box)
+				}
+				check(child)
+			}
+		}
+	}
+	check(tree.GetRootNode())
+}
+
+func sortedCoordinates(node octree.INode) [][3]float64 {
+	var coordinates [][3]float64
+	for _, point := range node.GetPoints() {
+		coordinates = append(coordinates, [3]float64{point.X, point.Y, point.Z})
+	}
+	sort.Slice(coordinates, func(i, j int) bool {
+		for k := 0; k < 3; k++ {
+			if coordinates[i][k] != coordinates[j][k] {
+				return coordinates[i][k] < coordinates[j][k]
+			}
+		}
+		return false
+	})
+	return coordinates
+}
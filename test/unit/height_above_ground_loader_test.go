@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/colors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"math"
+	"testing"
+)
+
+func TestHeightAboveGroundLoaderMeasuresHeightsFromGround(t *testing.T) {
+	loader := point_loader.NewHeightAboveGroundLoader(point_loader.NewSequentialLoader(), 1)
+	// ground sloping along X, without ground points in a 4x4 meters clearing
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 40; y++ {
+			if x >= 16 && x < 24 && y >= 16 && y < 24 {
+				continue
+			}
+			loader.AddPoint(data.NewPoint(float64(x)*0.5, float64(y)*0.5, float64(x)*0.05, 0, 0, 0, 0, 2))
+		}
+	}
+	trees := []*data.Point{
+		data.NewPoint(5.2, 5.7, 0.52+12, 0, 0, 0, 0, 5),
+		data.NewPoint(10.1, 9.9, 1.01+3, 0, 0, 0, 0, 5),
+		data.NewPoint(15.3, 2.4, 1.53+7.5, 0, 0, 0, 0, 6),
+	}
+	trees[0].Attributes = []float32{42}
+	for _, point := range trees {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+
+	expected := []float64{12, 3, 7.5}
+	for i, point := range trees {
+		height := float64(point.Attributes[len(point.Attributes)-1])
+		if math.Abs(height-expected[i]) > 0.1 {
+			t.Errorf("Expected height above ground %f, got %f", expected[i], height)
+		}
+	}
+	if len(trees[0].Attributes) != 2 || trees[0].Attributes[0] != 42 {
+		t.Errorf("Expected height above ground to be appended to the existing attributes, got %v", trees[0].Attributes)
+	}
+
+	count := 0
+	for point, hasNext := loader.GetNext(); point != nil; point, hasNext = loader.GetNext() {
+		count++
+		if len(point.Attributes) == 0 {
+			t.Errorf("Expected every point to have the height above ground")
+		}
+		if !hasNext {
+			break
+		}
+	}
+	if count != 40*40-64+3 {
+		t.Errorf("Expected %d points, got %d", 40*40-64+3, count)
+	}
+}
+
+func TestHeightAboveGroundLoaderWithoutGroundUsesLowestPoint(t *testing.T) {
+	loader := point_loader.NewHeightAboveGroundLoader(point_loader.NewSequentialLoader(), 1)
+	points := []*data.Point{
+		data.NewPoint(0, 0, 100, 0, 0, 0, 0, 1),
+		data.NewPoint(50, 20, 104, 0, 0, 0, 0, 1),
+	}
+	for _, point := range points {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+
+	if points[0].Attributes[0] != 0 || points[1].Attributes[0] != 4 {
+		t.Errorf("Expected heights 0 and 4, got %v and %v", points[0].Attributes, points[1].Attributes)
+	}
+}
+
+func TestHeightAboveGroundLoaderColorsPointsByHeight(t *testing.T) {
+	loader := point_loader.NewHeightAboveGroundLoader(point_loader.NewSequentialLoader(), 1)
+	loader.SetColorRamp(colors.Grayscale, 0, 20)
+	points := []*data.Point{
+		data.NewPoint(0, 0, 10, 0, 0, 0, 0, 2),
+		data.NewPoint(0.2, 0.2, 15, 0, 0, 0, 0, 4),
+		data.NewPoint(0.4, 0.4, 30, 0, 0, 0, 0, 5),
+	}
+	for _, point := range points {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+
+	expected := []uint8{0, 64, 255}
+	for i, point := range points {
+		if point.R != expected[i] || point.G != expected[i] || point.B != expected[i] {
+			t.Errorf("Expected gray %d, got %d,%d,%d", expected[i], point.R, point.G, point.B)
+		}
+	}
+}
+
+func TestHeightAboveGroundLoaderDefaultColorRangeDoesNotDependOnThePoints(t *testing.T) {
+	colorize := func(top float64) *data.Point {
+		loader := point_loader.NewHeightAboveGroundLoader(point_loader.NewSequentialLoader(), 1)
+		loader.SetColorRamp(colors.Grayscale, 0, 0)
+		point := data.NewPoint(0.2, 0.2, 15, 0, 0, 0, 0, 4)
+		loader.AddPoint(data.NewPoint(0, 0, 10, 0, 0, 0, 0, 2))
+		loader.AddPoint(point)
+		loader.AddPoint(data.NewPoint(0.4, 0.4, top, 0, 0, 0, 0, 5))
+		loader.InitializeLoader()
+		return point
+	}
+
+	// the same height gets the same color whatever the highest point loaded
+	low, high := colorize(20), colorize(60)
+	if low.R != high.R || low.G != high.G || low.B != high.B {
+		t.Errorf("Expected the same color for the same height, got %d,%d,%d and %d,%d,%d", low.R, low.G, low.B, high.R, high.G, high.B)
+	}
+	if expected := uint8(math.Round(5 / colors.DefaultHeightAboveGroundMax * 255)); low.R != expected {
+		t.Errorf("Expected gray %d, got %d", expected, low.R)
+	}
+}
@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/kd_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestKdNodeWithinMaxPointsIsLeaf(t *testing.T) {
+	node := kd_tree.NewKdNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 100, 0, 100, 0, 100),
+		&tiler.TilerOptions{MaxNumPointsPerNode: 10},
+		true,
+	)
+
+	for i := 0; i < 10; i++ {
+		node.AddDataPoint(data.NewPoint(float64(i), 2, 3, 0, 0, 0, 0, 0))
+	}
+	node.(*kd_tree.KdNode).BuildPoints()
+
+	if !node.IsLeaf() || len(node.GetPoints()) != 10 {
+		t.Errorf("Expected node to be a leaf storing %d points, got %d", 10, len(node.GetPoints()))
+	}
+
+	bbox := node.GetBoundingBox()
+	if bbox.Xmin != 0 || bbox.Xmax != 9 || bbox.Ymin != 2 || bbox.Ymax != 2 || bbox.Zmin != 3 || bbox.Zmax != 3 {
+		t.Errorf("Expected the bounding box to enclose tightly the points, got %v", bbox.GetAsArray())
+	}
+}
+
+func TestKdNodeSplitsAlongMedianOfLongestAxis(t *testing.T) {
+	node := kd_tree.NewKdNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 1000, 0, 1000, 0, 1000),
+		&tiler.TilerOptions{MaxNumPointsPerNode: 4},
+		true,
+	)
+
+	// points spread along the Y axis, with an uneven density
+	for i := 0; i < 20; i++ {
+		node.AddDataPoint(data.NewPoint(1, float64(i*i), float64(i%2), 0, 0, 0, 0, 0))
+	}
+	node.(*kd_tree.KdNode).BuildPoints()
+
+	if node.IsLeaf() || len(node.GetPoints()) != 4 {
+		t.Fatalf("Expected node to store a sample of %d points, got %d", 4, len(node.GetPoints()))
+	}
+
+	children := node.GetChildren()
+	for i := 2; i < 8; i++ {
+		if children[i] != nil {
+			t.Errorf("Expected no child at index %d", i)
+		}
+	}
+	if children[0].TotalNumberOfPoints() != 8 || children[1].TotalNumberOfPoints() != 8 {
+		t.Fatalf("Expected children to hold %d points each, got %d and %d", 8, children[0].TotalNumberOfPoints(), children[1].TotalNumberOfPoints())
+	}
+	if children[0].GetBoundingBox().Ymax > children[1].GetBoundingBox().Ymin {
+		t.Errorf("Expected children to be split along the Y axis")
+	}
+
+	var countPoints func(node octree.INode) int
+	countPoints = func(node octree.INode) int {
+		if node == nil {
+			return 0
+		}
+		if node.NumberOfPoints() > 4 {
+			t.Errorf("Expected at most %d points per node, got %d", 4, node.NumberOfPoints())
+		}
+		count := len(node.GetPoints())
+		for _, child := range node.GetChildren() {
+			count += countPoints(child)
+		}
+		return count
+	}
+	if countPoints(node) != 20 {
+		t.Errorf("Expected all the %d points to be stored in the tree", 20)
+	}
+}
+
+func TestKdNodeGeometricErrorDoesNotExceedParentOne(t *testing.T) {
+	node := kd_tree.NewKdNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 1000, 0, 1000, 0, 1000),
+		&tiler.TilerOptions{MaxNumPointsPerNode: 100},
+		true,
+	)
+
+	// the children store a dense sample of 100 points, the grandchildren are sparse leaves with about 25 points each
+	for i := 0; i < 402; i++ {
+		node.AddDataPoint(data.NewPoint(float64(i%21)*10, float64(i/21)*10, 0, 0, 0, 0, 0, 0))
+	}
+	node.(*kd_tree.KdNode).BuildPoints()
+
+	var checkErrors func(node octree.INode, depth int) int
+	checkErrors = func(node octree.INode, depth int) int {
+		maxDepth := depth
+		for _, child := range node.GetChildren() {
+			if child == nil {
+				continue
+			}
+			if child.ComputeGeometricError() > node.ComputeGeometricError() {
+				t.Errorf("Expected the geometric error %f of a node with %d points not to exceed the error %f of its parent with %d points", child.ComputeGeometricError(), child.NumberOfPoints(), node.ComputeGeometricError(), node.NumberOfPoints())
+			}
+			if childDepth := checkErrors(child, depth+1); childDepth > maxDepth {
+				maxDepth = childDepth
+			}
+		}
+		return maxDepth
+	}
+	if depth := checkErrors(node, 0); depth != 2 {
+		t.Errorf("Expected a tree of depth %d, got %d", 2, depth)
+	}
+}
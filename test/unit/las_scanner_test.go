@@ -0,0 +1,169 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+// writes a las 1.2 file of point format 0 storing the given coordinates with a scale of 0.01 and the given intensities
+func writeTestLas(t *testing.T, filePath string, coordinates [][3]float64, intensities []uint16) {
+	const headerSize, recordLength = 227, 20
+	content := make([]byte, headerSize)
+	copy(content, "LASF")
+	content[24], content[25] = 1, 2
+	binary.LittleEndian.PutUint16(content[94:], headerSize)
+	binary.LittleEndian.PutUint32(content[96:], headerSize)
+	content[104] = 0
+	binary.LittleEndian.PutUint16(content[105:], recordLength)
+	binary.LittleEndian.PutUint32(content[107:], uint32(len(coordinates)))
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint64(content[131+8*i:], math.Float64bits(0.01))
+	}
+	bounds := [6]float64{math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1)}
+	for i, coordinate := range coordinates {
+		record := make([]byte, recordLength)
+		for j, value := range coordinate {
+			binary.LittleEndian.PutUint32(record[4*j:], uint32(int32(math.Round(value*100))))
+			bounds[2*j] = math.Max(bounds[2*j], value)
+			bounds[2*j+1] = math.Min(bounds[2*j+1], value)
+		}
+		if intensities != nil {
+			binary.LittleEndian.PutUint16(record[12:], intensities[i])
+		}
+		content = append(content, record...)
+	}
+	for i, value := range bounds {
+		binary.LittleEndian.PutUint64(content[179+8*i:], math.Float64bits(value))
+	}
+
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitLasFileWritesEachPointOnce(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "las*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "input.las")
+	writeTestLas(t, filePath, [][3]float64{{1, 1, 10}, {7, 2, 11}, {3, 8, 12}, {9, 9, 13}, {2, 2, 14}}, nil)
+
+	outputs := []string{path.Join(tempdir, "west.las"), path.Join(tempdir, "east.las")}
+	counts, err := lidario.SplitLasFile(filePath, nil, outputs, func(x, y, z float64) int {
+		if z == 12 {
+			return -1
+		}
+		if x < 5 {
+			return 0
+		}
+		return 1
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("Expected 2 points in each output, got %v", counts)
+	}
+
+	expected := [][][3]float64{{{1, 1, 10}, {2, 2, 14}}, {{7, 2, 11}, {9, 9, 13}}}
+	for i, output := range outputs {
+		header, err := lidario.ReadLasHeader(output)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if header.NumberPoints != 2 || header.MinX != expected[i][0][0] || header.MaxX != expected[i][1][0] || header.MinZ != expected[i][0][2] || header.MaxZ != expected[i][1][2] {
+			t.Errorf("Unexpected header of output %d: %d points, x %f %f, z %f %f", i, header.NumberPoints, header.MinX, header.MaxX, header.MinZ, header.MaxZ)
+		}
+
+		var coordinates [][3]float64
+		err = lidario.ScanLasFile(output, nil, func(x, y, z float64, record []byte) error {
+			coordinates = append(coordinates, [3]float64{x, y, z})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(coordinates) != 2 {
+			t.Fatalf("Expected 2 points in output %d, got %d", i, len(coordinates))
+		}
+		for j, coordinate := range coordinates {
+			for k := range coordinate {
+				if math.Abs(coordinate[k]-expected[i][j][k]) > 1e-9 {
+					t.Errorf("Expected point %v in output %d, got %v", expected[i][j], i, coordinate)
+				}
+			}
+		}
+	}
+}
+
+func TestSplitLasFileUpdatesThePointsByReturnAndClearsTheExtendedRecords(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "las*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "input.las")
+	writeTestLas(t, filePath, [][3]float64{{1, 1, 10}, {2, 2, 11}, {7, 2, 12}, {9, 9, 13}}, nil)
+
+	// converts the file to las 1.4, storing the first return of two in the first point and the second return in the
+	// second one, the others being single returns, and declaring an extended variable length record
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const headerSize = 375
+	for i, returns := range []byte{1 | 2<<3, 2 | 2<<3, 1 | 1<<3, 1 | 1<<3} {
+		content[227+20*i+14] = returns
+	}
+	content = append(content[:227], append(make([]byte, headerSize-227), content[227:]...)...)
+	content[25] = 4
+	binary.LittleEndian.PutUint16(content[94:], headerSize)
+	binary.LittleEndian.PutUint32(content[96:], headerSize)
+	binary.LittleEndian.PutUint32(content[111:], 3)
+	binary.LittleEndian.PutUint32(content[115:], 1)
+	binary.LittleEndian.PutUint64(content[235:], headerSize+4*20)
+	binary.LittleEndian.PutUint32(content[243:], 1)
+	binary.LittleEndian.PutUint64(content[247:], 4)
+	binary.LittleEndian.PutUint64(content[255:], 3)
+	binary.LittleEndian.PutUint64(content[263:], 1)
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs := []string{path.Join(tempdir, "west.las"), path.Join(tempdir, "east.las")}
+	_, err = lidario.SplitLasFile(filePath, nil, outputs, func(x, y, z float64) int {
+		if x < 5 {
+			return 0
+		}
+		return 1
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedByReturn := [][2]uint64{{1, 1}, {2, 0}}
+	for i, output := range outputs {
+		header, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := binary.LittleEndian.Uint32(header[107:]); count != 2 {
+			t.Errorf("Expected legacy number of points %d in output %d, got %d", 2, i, count)
+		}
+		if count := binary.LittleEndian.Uint64(header[247:]); count != 2 {
+			t.Errorf("Expected number of points %d in output %d, got %d", 2, i, count)
+		}
+		for j, expected := range expectedByReturn[i] {
+			if count := binary.LittleEndian.Uint32(header[111+4*j:]); uint64(count) != expected {
+				t.Errorf("Expected legacy number of points of return %d %d in output %d, got %d", j+1, expected, i, count)
+			}
+			if count := binary.LittleEndian.Uint64(header[255+8*j:]); count != expected {
+				t.Errorf("Expected number of points of return %d %d in output %d, got %d", j+1, expected, i, count)
+			}
+		}
+		if offset, count := binary.LittleEndian.Uint64(header[235:]), binary.LittleEndian.Uint32(header[243:]); offset != 0 || count != 0 {
+			t.Errorf("Expected no extended variable length records in output %d, got %d at %d", i, count, offset)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"testing"
+)
+
+func TestLocalFrameOriginIsZero(t *testing.T) {
+	frame := geometry.NewLocalFrame(14, 41, 100)
+	local := frame.FromGeodetic(14, 41, 100)
+
+	if math.Abs(local.X) > 1e-6 || math.Abs(local.Y) > 1e-6 || math.Abs(local.Z) > 1e-6 {
+		t.Errorf("Expected origin at (0, 0, 0), got (%f, %f, %f)", local.X, local.Y, local.Z)
+	}
+}
+
+func TestLocalFrameGeodeticRoundTrip(t *testing.T) {
+	testCases := [][]float64{{14, 41, 100}, {-120, -33, -20}, {179.5, 60, 1500}, {45, 89.99, 10}, {0, -89.99, 0}}
+	for _, testCase := range testCases {
+		frame := geometry.NewLocalFrame(testCase[0], testCase[1], 0)
+		local := frame.FromGeodetic(testCase[0]+0.001, testCase[1]-0.001, testCase[2])
+		geodetic := frame.ToGeodetic(local)
+
+		if math.Abs(geodetic.X-testCase[0]-0.001) > 1e-8 || math.Abs(geodetic.Y-testCase[1]+0.001) > 1e-8 || math.Abs(geodetic.Z-testCase[2]) > 1e-4 {
+			t.Errorf("Round trip of %v failed, got %v", testCase, geodetic)
+		}
+	}
+}
+
+func TestLocalFramePreservesDistancesAtHighLatitudes(t *testing.T) {
+	frame := geometry.NewLocalFrame(10, 70, 0)
+
+	// two points on the same parallel, whose geodesic distance is computed on the ellipsoid
+	a := frame.FromGeodetic(10, 70, 0)
+	b := frame.FromGeodetic(10.001, 70, 0)
+	ecefA := geometry.GeodeticToGeocentric(10, 70, 0)
+	ecefB := geometry.GeodeticToGeocentric(10.001, 70, 0)
+
+	localDistance := math.Sqrt(math.Pow(a.X-b.X, 2) + math.Pow(a.Y-b.Y, 2) + math.Pow(a.Z-b.Z, 2))
+	ecefDistance := math.Sqrt(math.Pow(ecefA.X-ecefB.X, 2) + math.Pow(ecefA.Y-ecefB.Y, 2) + math.Pow(ecefA.Z-ecefB.Z, 2))
+
+	if math.Abs(localDistance-ecefDistance) > 1e-6 {
+		t.Errorf("Expected distance %f, got %f", ecefDistance, localDistance)
+	}
+
+	// about 38.2 meters, while in World Mercator the same segment would measure about 111 meters
+	if math.Abs(localDistance-38.2) > 0.1 {
+		t.Errorf("Expected distance of about %f meters, got %f", 38.2, localDistance)
+	}
+}
+
+func TestLocalFrameWithHeadingRotatesAxes(t *testing.T) {
+	north := geometry.NewLocalFrame(14, 41, 100)
+	// Y axis pointing east, X axis pointing south
+	rotated := geometry.NewLocalFrameWithHeading(14, 41, 100, 90)
+
+	local := north.ToGeodetic(geometry.Coordinate{X: 10, Y: 20, Z: 5})
+	converted := rotated.FromGeodetic(local.X, local.Y, local.Z)
+	if math.Abs(converted.X+20) > 1e-6 || math.Abs(converted.Y-10) > 1e-6 || math.Abs(converted.Z-5) > 1e-6 {
+		t.Errorf("Expected (-20, 10, 5), got (%f, %f, %f)", converted.X, converted.Y, converted.Z)
+	}
+}
+
+func TestLocalFrameTransformMatchesToGeocentric(t *testing.T) {
+	frame := geometry.NewLocalFrameWithHeading(-73.98, 40.75, 12, 33)
+	transform := frame.GetTransform()
+	local := geometry.Coordinate{X: 120, Y: -45, Z: 30}
+
+	expected := frame.ToGeocentric(local)
+	x := transform[0]*local.X + transform[4]*local.Y + transform[8]*local.Z + transform[12]
+	y := transform[1]*local.X + transform[5]*local.Y + transform[9]*local.Z + transform[13]
+	z := transform[2]*local.X + transform[6]*local.Y + transform[10]*local.Z + transform[14]
+	if math.Abs(x-expected.X) > 1e-6 || math.Abs(y-expected.Y) > 1e-6 || math.Abs(z-expected.Z) > 1e-6 || transform[15] != 1 {
+		t.Errorf("Expected (%f, %f, %f), got (%f, %f, %f)", expected.X, expected.Y, expected.Z, x, y, z)
+	}
+}
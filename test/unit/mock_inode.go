@@ -24,6 +24,7 @@ type mockNode struct {
 	leaf                bool
 	initialized         bool
 	geometricError      float64
+	nonAdditive         bool
 	sync.RWMutex
 }
 
@@ -43,6 +44,10 @@ func (mockNode *mockNode) GetBoundingBoxRegion(converter converters.CoordinateCo
 	return reg, nil
 }
 
+func (mockNode *mockNode) ToWGS84Cartesian(coordinate geometry.Coordinate, converter converters.CoordinateConverter) (geometry.Coordinate, error) {
+	return converter.ConvertToWGS84Cartesian(coordinate, mockNode.GetInternalSrid())
+}
+
 func (mockNode *mockNode) GetBoundingBox() *geometry.BoundingBox {
 	return mockNode.boundingBox
 }
@@ -86,3 +91,7 @@ func (mockNode *mockNode) ComputeGeometricError() float64 {
 func (mockNode *mockNode) GetParent() octree.INode {
 	return mockNode.parent
 }
+
+func (mockNode *mockNode) IsAdditive() bool {
+	return !mockNode.nonAdditive
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"testing"
+)
+
+func TestNoiseFilterLoaderIsNotUsedIfDisabled(t *testing.T) {
+	sequentialLoader := point_loader.NewSequentialLoader()
+	loader := point_loader.NewNoiseFilterLoader(sequentialLoader, 1, 0, 0, 1)
+
+	if loader != sequentialLoader {
+		t.Errorf("Expected the wrapped loader to be returned unchanged")
+	}
+}
+
+func TestNoiseFilterLoaderRemovesPointsWithFewNeighbours(t *testing.T) {
+	loader := point_loader.NewNoiseFilterLoader(point_loader.NewSequentialLoader(), 1, 3, 0, 0)
+	noise := addNoisyCloud(loader)
+
+	assertNoiseIsRemoved(t, loader, noise)
+}
+
+func TestNoiseFilterLoaderRemovesPointsWithDistantNearestNeighbours(t *testing.T) {
+	loader := point_loader.NewNoiseFilterLoader(point_loader.NewSequentialLoader(), 0, 0, 4, 1)
+	noise := addNoisyCloud(loader)
+
+	assertNoiseIsRemoved(t, loader, noise)
+}
+
+// adds a 10x10x10 lattice of points spaced 0.5m and two isolated points, which are returned
+func addNoisyCloud(loader point_loader.Loader) []*data.Point {
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			for z := 0; z < 10; z++ {
+				loader.AddPoint(data.NewPoint(float64(x)*0.5, float64(y)*0.5, float64(z)*0.5, 0, 0, 0, 0, 0))
+			}
+		}
+	}
+	noise := []*data.Point{
+		data.NewPoint(2, 2, 50, 0, 0, 0, 0, 0),
+		data.NewPoint(-20, 3, 1, 0, 0, 0, 0, 0),
+	}
+	for _, point := range noise {
+		loader.AddPoint(point)
+	}
+
+	return noise
+}
+
+func assertNoiseIsRemoved(t *testing.T, loader point_loader.Loader, noise []*data.Point) {
+	bounds := loader.GetBounds()
+	if bounds[0] != 0 || bounds[5] != 4.5 {
+		t.Errorf("Expected bounds to exclude the noise points, got %v", bounds)
+	}
+	loader.InitializeLoader()
+
+	var returned int
+	for {
+		point, hasNext := loader.GetNext()
+		if point != nil {
+			returned++
+			for _, noisePoint := range noise {
+				if point == noisePoint {
+					t.Errorf("Unexpected noise point returned: %v", point)
+				}
+			}
+		}
+		if !hasNext {
+			break
+		}
+	}
+
+	if returned != 1000 {
+		t.Errorf("Expected %d points, got %d", 1000, returned)
+	}
+	if loader.GetNumberOfRejectedPoints() != 2 {
+		t.Errorf("Expected %d rejected points, got %d", 2, loader.GetNumberOfRejectedPoints())
+	}
+}
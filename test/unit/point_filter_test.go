@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestPointFilterIncludedAndExcludedClasses(t *testing.T) {
+	filter := &tiler.PointFilter{
+		IncludedClasses: map[uint8]bool{2: true, 6: true},
+		ExcludedClasses: map[uint8]bool{6: true},
+		Returns:         tiler.AllReturns,
+	}
+
+	if !filter.Accepts(2, 1, 1, false, false) {
+		t.Errorf("Expected included class 2 to be accepted")
+	}
+	if filter.Accepts(6, 1, 1, false, false) {
+		t.Errorf("Expected excluded class 6 to be rejected")
+	}
+	if filter.Accepts(7, 1, 1, false, false) {
+		t.Errorf("Expected class 7 not included to be rejected")
+	}
+}
+
+func TestPointFilterReturns(t *testing.T) {
+	expectations := []struct {
+		returns         tiler.ReturnFilter
+		returnNumber    uint8
+		numberOfReturns uint8
+		accepted        bool
+	}{
+		{tiler.FirstReturns, 1, 3, true},
+		{tiler.FirstReturns, 2, 3, false},
+		{tiler.LastReturns, 3, 3, true},
+		{tiler.LastReturns, 1, 3, false},
+		{tiler.OnlyReturns, 1, 1, true},
+		{tiler.OnlyReturns, 1, 2, false},
+		{tiler.AllReturns, 2, 3, true},
+	}
+
+	for _, expectation := range expectations {
+		filter := &tiler.PointFilter{Returns: expectation.returns}
+		if filter.Accepts(2, expectation.returnNumber, expectation.numberOfReturns, false, false) != expectation.accepted {
+			t.Errorf("Expected return %d of %d accepted = %t with returns %s", expectation.returnNumber, expectation.numberOfReturns, expectation.accepted, expectation.returns)
+		}
+	}
+}
+
+func TestPointFilterFlags(t *testing.T) {
+	filter := &tiler.PointFilter{Returns: tiler.AllReturns, ExcludeWithheld: true}
+
+	if filter.Accepts(2, 1, 1, true, false) {
+		t.Errorf("Expected withheld point to be rejected")
+	}
+	if !filter.Accepts(2, 1, 1, false, true) {
+		t.Errorf("Expected synthetic point to be accepted")
+	}
+
+	filter.ExcludeSynthetic = true
+	if filter.Accepts(2, 1, 1, false, true) {
+		t.Errorf("Expected synthetic point to be rejected")
+	}
+}
+
+func TestParseReturnFilter(t *testing.T) {
+	returns, err := tiler.ParseReturnFilter(" Last")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if returns != tiler.LastReturns {
+		t.Errorf("Expected returns %s, got %s", tiler.LastReturns, returns)
+	}
+
+	if _, err := tiler.ParseReturnFilter("second"); err == nil {
+		t.Errorf("Expected an error parsing %q", "second")
+	}
+}
+
+func TestParseClassList(t *testing.T) {
+	classes, err := tiler.ParseClassList("7, 18")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(classes) != 2 || !classes[7] || !classes[18] {
+		t.Errorf("Expected classes map[7:true 18:true], got %v", classes)
+	}
+
+	for _, value := range []string{"256", "a", "7:1"} {
+		if _, err := tiler.ParseClassList(value); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scorers"
+	"testing"
+)
+
+func TestCenterScorerPrefersClosestPoint(t *testing.T) {
+	scorer := scorers.NewCenterScorer()
+	point := data.NewPoint(0, 0, 0, 0, 0, 0, 0, 0)
+
+	if scorer.Score(point, 1) <= scorer.Score(point, 2) {
+		t.Errorf("Expected closer points to have a higher score")
+	}
+}
+
+func TestParsePointScorerAttributes(t *testing.T) {
+	point := data.NewPoint(0, 0, 0, 10, 20, 30, 40, 0)
+	expected := map[string]float64{
+		"intensity": 40,
+		"Red":       10,
+		"green":     20,
+		"blue":      30,
+		"luminance": 0.2126*10 + 0.7152*20 + 0.0722*30,
+	}
+
+	for name, value := range expected {
+		scorer, err := scorers.ParsePointScorer(name, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %s", name, err)
+		}
+		if score := scorer.Score(point, 1); score != value {
+			t.Errorf("Expected %s score %f, got %f", name, value, score)
+		}
+	}
+}
+
+func TestParsePointScorerUnknown(t *testing.T) {
+	if _, err := scorers.ParsePointScorer("slope", nil); err == nil {
+		t.Errorf("Expected an error parsing an unknown scorer")
+	}
+}
+
+func TestParsePointScorerComputedAttributes(t *testing.T) {
+	point := data.NewPoint(0, 0, 0, 0, 0, 0, 0, 0)
+	point.Attributes = []float32{5, 0.25}
+	attributeNames := []string{"Score", "Curvature"}
+	expected := map[string]float64{
+		"attribute:score":   5,
+		"attribute: Score ": 5,
+		"curvature":         0.25,
+	}
+
+	for name, value := range expected {
+		scorer, err := scorers.ParsePointScorer(name, attributeNames)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %s", name, err)
+		}
+		if score := scorer.Score(point, 1); score != value {
+			t.Errorf("Expected %s score %f, got %f", name, value, score)
+		}
+	}
+
+	if scorer, _ := scorers.ParsePointScorer("attribute:Curvature", attributeNames); scorer.Score(data.NewPoint(0, 0, 0, 0, 0, 0, 0, 0), 1) != 0 {
+		t.Errorf("Expected zero score for points lacking the attribute")
+	}
+}
+
+func TestParsePointScorerMissingAttribute(t *testing.T) {
+	if _, err := scorers.ParsePointScorer("attribute:Score", []string{"HeightAboveGround"}); err == nil {
+		t.Errorf("Expected an error scoring by an attribute that is not computed")
+	}
+	if _, err := scorers.ParsePointScorer("curvature", nil); err == nil {
+		t.Errorf("Expected an error scoring by curvature if it is not computed")
+	}
+}
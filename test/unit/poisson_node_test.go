@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/poisson_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"testing"
+)
+
+func TestPoissonNodeEnforcesMinSpacing(t *testing.T) {
+	node := poisson_tree.NewPoissonNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		2.0,
+		&tiler.TilerOptions{CellMinSize: 0.1},
+		true,
+	)
+
+	// a line of points spaced by 0.5 meters
+	for i := 0; i < 20; i++ {
+		node.AddDataPoint(data.NewPoint(float64(i)*0.5, 1, 1, 0, 0, 0, 0, 0))
+	}
+	node.(*poisson_tree.PoissonNode).BuildPoints()
+
+	points := node.GetPoints()
+	if len(points) != 5 {
+		t.Errorf("Expected %d points stored in the root, got %d", 5, len(points))
+	}
+	for i := 0; i < len(points); i++ {
+		for j := i + 1; j < len(points); j++ {
+			if math.Abs(points[i].X-points[j].X) < 2.0 {
+				t.Errorf("Points %v and %v are closer than the spacing", points[i], points[j])
+			}
+		}
+	}
+
+	if node.TotalNumberOfPoints() != 20 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 20, node.TotalNumberOfPoints())
+	}
+	if node.IsLeaf() {
+		t.Errorf("Expected node not to be a leaf")
+	}
+}
+
+func TestPoissonNodeHalvesSpacingInChildren(t *testing.T) {
+	node := poisson_tree.NewPoissonNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		2.0,
+		&tiler.TilerOptions{CellMinSize: 0.1},
+		true,
+	)
+
+	node.AddDataPoint(data.NewPoint(1, 1, 1, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(2.5, 1, 1, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(2, 1, 1, 0, 0, 0, 0, 0))
+
+	child := node.GetChildren()[0]
+	if len(child.GetPoints()) != 1 {
+		t.Fatalf("Expected %d point in the child, got %d", 1, len(child.GetPoints()))
+	}
+
+	// the third point is closer than the child spacing to the second one
+	if len(child.GetChildren()[0].GetPoints()) != 1 {
+		t.Errorf("Expected %d point in the grandchild, got %d", 1, len(child.GetChildren()[0].GetPoints()))
+	}
+}
+
+func TestPoissonNodeBelowMinSizeStoresAllPoints(t *testing.T) {
+	node := poisson_tree.NewPoissonNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		0.5,
+		&tiler.TilerOptions{CellMinSize: 1},
+		true,
+	)
+
+	for i := 0; i < 10; i++ {
+		node.AddDataPoint(data.NewPoint(1, 1, 1, 0, 0, 0, 0, 0))
+	}
+
+	if len(node.GetPoints()) != 10 || !node.IsLeaf() {
+		t.Errorf("Expected the node to be a leaf storing %d points, got %d", 10, len(node.GetPoints()))
+	}
+}
@@ -0,0 +1,223 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"testing"
+)
+
+func TestEstimateResourcesGrowsWithNumberOfPoints(t *testing.T) {
+	opts := &tiler.TilerOptions{Algorithm: tiler.Grid, RefineMode: tiler.RefineModeAdd}
+	small := preflight.EstimateResources(preflight.CloudInfo{NumberOfPoints: 1000}, opts)
+	large := preflight.EstimateResources(preflight.CloudInfo{NumberOfPoints: 1000000}, opts)
+
+	if large.PeakMemory <= small.PeakMemory {
+		t.Errorf("Expected peak memory to grow with the number of points, got %d and %d", small.PeakMemory, large.PeakMemory)
+	}
+	if large.OutputSize != 17000000 {
+		t.Errorf("Expected output size %d, got %d", 17000000, large.OutputSize)
+	}
+}
+
+func TestEstimateResourcesReplaceModeIncreasesOutputSize(t *testing.T) {
+	info := preflight.CloudInfo{NumberOfPoints: 1000000}
+	add := preflight.EstimateResources(info, &tiler.TilerOptions{Algorithm: tiler.Grid, RefineMode: tiler.RefineModeAdd, CellMaxSize: 5, CellMinSize: 0.15})
+	replace := preflight.EstimateResources(info, &tiler.TilerOptions{Algorithm: tiler.Grid, RefineMode: tiler.RefineModeReplace, CellMaxSize: 5, CellMinSize: 0.15})
+
+	if replace.OutputSize <= add.OutputSize {
+		t.Errorf("Expected REPLACE output size to be greater than ADD one, got %d and %d", replace.OutputSize, add.OutputSize)
+	}
+}
+
+func TestEstimateResourcesCountsTheMemoryOfEnabledFeatures(t *testing.T) {
+	info := preflight.CloudInfo{NumberOfPoints: 1000000}
+	base := preflight.EstimateResources(info, &tiler.TilerOptions{Algorithm: tiler.Grid})
+	program, err := expression.Parse("HeightBand = floor(Z / 10)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ortho, err := raster.NewRaster(1000, 1000, make([]uint8, 3000000), raster.Transform{0, 1, 0, 0, 0, -1}, 32633)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	features := map[string]*tiler.TilerOptions{
+		"bounds-percentile":   {Algorithm: tiler.Grid, BoundsPercentile: 0.1},
+		"noise-radius":        {Algorithm: tiler.Grid, NoiseRadius: 1, NoiseMinNeighbours: 3},
+		"noise-knn":           {Algorithm: tiler.Grid, NoiseKNearest: 8, NoiseMaxMeanDistance: 1},
+		"curvature":           {Algorithm: tiler.Grid, Curvature: true, CurvatureRadius: 1},
+		"expressions":         {Algorithm: tiler.Grid, Expressions: program},
+		"colorize":            {Algorithm: tiler.Grid, Colorization: ortho},
+		"height-above-ground": {Algorithm: tiler.Grid, HeightAboveGround: true, GroundCellSize: 1},
+	}
+
+	for name, opts := range features {
+		if estimate := preflight.EstimateResources(info, opts); estimate.PeakMemory <= base.PeakMemory {
+			t.Errorf("Expected %s to increase the peak memory, got %d and %d", name, base.PeakMemory, estimate.PeakMemory)
+		}
+	}
+}
+
+func TestPlanJobsWithoutBudgetReturnsSingleJob(t *testing.T) {
+	jobs, _, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 100000000, Bounds: geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)},
+		&tiler.TilerOptions{Algorithm: tiler.Grid},
+	)
+
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if len(jobs) != 1 || !jobs[0].IsWholeCloud() {
+		t.Errorf("Expected a single job covering the whole cloud, got %d jobs", len(jobs))
+	}
+}
+
+func TestPlanJobsExceedingBudgetWithoutAutoSplitReturnsError(t *testing.T) {
+	_, estimate, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 100000000, Bounds: geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)},
+		&tiler.TilerOptions{Algorithm: tiler.Grid, MaxMemory: 1024},
+	)
+
+	if err == nil {
+		t.Errorf("Expected an error as the memory budget is exceeded")
+	}
+	if estimate == nil {
+		t.Errorf("Expected the estimate to be returned together with the error")
+	}
+}
+
+// returns a function counting the given number of points laid on a regular lattice over the given bounds, followed
+// by the given number of points in the given dense rectangle
+func getTestPointCounter(bounds *geometry.BoundingBox, numberOfPoints int, dense *geometry.BoundingBox, numberOfDensePoints int) func() (*preflight.PointCounts, error) {
+	return func() (*preflight.PointCounts, error) {
+		counts := preflight.NewPointCounts(bounds)
+		for _, area := range []struct {
+			box            *geometry.BoundingBox
+			numberOfPoints int
+		}{{bounds, numberOfPoints}, {dense, numberOfDensePoints}} {
+			side := int(math.Sqrt(float64(area.numberOfPoints)))
+			for i := 0; i < area.numberOfPoints; i++ {
+				x := area.box.Xmin + (area.box.Xmax-area.box.Xmin)*(float64(i%side)+0.5)/float64(side)
+				y := area.box.Ymin + (area.box.Ymax-area.box.Ymin)*(float64(i/side%side)+0.5)/float64(side)
+				counts.Add(x, y)
+			}
+		}
+		return counts, nil
+	}
+}
+
+func TestPlanJobsExceedingBudgetWithAutoSplitPartitionsTheCloud(t *testing.T) {
+	bounds := geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)
+	jobs, _, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 10000000, Bounds: bounds, CountPoints: getTestPointCounter(bounds, 10000000, bounds, 0)},
+		&tiler.TilerOptions{Algorithm: tiler.Grid, MaxMemory: 1024, AutoSplit: true},
+	)
+
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if len(jobs) < 4 {
+		t.Errorf("Expected at least %d jobs, got %d", 4, len(jobs))
+	}
+
+	// every point, including the ones on the cloud border and on the job borders, must belong to exactly one job
+	testPoints := [][]float64{{0, 0}, {100, 100}, {50, 50}, {0, 100}, {99.99, 0.01}, {-1, 101}}
+	for _, point := range testPoints {
+		count := 0
+		for _, job := range jobs {
+			if job.Contains(point[0], point[1], 0) {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("Expected point %v to belong to exactly one job, got %d", point, count)
+		}
+	}
+}
+
+func TestPlanJobsWithTooSmallBudgetReturnsError(t *testing.T) {
+	_, _, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 10000000, Bounds: geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)},
+		&tiler.TilerOptions{Algorithm: tiler.Grid, MaxMemory: 64, AutoSplit: true},
+	)
+
+	if err == nil {
+		t.Errorf("Expected an error as the memory budget is too small")
+	}
+}
+
+func TestPlanJobsSplitsDenseAreasInMoreJobs(t *testing.T) {
+	bounds := geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)
+	dense := geometry.NewBoundingBox(0, 10, 0, 10, 0, 10)
+	opts := &tiler.TilerOptions{Algorithm: tiler.Grid, MaxMemory: 1024, AutoSplit: true}
+	jobs, _, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 10000000, Bounds: bounds, CountPoints: getTestPointCounter(bounds, 1000000, dense, 9000000)},
+		opts,
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	pointsBudget := int64(1024<<20-128<<20) / 320
+	var total int64
+	denseJobs := 0
+	for _, job := range jobs {
+		total += job.NumberOfPoints
+		if job.NumberOfPoints > pointsBudget {
+			t.Errorf("Expected at most %d points in job %s, got %d", pointsBudget, job.Name, job.NumberOfPoints)
+		}
+		if job.Extent.Xmin < dense.Xmax && job.Extent.Ymin < dense.Ymax {
+			denseJobs++
+		}
+	}
+	if total != 10000000 {
+		t.Errorf("Expected %d points in the jobs, got %d", 10000000, total)
+	}
+	// the dense area holds more than three times the points that fit in the budget
+	if denseJobs < 4 {
+		t.Errorf("Expected the dense area to be split in at least %d jobs, got %d", 4, denseJobs)
+	}
+}
+
+func TestJobLocatorFindsTheJobContainingEachPoint(t *testing.T) {
+	bounds := geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)
+	jobs, _, err := preflight.PlanJobs(
+		preflight.CloudInfo{NumberOfPoints: 10000000, Bounds: bounds, CountPoints: getTestPointCounter(bounds, 1000000, geometry.NewBoundingBox(20, 30, 60, 90, 0, 10), 9000000)},
+		&tiler.TilerOptions{Algorithm: tiler.Grid, MaxMemory: 1024, AutoSplit: true},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	locator := preflight.NewJobLocator(jobs)
+	for _, point := range [][]float64{{0, 0}, {100, 100}, {-5, 105}, {25, 75}, {jobs[0].Extent.Xmax, jobs[0].Extent.Ymin}, {50, 50.5}} {
+		index := locator.Locate(point[0], point[1], 0)
+		if index < 0 || !jobs[index].Contains(point[0], point[1], 0) {
+			t.Errorf("Expected point %v to be located in the job containing it, got %d", point, index)
+		}
+	}
+}
+
+func TestPlanJobsWithNeighbourhoodStagesDoesNotSplit(t *testing.T) {
+	bounds := geometry.NewBoundingBox(0, 100, 0, 100, 0, 10)
+	info := preflight.CloudInfo{NumberOfPoints: 10000000, Bounds: bounds, CountPoints: getTestPointCounter(bounds, 10000000, bounds, 0)}
+	stages := map[string]*tiler.TilerOptions{
+		"noise-radius":        {Algorithm: tiler.Grid, MaxMemory: 8192, AutoSplit: true, NoiseRadius: 1, NoiseMinNeighbours: 3},
+		"noise-knn":           {Algorithm: tiler.Grid, MaxMemory: 8192, AutoSplit: true, NoiseKNearest: 8, NoiseMaxMeanDistance: 1},
+		"curvature":           {Algorithm: tiler.Grid, MaxMemory: 8192, AutoSplit: true, Curvature: true, CurvatureRadius: 1},
+		"height-above-ground": {Algorithm: tiler.Grid, MaxMemory: 8192, AutoSplit: true, HeightAboveGround: true, GroundCellSize: 1},
+	}
+
+	for name, opts := range stages {
+		if _, _, err := preflight.PlanJobs(info, opts); err != nil {
+			t.Errorf("Expected %s to be processed in a single job within the budget, got %v", name, err)
+		}
+		opts.MaxMemory = 1024
+		if jobs, _, err := preflight.PlanJobs(info, opts); err == nil {
+			t.Errorf("Expected an error splitting the input with %s, got %d jobs", name, len(jobs))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestQuadNodeSubdividesInXYOnly(t *testing.T) {
+	node := grid_tree.NewQuadNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 100, 0, 100, 0, 100),
+		100.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
+		true,
+	)
+
+	// points in the same cell, the last ones are pushed out to the children
+	node.AddDataPoint(data.NewPoint(50, 50, 50, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(90, 10, 90, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(90, 10, 10, 0, 0, 0, 0, 0))
+	node.(*grid_tree.QuadNode).BuildPoints()
+
+	children := node.GetChildren()
+	for i := 4; i < 8; i++ {
+		if children[i] != nil {
+			t.Errorf("Expected no child at index %d", i)
+		}
+	}
+
+	child := children[1]
+	if child.TotalNumberOfPoints() != 2 {
+		t.Fatalf("Expected %d points in the south east child, got %d", 2, child.TotalNumberOfPoints())
+	}
+
+	bbox := child.GetBoundingBox()
+	if bbox.Xmin != 50 || bbox.Xmax != 100 || bbox.Ymin != 0 || bbox.Ymax != 50 {
+		t.Errorf("Unexpected child bounding box %v", bbox.GetAsArray())
+	}
+	if bbox.Zmin != 10 || bbox.Zmax != 90 {
+		t.Errorf("Expected child vertical extent [%f, %f], got [%f, %f]", 10.0, 90.0, bbox.Zmin, bbox.Zmax)
+	}
+	if children[0].GetBoundingBox().Zmin != 0 || children[0].GetBoundingBox().Zmax != 100 {
+		t.Errorf("Expected empty children to keep the vertical extent of their parent")
+	}
+}
+
+func TestQuadNodeShrinksRootVerticalExtent(t *testing.T) {
+	node := grid_tree.NewQuadNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 100, 0, 100, -50, 500),
+		10.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
+		true,
+	)
+
+	for i := 0; i < 10; i++ {
+		node.AddDataPoint(data.NewPoint(float64(i*10), float64(i*10), float64(20+i), 0, 0, 0, 0, 0))
+	}
+	node.(*grid_tree.QuadNode).BuildPoints()
+
+	bbox := node.GetBoundingBox()
+	if bbox.Zmin != 20 || bbox.Zmax != 29 {
+		t.Errorf("Expected root vertical extent [%f, %f], got [%f, %f]", 20.0, 29.0, bbox.Zmin, bbox.Zmax)
+	}
+	if node.TotalNumberOfPoints() != 10 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 10, node.TotalNumberOfPoints())
+	}
+}
+
+func TestQuadNodeCellsSpanTheWholeHeight(t *testing.T) {
+	node := grid_tree.NewQuadNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 100, 0, 100, 0, 50),
+		10.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
+		true,
+	)
+
+	// points in the same column far apart vertically, only the one closest to the column axis is kept
+	node.AddDataPoint(data.NewPoint(5, 5, 1, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(6, 6, 45, 0, 0, 0, 0, 0))
+	node.AddDataPoint(data.NewPoint(15, 5, 25, 0, 0, 0, 0, 0))
+	node.(*grid_tree.QuadNode).BuildPoints()
+
+	if node.NumberOfPoints() != 2 {
+		t.Fatalf("Expected %d points stored in the node, got %d", 2, node.NumberOfPoints())
+	}
+	for _, point := range node.GetPoints() {
+		if point.Z == 45 {
+			t.Errorf("Expected the point farthest from the column axis to be pushed out")
+		}
+	}
+	if node.GetChildren()[0].TotalNumberOfPoints() != 1 {
+		t.Errorf("Expected the pushed out point in the south west child")
+	}
+}
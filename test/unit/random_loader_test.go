@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"testing"
+)
+
+func TestRandomLoaderSameSeedGivesSameOrder(t *testing.T) {
+	points := make([]*data.Point, 0)
+	for i := 0; i < 100; i++ {
+		points = append(points, data.NewPoint(float64(i), 0, 0, 0, 0, 0, 0, 0))
+	}
+
+	first := shuffleWithRandomLoader(point_loader.NewRandomLoader(42), points)
+	second := shuffleWithRandomLoader(point_loader.NewRandomLoader(42), points)
+
+	if len(first) != len(points) || len(second) != len(points) {
+		t.Fatalf("Expected %d points to be returned, got %d and %d", len(points), len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same order with the same seed, points differ at position %d", i)
+		}
+	}
+}
+
+func TestRandomBoxLoaderSameSeedGivesSameOrder(t *testing.T) {
+	points := make([]*data.Point, 0)
+	for i := 0; i < 100; i++ {
+		points = append(points, data.NewPoint(float64(i%10)*0.001, 0, float64(i/10), 0, 0, 0, 0, 0))
+	}
+
+	first := shuffleWithRandomLoader(point_loader.NewRandomBoxLoader(42), points)
+	second := shuffleWithRandomLoader(point_loader.NewRandomBoxLoader(42), points)
+
+	if len(first) != len(second) {
+		t.Fatalf("Expected the same number of points, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected the same order with the same seed, points differ at position %d", i)
+		}
+	}
+}
+
+func shuffleWithRandomLoader(loader point_loader.Loader, points []*data.Point) []*data.Point {
+	for _, point := range points {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+
+	out := make([]*data.Point, 0)
+	for {
+		point, hasNext := loader.GetNext()
+		if point != nil {
+			out = append(out, point)
+		}
+		if !hasNext {
+			break
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,247 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+// tiff tag written by writeTestTiff, with either short, double or ASCII values
+type testTiffTag struct {
+	tag     uint16
+	shorts  []uint16
+	doubles []float64
+	ascii   string
+}
+
+// writes a little endian TIFF storing the given data in a single strip
+func writeTestTiff(t *testing.T, filePath string, data []byte, tags []testTiffTag) {
+	tags = append(tags, testTiffTag{tag: 273, shorts: []uint16{8}}, testTiffTag{tag: 279, shorts: []uint16{uint16(len(data))}})
+	for i := 1; i < len(tags); i++ {
+		for j := i; j > 0 && tags[j].tag < tags[j-1].tag; j-- {
+			tags[j], tags[j-1] = tags[j-1], tags[j]
+		}
+	}
+
+	content := append([]byte{'I', 'I', 42, 0, 0, 0, 0, 0}, data...)
+	if len(content)%2 == 1 {
+		content = append(content, 0)
+	}
+	binary.LittleEndian.PutUint32(content[4:], uint32(len(content)))
+	extraOffset := len(content) + 2 + len(tags)*12 + 4
+	var extra []byte
+	content = append(content, 0, 0)
+	binary.LittleEndian.PutUint16(content[len(content)-2:], uint16(len(tags)))
+	for _, tag := range tags {
+		entry := make([]byte, 12)
+		binary.LittleEndian.PutUint16(entry, tag.tag)
+		var values []byte
+		if tag.ascii != "" {
+			values = append([]byte(tag.ascii), 0)
+			binary.LittleEndian.PutUint16(entry[2:], 2)
+			binary.LittleEndian.PutUint32(entry[4:], uint32(len(values)))
+		} else if tag.doubles != nil {
+			binary.LittleEndian.PutUint16(entry[2:], 12)
+			binary.LittleEndian.PutUint32(entry[4:], uint32(len(tag.doubles)))
+			for _, value := range tag.doubles {
+				values = append(values, make([]byte, 8)...)
+				binary.LittleEndian.PutUint64(values[len(values)-8:], math.Float64bits(value))
+			}
+		} else {
+			binary.LittleEndian.PutUint16(entry[2:], 3)
+			binary.LittleEndian.PutUint32(entry[4:], uint32(len(tag.shorts)))
+			for _, value := range tag.shorts {
+				values = append(values, 0, 0)
+				binary.LittleEndian.PutUint16(values[len(values)-2:], value)
+			}
+		}
+		if len(values) <= 4 {
+			copy(entry[8:], values)
+		} else {
+			binary.LittleEndian.PutUint32(entry[8:], uint32(extraOffset+len(extra)))
+			extra = append(extra, values...)
+		}
+		content = append(content, entry...)
+	}
+	content = append(content, 0, 0, 0, 0)
+	content = append(content, extra...)
+
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tags of a RGB GeoTIFF in EPSG:32633 with 10 meters pixels and top left corner in 500000,4600000
+func getTestGeoTiffTags(width uint16, height uint16, compression uint16) []testTiffTag {
+	return []testTiffTag{
+		{tag: 256, shorts: []uint16{width}},
+		{tag: 257, shorts: []uint16{height}},
+		{tag: 258, shorts: []uint16{8, 8, 8}},
+		{tag: 259, shorts: []uint16{compression}},
+		{tag: 262, shorts: []uint16{2}},
+		{tag: 277, shorts: []uint16{3}},
+		{tag: 278, shorts: []uint16{height}},
+		{tag: 33550, doubles: []float64{10, 10, 0}},
+		{tag: 33922, doubles: []float64{0, 0, 0, 500000, 4600000, 0}},
+		{tag: 34735, shorts: []uint16{1, 1, 0, 2, 1025, 0, 1, 1, 3072, 0, 1, 32633}},
+	}
+}
+
+func TestRasterOpenGeoTiffAndSample(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "raster*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "ortho.tif")
+	// 2x2 pixels: red, green, blue and white
+	writeTestTiff(t, filePath, []byte{200, 0, 0, 0, 200, 0, 0, 0, 200, 200, 200, 200}, getTestGeoTiffTags(2, 2, 1))
+
+	ortho, err := raster.Open(filePath, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ortho.Width != 2 || ortho.Height != 2 || ortho.Srid != 32633 {
+		t.Errorf("Expected 2x2 raster in EPSG:32633, got %dx%d in EPSG:%d", ortho.Width, ortho.Height, ortho.Srid)
+	}
+
+	expectations := []struct {
+		x, y     float64
+		color    [3]uint8
+		inRaster bool
+	}{
+		{500005, 4599995, [3]uint8{200, 0, 0}, true},
+		{500015, 4599985, [3]uint8{200, 200, 200}, true},
+		{500010, 4599995, [3]uint8{100, 100, 0}, true},
+		{500010, 4599990, [3]uint8{100, 100, 100}, true},
+		{500001, 4599999, [3]uint8{200, 0, 0}, true},
+		{499999, 4599995, [3]uint8{}, false},
+		{500005, 4599979, [3]uint8{}, false},
+	}
+	for _, expectation := range expectations {
+		r, g, b, ok := ortho.Sample(expectation.x, expectation.y)
+		if ok != expectation.inRaster || (ok && [3]uint8{r, g, b} != expectation.color) {
+			t.Errorf("Expected sample at %f,%f = %v %t, got %v %t", expectation.x, expectation.y, expectation.color, expectation.inRaster, [3]uint8{r, g, b}, ok)
+		}
+	}
+}
+
+func TestRasterOpenLZWGeoTiff(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "raster*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "ortho.tif")
+	// 2x1 pixels with color 10,20,30 compressed as the codes 256,10,20,30,258,30,257
+	writeTestTiff(t, filePath, []byte{128, 2, 130, 129, 232, 16, 122, 2}, getTestGeoTiffTags(2, 1, 5))
+
+	ortho, err := raster.Open(filePath, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, x := range []float64{500005, 500015} {
+		if r, g, b, _ := ortho.Sample(x, 4599995); r != 10 || g != 20 || b != 30 {
+			t.Errorf("Expected color 10,20,30 at %f, got %d,%d,%d", x, r, g, b)
+		}
+	}
+}
+
+func TestRasterOpenTiffWithCountsExceedingTheFileReturnsError(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "raster*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "ortho.tif")
+	writeTestTiff(t, filePath, []byte{10, 20, 30}, getTestGeoTiffTags(1, 1, 1))
+	valid, _ := ioutil.ReadFile(filePath)
+	directoryOffset := int(binary.LittleEndian.Uint32(valid[4:]))
+	numberOfEntries := int(binary.LittleEndian.Uint16(valid[directoryOffset:]))
+
+	corruptions := map[string]func(content []byte){
+		"entries": func(content []byte) {
+			binary.LittleEndian.PutUint16(content[directoryOffset:], 0xFFFF)
+		},
+		"values": func(content []byte) {
+			for i := 0; i < numberOfEntries; i++ {
+				entry := content[directoryOffset+2+i*12:]
+				if binary.LittleEndian.Uint16(entry) == 33922 {
+					binary.LittleEndian.PutUint32(entry[4:], 0x7FFFFFFF)
+				}
+			}
+		},
+		"strip": func(content []byte) {
+			for i := 0; i < numberOfEntries; i++ {
+				entry := content[directoryOffset+2+i*12:]
+				if binary.LittleEndian.Uint16(entry) == 279 {
+					binary.LittleEndian.PutUint16(entry[8:], 0xFFFF)
+				}
+			}
+		},
+	}
+	for name, corrupt := range corruptions {
+		content := append([]byte{}, valid...)
+		corrupt(content)
+		if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := raster.Open(filePath, 0); err == nil {
+			t.Errorf("Expected an error opening a TIFF with corrupted %s", name)
+		}
+	}
+}
+
+func TestRasterOpenImageWithWorldFile(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "raster*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "ortho.png")
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 50), G: uint8(y * 100), B: 7, A: 255})
+		}
+	}
+	file, _ := os.Create(filePath)
+	_ = png.Encode(file, img)
+	_ = file.Close()
+
+	if _, err := raster.Open(filePath, 4326); err == nil {
+		t.Errorf("Expected error opening an image without world file")
+	}
+
+	// 0.5 degrees pixels, center of the top left pixel in 10.25,45.75
+	_ = ioutil.WriteFile(path.Join(tempdir, "ortho.pgw"), []byte("0.5\n0\n0\n-0.5\n10.25\n45.75\n"), 0666)
+	if _, err := raster.Open(filePath, 0); err == nil {
+		t.Errorf("Expected error opening an image with world file and no srid")
+	}
+	ortho, err := raster.Open(filePath, 4326)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r, g, b, ok := ortho.Sample(11.25, 45.25); !ok || r != 100 || g != 100 || b != 7 {
+		t.Errorf("Expected color 100,100,7, got %d,%d,%d %t", r, g, b, ok)
+	}
+}
+
+func TestColorizerReprojectsPoints(t *testing.T) {
+	pixels := make([]uint8, 100*100*3)
+	for i := 0; i < 100*100; i++ {
+		pixels[i*3] = uint8(i % 100)
+		pixels[i*3+1] = uint8(i / 100)
+	}
+	center, _ := coordinateConverter.ConvertCoordinateSrid(4326, 32633, geometry.Coordinate{X: 14.905, Y: 41.305})
+	// 1 meter pixels, the center of the pixel 30,60 is in the given point
+	transform := raster.Transform{center.X - 30.5, 1, 0, center.Y + 60.5, 0, -1}
+	ortho, err := raster.NewRaster(100, 100, pixels, transform, 32633)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	colorizer := raster.NewColorizer(ortho, coordinateConverter, 4326)
+	if r, g, _, ok := colorizer.Colorize(14.905, 41.305, 0); !ok || r != 30 || g != 60 {
+		t.Errorf("Expected color 30,60, got %d,%d %t", r, g, ok)
+	}
+	if _, _, _, ok := colorizer.Colorize(14.8, 41.305, 0); ok {
+		t.Errorf("Expected point outside the raster not to be colored")
+	}
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRobustBoundsLoaderIsNotUsedIfDisabled(t *testing.T) {
+	sequentialLoader := point_loader.NewSequentialLoader()
+	loader := point_loader.NewRobustBoundsLoader(sequentialLoader, 0, 0)
+
+	if loader != sequentialLoader {
+		t.Errorf("Expected the wrapped loader to be returned unchanged")
+	}
+}
+
+func TestRobustBoundsLoaderPercentileBoundsKeepOutliers(t *testing.T) {
+	loader := point_loader.NewRobustBoundsLoader(point_loader.NewSequentialLoader(), 1, 0)
+	for i := 0; i < 100; i++ {
+		loader.AddPoint(data.NewPoint(float64(i), float64(i), float64(i), 0, 0, 0, 0, 0))
+	}
+	loader.AddPoint(data.NewPoint(100, 100, 3000, 0, 0, 0, 0, 0))
+	loader.InitializeLoader()
+
+	bounds := loader.GetBounds()
+	expected := []float64{1, 99, 1, 99, 1, 99}
+	if !reflect.DeepEqual(bounds, expected) {
+		t.Errorf("Expected bounds %v, got %v", expected, bounds)
+	}
+
+	var returned int
+	for {
+		point, hasNext := loader.GetNext()
+		if point != nil {
+			returned++
+		}
+		if !hasNext {
+			break
+		}
+	}
+
+	// the points outside the bounds are kept
+	if returned != 101 {
+		t.Errorf("Expected %d points, got %d", 101, returned)
+	}
+	if loader.GetNumberOfRejectedPoints() != 0 {
+		t.Errorf("Expected %d rejected points, got %d", 0, loader.GetNumberOfRejectedPoints())
+	}
+}
+
+func TestRobustBoundsLoaderPercentileBoundsWithConcurrentReaders(t *testing.T) {
+	loader := point_loader.NewRobustBoundsLoader(point_loader.NewSequentialLoader(), 1, 0)
+	var wg sync.WaitGroup
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func(reader int) {
+			defer wg.Done()
+			for i := reader; i < 101; i += 4 {
+				loader.AddPoint(data.NewPoint(float64(i), float64(i), float64(i), 0, 0, 0, 0, 0))
+			}
+		}(reader)
+	}
+	wg.Wait()
+	loader.InitializeLoader()
+
+	bounds := loader.GetBounds()
+	expected := []float64{1, 99, 1, 99, 1, 99}
+	if !reflect.DeepEqual(bounds, expected) {
+		t.Errorf("Expected bounds %v, got %v", expected, bounds)
+	}
+}
+
+func TestRobustBoundsLoaderSigmaBoundsDiscardOutliers(t *testing.T) {
+	loader := point_loader.NewRobustBoundsLoader(point_loader.NewSequentialLoader(), 0, 3)
+	for i := 0; i < 1000; i++ {
+		loader.AddPoint(data.NewPoint(float64(i%10), float64(i%10), float64(i%10), 0, 0, 0, 0, 0))
+	}
+	loader.AddPoint(data.NewPoint(5, 5, 3000, 0, 0, 0, 0, 0))
+	loader.InitializeLoader()
+
+	bounds := loader.GetBounds()
+	if bounds[5] >= 3000 || bounds[5] < 9 {
+		t.Errorf("Expected max Z to enclose the points and exclude the outlier, got %f", bounds[5])
+	}
+
+	var returned int
+	for {
+		point, hasNext := loader.GetNext()
+		if point != nil {
+			returned++
+		}
+		if !hasNext {
+			break
+		}
+	}
+
+	if returned != 1000 {
+		t.Errorf("Expected %d points, got %d", 1000, returned)
+	}
+	if loader.GetNumberOfRejectedPoints() != 1 {
+		t.Errorf("Expected %d rejected point, got %d", 1, loader.GetNumberOfRejectedPoints())
+	}
+}
@@ -3,6 +3,7 @@ package unit
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geoid"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
 	"reflect"
@@ -23,6 +24,34 @@ func TestAlgorithmManagerReturnsGridTree(t *testing.T) {
 	}
 }
 
+func TestAlgorithmManagerReturnsVoxelTree(t *testing.T) {
+	expected := "VoxelTree"
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.Voxel,
+		},
+	)
+
+	treeType := reflect.ValueOf(algorithmManager.GetTreeAlgorithm()).Elem().Type().Name()
+	if treeType != expected {
+		t.Errorf("Wrong tree algorithm returned, %s expected, but %s was returned", expected, treeType)
+	}
+}
+
+func TestAlgorithmManagerReturnsPoissonTree(t *testing.T) {
+	expected := "PoissonTree"
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.Poisson,
+		},
+	)
+
+	treeType := reflect.ValueOf(algorithmManager.GetTreeAlgorithm()).Elem().Type().Name()
+	if treeType != expected {
+		t.Errorf("Wrong tree algorithm returned, %s expected, but %s was returned", expected, treeType)
+	}
+}
+
 func TestAlgorithmManagerReturnsRandomTree(t *testing.T) {
 	expectedTree := "RandomTree"
 	expectedLoader := "RandomLoader"
@@ -157,3 +186,58 @@ func TestAlgorithmManagerReturnsGeoidElevationCorrector(t *testing.T) {
 	}
 
 }
+
+func TestAlgorithmManagerReturnsGridElevationCorrector(t *testing.T) {
+	expectedNestedCorrector := "GridElevationCorrector"
+	grid, err := geoid.NewGrid(10, 40, 1, 1, 2, 2, []float32{40, 42, 44, 46})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm:              tiler.Grid,
+			EnableGeoidZCorrection: true,
+			GeoidGrid:              grid,
+		},
+	)
+
+	elevationCorrectionType := reflect.ValueOf(algorithmManager.GetElevationCorrectionAlgorithm()).Elem()
+	correctors := elevationCorrectionType.FieldByName("Correctors").Interface().([]converters.ElevationCorrector)
+
+	if len(correctors) != 2 {
+		t.Fatalf("Two nested correction algorithms expected but %d found", len(correctors))
+	}
+
+	nestedCorrector := reflect.ValueOf(correctors[1]).Elem().Type().Name()
+	if nestedCorrector != expectedNestedCorrector {
+		t.Fatalf("Wrong second elevation corrector algorithm returned, %s expected, but %s was returned", expectedNestedCorrector, nestedCorrector)
+	}
+}
+
+func TestAlgorithmManagerReturnsQuadTree(t *testing.T) {
+	expected := "QuadTree"
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.Quadtree,
+		},
+	)
+
+	treeType := reflect.ValueOf(algorithmManager.GetTreeAlgorithm()).Elem().Type().Name()
+	if treeType != expected {
+		t.Errorf("Wrong tree algorithm returned, %s expected, but %s was returned", expected, treeType)
+	}
+}
+
+func TestAlgorithmManagerReturnsKdTree(t *testing.T) {
+	expected := "KdTree"
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.KdTree,
+		},
+	)
+
+	treeType := reflect.ValueOf(algorithmManager.GetTreeAlgorithm()).Elem().Type().Name()
+	if treeType != expected {
+		t.Errorf("Wrong tree algorithm returned, %s expected, but %s was returned", expected, treeType)
+	}
+}
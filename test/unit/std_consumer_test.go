@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/io"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
@@ -14,6 +15,7 @@ import (
 	"math"
 	"os"
 	"path"
+	"reflect"
 	"sync"
 	"testing"
 )
@@ -1234,3 +1236,160 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 		t.Errorf("Expected classification: %d, got: %d", 5, classification)
 	}
 }
+
+func TestGetNodeRefineModeAdditiveNodeKeepsRefineMode(t *testing.T) {
+	node := &mockNode{}
+
+	if io.GetNodeRefineMode(node, tiler.RefineModeAdd) != tiler.RefineModeAdd {
+		t.Errorf("Expected refine mode %s", tiler.RefineModeAdd)
+	}
+	if io.GetNodeRefineMode(node, tiler.RefineModeReplace) != tiler.RefineModeReplace {
+		t.Errorf("Expected refine mode %s", tiler.RefineModeReplace)
+	}
+}
+
+func TestGetNodeRefineModeNonAdditiveNodeIsReplace(t *testing.T) {
+	node := &mockNode{nonAdditive: true}
+
+	if io.GetNodeRefineMode(node, tiler.RefineModeAdd) != tiler.RefineModeReplace {
+		t.Errorf("Expected refine mode %s", tiler.RefineModeReplace)
+	}
+}
+
+func TestConsumerWritesComputedAttributes(t *testing.T) {
+	program, err := expression.Parse("HeightBand = floor(Z / 10)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	point := data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)
+	point.Attributes = []float32{2.5}
+	node := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points:              []*data.Point{point},
+		depth:               1,
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:        4326,
+			Expressions: program,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	workChannel := make(chan *io.WorkUnit, 1)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	go consumer.Consume(workChannel, errorChannel, &waitGroup)
+	workChannel <- &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir}
+	close(workChannel)
+	waitGroup.Wait()
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Errorf("Unexpected error found in error channel: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	featureTableLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	featureTableBinaryLength := int(binary.LittleEndian.Uint32(content[16:20]))
+	batchTableLength := int(binary.LittleEndian.Uint32(content[20:24]))
+	batchTableBinaryLength := int(binary.LittleEndian.Uint32(content[24:28]))
+	batchTableStart := 28 + featureTableLength + featureTableBinaryLength
+
+	var batchTable map[string]struct {
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType string `json:"componentType"`
+	}
+	if err := json.Unmarshal(content[batchTableStart:batchTableStart+batchTableLength], &batchTable); err != nil {
+		t.Fatalf("Error parsing batch table: %s", err.Error())
+	}
+	heightBand, ok := batchTable["HeightBand"]
+	if !ok || heightBand.ComponentType != "FLOAT" || heightBand.ByteOffset%4 != 0 {
+		t.Fatalf("Expected HeightBand FLOAT column aligned to 4 bytes, got %+v", batchTable)
+	}
+	if batchTableBinaryLength != heightBand.ByteOffset+4 {
+		t.Errorf("Expected batch table binary length %d, got %d", heightBand.ByteOffset+4, batchTableBinaryLength)
+	}
+
+	batchTableBinary := content[batchTableStart+batchTableLength:]
+	if len(batchTableBinary) != batchTableBinaryLength {
+		t.Errorf("Expected %d bytes of batch table binary, got %d", batchTableBinaryLength, len(batchTableBinary))
+	}
+	value := math.Float32frombits(binary.LittleEndian.Uint32(batchTableBinary[heightBand.ByteOffset:]))
+	if value != 2.5 {
+		t.Errorf("Expected HeightBand 2.5, got %f", value)
+	}
+	if batchTableBinary[0] != 4 || batchTableBinary[1] != 5 {
+		t.Errorf("Expected intensity 4 and classification 5, got %d and %d", batchTableBinary[0], batchTableBinary[1])
+	}
+}
+
+func TestConsumerWritesLocalPlacement(t *testing.T) {
+	placement := geometry.NewLocalFrameWithHeading(14, 41, 100, 30)
+	node := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 20, 10, 30, -2, 2),
+		points:              []*data.Point{data.NewPoint(5, 15, 1, 1, 2, 3, 4, 5), data.NewPoint(15, 25, -1, 1, 2, 3, 4, 5)},
+		depth:               1,
+		globalChildrenCount: 2,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Placement: placement,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	workChannel := make(chan *io.WorkUnit, 1)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	go consumer.Consume(workChannel, errorChannel, &waitGroup)
+	workChannel <- &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir}
+	close(workChannel)
+	waitGroup.Wait()
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Errorf("Unexpected error found in error channel: %s", err.Error())
+	}
+
+	tilesetContent, err := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	if err != nil {
+		t.Fatalf("Error reading tileset.json: %s", err.Error())
+	}
+	var tileset io.Tileset
+	if err := json.Unmarshal(tilesetContent, &tileset); err != nil {
+		t.Fatalf("Error parsing tileset.json: %s", err.Error())
+	}
+	expectedTransform := placement.GetTransform()
+	if !reflect.DeepEqual(tileset.Root.Transform, expectedTransform[:]) {
+		t.Errorf("Expected root transform %v, got %v", expectedTransform, tileset.Root.Transform)
+	}
+	expectedBox := []float64{10, 20, 0, 10, 0, 0, 0, 10, 0, 0, 0, 2}
+	if tileset.Root.BoundingVolume.Region != nil || !reflect.DeepEqual(tileset.Root.BoundingVolume.Box, expectedBox) {
+		t.Errorf("Expected box bounding volume %v, got %+v", expectedBox, tileset.Root.BoundingVolume)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	featureTableLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	var featureTable struct {
+		RtcCenter []float64 `json:"RTC_CENTER"`
+	}
+	if err := json.Unmarshal(content[28:28+featureTableLength], &featureTable); err != nil {
+		t.Fatalf("Error parsing feature table: %s", err.Error())
+	}
+	if !reflect.DeepEqual(featureTable.RtcCenter, []float64{10, 20, 0}) {
+		t.Errorf("Expected RTC center in the local frame (10, 20, 0), got %v", featureTable.RtcCenter)
+	}
+}
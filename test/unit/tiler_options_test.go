@@ -0,0 +1,51 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"reflect"
+	"testing"
+)
+
+func TestParseClassPrioritiesWithDefaultAndExplicitPriorities(t *testing.T) {
+	priorities, err := tiler.ParseClassPriorities("14, 15:3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(priorities) != 2 || priorities[14] != 1 || priorities[15] != 3 {
+		t.Errorf("Expected priorities map[14:1 15:3], got %v", priorities)
+	}
+}
+
+func TestParseClassPrioritiesEmpty(t *testing.T) {
+	priorities, err := tiler.ParseClassPriorities("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(priorities) != 0 {
+		t.Errorf("Expected no priorities, got %v", priorities)
+	}
+}
+
+func TestParseClassPrioritiesInvalid(t *testing.T) {
+	for _, value := range []string{"256", "a", "14:x", "14:-1", "14:1:2"} {
+		if _, err := tiler.ParseClassPriorities(value); err == nil {
+			t.Errorf("Expected an error parsing %q", value)
+		}
+	}
+}
+
+func TestGetAttributeNamesListsTheAttributesInTheOrderTheyAreComputed(t *testing.T) {
+	program, err := expression.Parse("Band = floor(Z / 10)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	opts := tiler.TilerOptions{Expressions: program, HeightAboveGround: true, Curvature: true}
+
+	expected := []string{"Band", "HeightAboveGround", "Curvature"}
+	if names := opts.GetAttributeNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected attribute names %v, got %v", expected, names)
+	}
+}
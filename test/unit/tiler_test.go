@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/crop"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/pkg"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTilerWithCropExcludingAllPointsReturnsError(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "tiler*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	opts := getCropTestOptions(t, tempdir, crop.NewBoxArea(0, 0, 10, 10, math.Inf(-1), math.Inf(1), 32633))
+
+	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(opts)).RunTiler(opts)
+	if err == nil {
+		t.Errorf("Expected an error when the crop area excludes all points")
+	}
+	if _, err := os.Stat(path.Join(tempdir, "input", "tileset.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tileset to be exported")
+	}
+}
+
+func TestTilerWithCropIncludingPointsExportsTileset(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "tiler*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	opts := getCropTestOptions(t, tempdir, crop.NewBoxArea(399990, 4600000, 400010, 4600010, math.Inf(-1), math.Inf(1), 32633))
+
+	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(opts)).RunTiler(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path.Join(tempdir, "input", "tileset.json")); err != nil {
+		t.Errorf("Expected the tileset to be exported: %v", err)
+	}
+}
+
+// writes a small las file in the given folder and returns the options to tile it, in UTM zone 33N, with the given crop
+func getCropTestOptions(t *testing.T, folder string, area *crop.Area) *tiler.TilerOptions {
+	input := path.Join(folder, "input.las")
+	writeTestLas(t, input, [][3]float64{{400000, 4600000, 10}, {400001, 4600001, 11}, {400002, 4600002, 12}}, nil)
+
+	return &tiler.TilerOptions{
+		Input:       input,
+		Output:      folder,
+		Srid:        32633,
+		Silent:      true,
+		Algorithm:   tiler.Grid,
+		CellMaxSize: 5,
+		CellMinSize: 0.15,
+		RefineMode:  tiler.RefineModeAdd,
+		Crop:        area,
+	}
+}
+
+func TestTilerWithFiltersRejectingAllPointsReturnsError(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "tiler*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	opts := getCropTestOptions(t, tempdir, crop.NewBoxArea(399990, 4600000, 400010, 4600010, math.Inf(-1), math.Inf(1), 32633))
+	opts.PointFilter = &tiler.PointFilter{IncludedClasses: map[uint8]bool{2: true}}
+
+	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(opts)).RunTiler(opts)
+	if err == nil {
+		t.Errorf("Expected an error when the filters reject all points")
+	}
+	if _, err := os.Stat(path.Join(tempdir, "input", "tileset.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tileset to be exported")
+	}
+}
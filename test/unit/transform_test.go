@@ -0,0 +1,158 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/transform"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestHelmertTransformMatchesEpsgExample(t *testing.T) {
+	// WGS 72 to WGS 84 example of the EPSG guidance note 7-2, in both rotation conventions
+	transforms := []*transform.Affine{
+		transform.NewHelmert(0, 0, 4.5, 0, 0, 0.554, 0.219, transform.PositionVector),
+		transform.NewHelmert(0, 0, 4.5, 0, 0, -0.554, 0.219, transform.CoordinateFrame),
+	}
+	for _, helmert := range transforms {
+		x, y, z := helmert.Apply(3657660.66, 255768.55, 5201382.11)
+		if math.Abs(x-3657660.78) > 0.01 || math.Abs(y-255778.43) > 0.01 || math.Abs(z-5201387.75) > 0.01 {
+			t.Errorf("Expected 3657660.78,255778.43,5201387.75, got %f,%f,%f", x, y, z)
+		}
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	// 90 degrees rotation around Z, followed by a translation
+	affine, err := transform.Parse("0,-1,0,100 1,0,0,200 0,0,2,0 0,0,0,1", transform.PositionVector)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if x, y, z := affine.Apply(1, 2, 3); x != 98 || y != 201 || z != 6 {
+		t.Errorf("Expected 98,201,6, got %f,%f,%f", x, y, z)
+	}
+
+	bounds := affine.ApplyToBounds(geometry.NewBoundingBox(0, 10, 0, 20, 0, 1))
+	if bounds.Xmin != 80 || bounds.Xmax != 100 || bounds.Ymin != 200 || bounds.Ymax != 210 || bounds.Zmax != 2 {
+		t.Errorf("Unexpected transformed bounds %v", bounds)
+	}
+
+	tempdir, _ := ioutil.TempDir("", "transform*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "helmert.txt")
+	_ = ioutil.WriteFile(filePath, []byte("10 20 30\n0 0 0\n0\n"), 0666)
+	helmert, err := transform.Parse(filePath, transform.CoordinateFrame)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if x, y, z := helmert.Apply(1, 2, 3); x != 11 || y != 22 || z != 33 {
+		t.Errorf("Expected 11,22,33, got %f,%f,%f", x, y, z)
+	}
+
+	for _, value := range []string{"1,2,3", "1,0,0,0,0,1,0,0,0,0,1,0,0,0,1,1", "1,2,a,4,5,6,7"} {
+		if _, err := transform.Parse(value, transform.PositionVector); err == nil {
+			t.Errorf("Expected error parsing %q", value)
+		}
+	}
+	if _, err := transform.ParseConvention("bursa-wolf"); err == nil {
+		t.Errorf("Expected error parsing unknown convention")
+	}
+}
+
+func TestSolveSimilarityRecoversTransform(t *testing.T) {
+	// 1.0001 scale, 30 degrees rotation around Z and 5 degrees around X, translation to projected coordinates
+	cosZ, sinZ := math.Cos(math.Pi/6), math.Sin(math.Pi/6)
+	cosX, sinX := math.Cos(math.Pi/36), math.Sin(math.Pi/36)
+	scale := 1.0001
+	expected := transform.NewAffine([12]float64{
+		scale * cosZ, -scale * sinZ * cosX, scale * sinZ * sinX, 512000,
+		scale * sinZ, scale * cosZ * cosX, -scale * cosZ * sinX, 4640000,
+		0, scale * sinX, scale * cosX, 35,
+	})
+	var points []transform.ControlPoint
+	for i, local := range []geometry.Coordinate{{X: 0, Y: 0, Z: 0}, {X: 100, Y: 5, Z: 2}, {X: 40, Y: 80, Z: -3}, {X: -20, Y: 60, Z: 10}} {
+		x, y, z := expected.Apply(local.X, local.Y, local.Z)
+		points = append(points, transform.ControlPoint{Name: string(rune('A' + i)), Local: local, Target: geometry.Coordinate{X: x, Y: y, Z: z}})
+	}
+
+	solved, err := transform.SolveSimilarity(points)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, point := range points {
+		if residual := solved.GetResidual(point); residual > 1e-6 {
+			t.Errorf("Expected no residual for point %s, got %f", point.Name, residual)
+		}
+	}
+	x, y, z := solved.Apply(500, -300, 40)
+	ex, ey, ez := expected.Apply(500, -300, 40)
+	if math.Abs(x-ex) > 1e-6 || math.Abs(y-ey) > 1e-6 || math.Abs(z-ez) > 1e-6 {
+		t.Errorf("Expected %f,%f,%f, got %f,%f,%f", ex, ey, ez, x, y, z)
+	}
+
+	collinear := []transform.ControlPoint{
+		{Local: geometry.Coordinate{X: 0}, Target: geometry.Coordinate{X: 10}},
+		{Local: geometry.Coordinate{X: 1}, Target: geometry.Coordinate{X: 11}},
+		{Local: geometry.Coordinate{X: 2}, Target: geometry.Coordinate{X: 12}},
+	}
+	if _, err := transform.SolveSimilarity(collinear); err == nil {
+		t.Errorf("Expected error solving with collinear control points")
+	}
+}
+
+func TestReadControlPoints(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "transform*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "gcp.csv")
+	_ = ioutil.WriteFile(filePath, []byte("name;x;y;z;east;north;height\nGCP1;1;2;3;512001;4640002;38\n\nGCP2;4;5;6;512004;4640005;41\n"), 0666)
+
+	points, err := transform.ReadControlPoints(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != 2 || points[1].Name != "GCP2" || points[1].Local.Y != 5 || points[1].Target.Z != 41 {
+		t.Errorf("Unexpected control points %v", points)
+	}
+
+	_ = ioutil.WriteFile(filePath, []byte("1,2,3,4,5,6\n1,2,3,4,5,x\n"), 0666)
+	if _, err := transform.ReadControlPoints(filePath); err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("Expected error reading invalid coordinates in row 2, got %v", err)
+	}
+}
+
+func TestReadControlPointsSkipsOnlyNonNumericHeader(t *testing.T) {
+	tempdir, _ := ioutil.TempDir("", "transform*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	filePath := path.Join(tempdir, "gcp.csv")
+
+	// a mistyped first point must not be silently dropped as a header
+	_ = ioutil.WriteFile(filePath, []byte("GCP1,1,2,3,512001,4640002,3O\nGCP2,4,5,6,512004,4640005,41\n"), 0666)
+	if _, err := transform.ReadControlPoints(filePath); err == nil || !strings.Contains(err.Error(), "row 1") {
+		t.Errorf("Expected error reading invalid coordinates in row 1, got %v", err)
+	}
+
+	_ = ioutil.WriteFile(filePath, []byte("x,y,z,east,north,height\n1,2,3,4,5,6\n"), 0666)
+	points, err := transform.ReadControlPoints(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != 1 || points[0].Name != "2" {
+		t.Errorf("Expected a single control point named after its row, got %v", points)
+	}
+}
+
+func TestAffineStringIsParsedBack(t *testing.T) {
+	affine := transform.NewHelmert(10, -20, 30, 1.5, -2.5, 3.5, 4.2, transform.PositionVector)
+
+	parsed, err := transform.Parse(affine.String(), transform.PositionVector)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	x, y, z := affine.Apply(100, 200, 300)
+	if px, py, pz := parsed.Apply(100, 200, 300); px != x || py != y || pz != z {
+		t.Errorf("Expected %f,%f,%f from the parsed transform, got %f,%f,%f", x, y, z, px, py, pz)
+	}
+}
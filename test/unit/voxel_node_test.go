@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/voxel_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"testing"
+)
+
+func TestVoxelNodeSinglePointIsLeaf(t *testing.T) {
+	node := voxel_tree.NewVoxelNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 10, 0, 10, 0, 10),
+		5.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
+		true,
+	)
+
+	point := data.NewPoint(1, 1, 1, 2, 3, 4, 5, 6)
+	node.AddDataPoint(point)
+	node.(*voxel_tree.VoxelNode).BuildPoints()
+
+	if !node.IsLeaf() {
+		t.Errorf("Expected node to be a leaf")
+	}
+	if len(node.GetPoints()) != 1 || node.GetPoints()[0] != point {
+		t.Errorf("Expected node to store the original point")
+	}
+}
+
+func TestVoxelNodeSynthesizesCentroid(t *testing.T) {
+	node := voxel_tree.NewVoxelNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 4, 0, 4, 0, 4),
+		5.0,
+		&tiler.TilerOptions{CellMinSize: 1.0},
+		true,
+	)
+
+	// all the points fall in the same 5 meters voxel
+	node.AddDataPoint(data.NewPoint(1, 1, 1, 10, 20, 30, 100, 2))
+	node.AddDataPoint(data.NewPoint(3, 1, 1, 20, 30, 40, 200, 2))
+	node.AddDataPoint(data.NewPoint(1, 3, 1, 30, 40, 50, 0, 6))
+	node.AddDataPoint(data.NewPoint(3, 3, 3, 40, 50, 60, 100, 5))
+	node.(*voxel_tree.VoxelNode).BuildPoints()
+
+	if node.IsLeaf() {
+		t.Fatalf("Expected node not to be a leaf")
+	}
+	if node.IsAdditive() {
+		t.Errorf("Expected voxel nodes not to be additive")
+	}
+	if len(node.GetPoints()) != 1 {
+		t.Fatalf("Expected %d synthesized point, got %d", 1, len(node.GetPoints()))
+	}
+	if node.TotalNumberOfPoints() != 4 {
+		t.Errorf("Expected TotalNumberOfPoints %d, got %d", 4, node.TotalNumberOfPoints())
+	}
+
+	point := node.GetPoints()[0]
+	if math.Abs(point.X-2) > 1e-9 || math.Abs(point.Y-2) > 1e-9 || math.Abs(point.Z-1.5) > 1e-9 {
+		t.Errorf("Expected centroid (2, 2, 1.5), got (%f, %f, %f)", point.X, point.Y, point.Z)
+	}
+	if point.R != 25 || point.G != 35 || point.B != 45 || point.Intensity != 100 {
+		t.Errorf("Expected average color (25, 35, 45) and intensity 100, got (%d, %d, %d) and %d", point.R, point.G, point.B, point.Intensity)
+	}
+	if point.Classification != 2 {
+		t.Errorf("Expected majority classification %d, got %d", 2, point.Classification)
+	}
+
+	// the original points are stored in the children
+	var childrenPoints int
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			childrenPoints += len(child.GetPoints())
+		}
+	}
+	if childrenPoints != 4 {
+		t.Errorf("Expected %d points stored in the children, got %d", 4, childrenPoints)
+	}
+}
+
+func TestVoxelNodeMaxDepthStoresAllPoints(t *testing.T) {
+	node := voxel_tree.NewVoxelNode(
+		nil,
+		geometry.NewLocalFrame(14, 41, 0),
+		geometry.NewBoundingBox(0, 4, 0, 4, 0, 4),
+		5.0,
+		&tiler.TilerOptions{CellMinSize: 0.001, GridMaxDepth: 1},
+		true,
+	)
+
+	for i := 0; i < 10; i++ {
+		node.AddDataPoint(data.NewPoint(1, 1, 1, 0, 0, 0, 0, 0))
+	}
+	node.(*voxel_tree.VoxelNode).BuildPoints()
+
+	child := node.GetChildren()[0]
+	if !child.IsLeaf() || len(child.GetPoints()) != 10 {
+		t.Errorf("Expected the child at max depth to be a leaf storing %d points", 10)
+	}
+}
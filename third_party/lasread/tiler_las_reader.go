@@ -7,12 +7,17 @@ package lidario
 
 import (
 	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/expression"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/internal/transform"
 	"io"
+	"math"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 )
 
 var recLengths = [11][4]int{
@@ -73,14 +78,51 @@ var classificationOffets = [11]int{
 	16, // Point format 10
 }
 
+// maximum number of points whose raw data is kept in memory at once while reading a las file
+const readChunkSize = 1 << 20
+
+// Returns the color of a point given its coordinates in the input srid, its 16 bit intensity as stored in the file and
+// its classification, false to keep the stored one
+type PointColorizer func(x, y, z float64, intensity uint16, classification uint8) (uint8, uint8, uint8, bool)
+
+// Options of a LasFileLoader selecting and transforming the points read from a las file
+type LasReaderOptions struct {
+	Transform   *transform.Affine          // Applied to the coordinates as soon as they are read, yielding coordinates in the input srid. nil if none
+	Accept      func(x, y, z float64) bool // Selects the points to store given their coordinates in the input srid, nil to store all of them
+	Filter      *tiler.PointFilter         // Selects the points to store by classification, returns and flags, nil to store all of them
+	Colorize    PointColorizer             // Computes the colors of the points, nil to keep the stored ones
+	Expressions *expression.Program        // Filters the points and computes their attributes, nil if none
+	Ordered     bool                       // Stores the points in the tree in the same order they appear in the file
+}
+
 type LasFileLoader struct {
 	Tree octree.ITree
+	LasReaderOptions
+	numberOfPoints int64
 }
 
-func NewLasFileLoader(tree octree.ITree) *LasFileLoader {
+// Creates a loader that stores the points of a las file in the given tree, reading them as set by the given options
+func NewLasFileLoader(tree octree.ITree, opts LasReaderOptions) *LasFileLoader {
 	return &LasFileLoader{
-		Tree: tree,
+		Tree:             tree,
+		LasReaderOptions: opts,
+	}
+}
+
+// Returns the number of points stored in the tree, i.e. the ones that passed all the filters
+func (lasFileLoader *LasFileLoader) NumberOfPoints() int64 {
+	return atomic.LoadInt64(&lasFileLoader.numberOfPoints)
+}
+
+// Reads only the header of the given las file, without loading its points
+func ReadLasHeader(fileName string) (*LasHeader, error) {
+	las, err := NewLasFile(fileName, "rh")
+	if err != nil {
+		return nil, err
 	}
+	defer func() { _ = las.Close() }()
+
+	return &las.Header, nil
 }
 
 // NewLasFile creates a new LasFile structure which stores the points data directly into Point instances
@@ -132,26 +174,37 @@ func (lasFileLoader *LasFileLoader) readForOctree(inSrid int, eightBitColor bool
 }
 
 // Reads all the points of the given las file and parses them into a Point data structure which is then stored
-// in the given LasFile instance
+// in the given LasFile instance. Points are read in chunks of readChunkSize points to bound the memory used
+// to hold the raw file content
 func (lasFileLoader *LasFileLoader) readPointsOctElem(inSrid int, eightBitColor bool, las *LasFile) error {
 	las.Lock()
 	defer las.Unlock()
-	// las.pointDataOctElement = make([]octree.OctElement, las.Header.NumberPoints)
-	if las.Header.PointFormatID == 1 || las.Header.PointFormatID == 3 {
-		// las.gpsData = make([]float64, las.Header.NumberPoints)
-	}
-	if las.Header.PointFormatID == 2 || las.Header.PointFormatID == 3 {
-		// las.rgbData = make([]RgbData, las.Header.NumberPoints)
+
+	chunkLength := readChunkSize
+	if las.Header.NumberPoints < chunkLength {
+		chunkLength = las.Header.NumberPoints
 	}
+	b := make([]byte, chunkLength*las.Header.PointRecordLength)
 
-	// Estimate how many bytes are used to store the points
-	pointsLength := las.Header.NumberPoints * las.Header.PointRecordLength
-	b := make([]byte, pointsLength)
-	if _, err := las.f.ReadAt(b, int64(las.Header.OffsetToPoints)); err != nil && err != io.EOF {
-		// return err
+	for chunkStart := 0; chunkStart < las.Header.NumberPoints; chunkStart += chunkLength {
+		numberOfPoints := chunkLength
+		if chunkStart+numberOfPoints > las.Header.NumberPoints {
+			numberOfPoints = las.Header.NumberPoints - chunkStart
+		}
+		chunk := b[:numberOfPoints*las.Header.PointRecordLength]
+		chunkOffset := int64(las.Header.OffsetToPoints) + int64(chunkStart)*int64(las.Header.PointRecordLength)
+		if _, err := las.f.ReadAt(chunk, chunkOffset); err != nil && err != io.EOF {
+			// return err
+		}
+		lasFileLoader.readChunk(inSrid, eightBitColor, las, chunk, numberOfPoints)
 	}
 
-    // The LAS Specifications state that:
+	return nil
+}
+
+// Parses in parallel the given number of points stored in the chunk and stores them in the tree
+func (lasFileLoader *LasFileLoader) readChunk(inSrid int, eightBitColor bool, las *LasFile, chunk []byte, numberOfPoints int) {
+	// The LAS Specifications state that:
 	// " Point data items that are not ‘Required’ must be set to
 	// the equivalent of zero for the data type (e.g. 0.0 for floating types, null for ASCII, 0 for integers)."
 	//
@@ -162,31 +215,71 @@ func (lasFileLoader *LasFileLoader) readPointsOctElem(inSrid int, eightBitColor
 	// imported and used in this project.
 
 	numCPUs := runtime.NumCPU()
+	if lasFileLoader.Ordered {
+		// a single block is parsed so that points are stored in the tree in file order
+		numCPUs = 1
+	}
 	var wg sync.WaitGroup
-	blockSize := las.Header.NumberPoints / numCPUs
+	blockSize := numberOfPoints / numCPUs
 	var startingPoint int
-	for startingPoint < las.Header.NumberPoints {
+	for startingPoint < numberOfPoints {
 		endingPoint := startingPoint + blockSize
-		if endingPoint >= las.Header.NumberPoints {
-			endingPoint = las.Header.NumberPoints - 1
+		if endingPoint >= numberOfPoints {
+			endingPoint = numberOfPoints - 1
 		}
 		wg.Add(1)
 		go func(pointSt, pointEnd int) {
 			defer wg.Done()
 
 			var offset int
-			// var p PointRecord0
 			for i := pointSt; i <= pointEnd; i++ {
 				offset = i * las.Header.PointRecordLength
-				X, Y, Z, R, G, B, Intensity, Classification := readPoint(&las.Header, b, offset, eightBitColor)
-				lasFileLoader.Tree.AddPoint(&geometry.Coordinate{X: X, Y: Y, Z: Z}, R, G, B, Intensity, Classification, inSrid)
-				// las.pointDataOctElement[i] = elem
+				X, Y, Z, R, G, B, Intensity, Classification := readPoint(&las.Header, chunk, offset, eightBitColor)
+				if lasFileLoader.Transform != nil {
+					X, Y, Z = lasFileLoader.Transform.Apply(X, Y, Z)
+				}
+				if lasFileLoader.Accept != nil && !lasFileLoader.Accept(X, Y, Z) {
+					continue
+				}
+				if lasFileLoader.Filter != nil {
+					returnNumber, numberOfReturns, withheld, synthetic := readReturnsAndFlags(&las.Header, chunk, offset)
+					if !lasFileLoader.Filter.Accepts(Classification, returnNumber, numberOfReturns, withheld, synthetic) {
+						continue
+					}
+				}
+				if lasFileLoader.Colorize != nil {
+					if r, g, b, ok := lasFileLoader.Colorize(X, Y, Z, ReadRecordIntensity(chunk[offset:]), Classification); ok {
+						R, G, B = r, g, b
+					}
+				}
+				var attributes []float32
+				if lasFileLoader.Expressions != nil {
+					returnNumber, numberOfReturns, _, _ := readReturnsAndFlags(&las.Header, chunk, offset)
+					gpsTime, scanAngle, pointSourceID, userData := readScanData(&las.Header, chunk, offset)
+					var accepted bool
+					attributes, accepted = lasFileLoader.Expressions.Evaluate(&expression.Values{
+						X: X, Y: Y, Z: Z,
+						R: R, G: G, B: B,
+						Intensity:       Intensity,
+						Classification:  Classification,
+						ReturnNumber:    returnNumber,
+						NumberOfReturns: numberOfReturns,
+						GpsTime:         gpsTime,
+						ScanAngle:       scanAngle,
+						PointSourceID:   pointSourceID,
+						UserData:        userData,
+					})
+					if !accepted {
+						continue
+					}
+				}
+				atomic.AddInt64(&lasFileLoader.numberOfPoints, 1)
+				lasFileLoader.Tree.AddPoint(&geometry.Coordinate{X: X, Y: Y, Z: Z}, R, G, B, Intensity, Classification, attributes, inSrid)
 			}
 		}(startingPoint, endingPoint)
 		startingPoint = endingPoint + 1
 	}
 	wg.Wait()
-	return nil
 }
 
 
@@ -221,6 +314,36 @@ func readPoint(header *LasHeader, data []byte, offset int, eightBitColor bool) (
 	intensity = uint8(binary.LittleEndian.Uint16(data[intensityOffset:intensityOffset+2]) / 256)
 	classificationOffset := classificationOffets[header.PointFormatID] + offset
 	classification = data[classificationOffset]
+	if header.PointFormatID < 6 {
+		// legacy formats store the synthetic, key-point and withheld flags in the upper bits of the classification
+		classification &= 0x1F
+	}
 
 	return x,y,z,r,g,b,intensity,classification
-}
\ No newline at end of file
+}
+
+// Reads the return number, the number of returns and the withheld and synthetic flags of the point at the given offset
+func readReturnsAndFlags(header *LasHeader, data []byte, offset int) (uint8, uint8, bool, bool) {
+	returns := data[offset+14]
+	flags := data[offset+15]
+	if header.PointFormatID < 6 {
+		return returns & 0x07, (returns >> 3) & 0x07, flags&0x80 != 0, flags&0x20 != 0
+	}
+	return returns & 0x0F, returns >> 4, flags&0x04 != 0, flags&0x01 != 0
+}
+
+// Reads the gps time, zero if the point format does not store it, the scan angle in degrees, the point source id and
+// the user data of the point at the given offset
+func readScanData(header *LasHeader, data []byte, offset int) (float64, float64, uint16, uint8) {
+	if header.PointFormatID < 6 {
+		var gpsTime float64
+		if header.PointFormatID != 0 && header.PointFormatID != 2 {
+			gpsTime = math.Float64frombits(binary.LittleEndian.Uint64(data[offset+20:]))
+		}
+		return gpsTime, float64(int8(data[offset+16])), binary.LittleEndian.Uint16(data[offset+18:]), data[offset+17]
+	}
+	// extended formats store the scan angle in increments of 0.006 degrees
+	scanAngle := float64(int16(binary.LittleEndian.Uint16(data[offset+18:]))) * 0.006
+	gpsTime := math.Float64frombits(binary.LittleEndian.Uint64(data[offset+22:]))
+	return gpsTime, scanAngle, binary.LittleEndian.Uint16(data[offset+20:]), data[offset+17]
+}
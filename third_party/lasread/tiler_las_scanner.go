@@ -0,0 +1,240 @@
+// Copyright 2019 Massimo Federico Bonfigli
+
+// This file contains definitions of helper functions reading the points of a las file in a single sequential pass,
+// used to gather statistics about a cloud and to split it in smaller las files before loading it
+
+package lidario
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/transform"
+	"io"
+	"math"
+	"os"
+)
+
+// byte offsets of the header fields updated when writing a subset of the points of a las file
+const (
+	legacyNumberOfPointsOffset   = 107
+	legacyPointsByReturnOffset   = 111
+	boundsOffset                 = 179
+	waveformDataOffset           = 227
+	firstEVLROffset              = 235
+	numberOfEVLRsOffset          = 243
+	numberOfPointsOffset         = 247
+	pointsByReturnOffset         = 255
+	extendedHeaderSize           = 375
+	legacyNumberOfReturnCounts   = 5
+	extendedNumberOfReturnCounts = 15
+)
+
+// Calls visit for every point of the given las file, in file order, with its coordinates, transformed with the given
+// transform if not nil, and its raw record. Points are read in chunks of readChunkSize points and the scan stops at
+// the first error returned by visit
+func ScanLasFile(fileName string, transform *transform.Affine, visit func(x, y, z float64, record []byte) error) error {
+	las, err := NewLasFile(fileName, "rh")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = las.Close() }()
+
+	return scanPoints(las, func(record []byte) error {
+		x, y, z := readCoordinates(&las.Header, record)
+		if transform != nil {
+			x, y, z = transform.Apply(x, y, z)
+		}
+		return visit(x, y, z, record)
+	})
+}
+
+// Returns the 16 bit intensity stored in the given raw point record, as is
+func ReadRecordIntensity(record []byte) uint16 {
+	return binary.LittleEndian.Uint16(record[12:14])
+}
+
+// Writes the points of the given las file in the given output files, each one storing the points for which assign
+// returns its index. Points for which assign returns a negative index are dropped. The output files share the header
+// and the variable length records of the input one, updated with their number of points, their number of points by
+// return and their bounds. Waveform packets and extended variable length records are not copied.
+// Assign is called with the coordinates of the points transformed with the given transform, if not nil. Returns the
+// number of points written in each output file
+func SplitLasFile(fileName string, transform *transform.Affine, outputs []string, assign func(x, y, z float64) int) ([]int, error) {
+	las, err := NewLasFile(fileName, "rh")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = las.Close() }()
+
+	prefix := make([]byte, las.Header.OffsetToPoints)
+	if _, err := las.f.ReadAt(prefix, 0); err != nil {
+		return nil, err
+	}
+
+	parts := make([]*lasPart, len(outputs))
+	defer func() {
+		for _, part := range parts {
+			if part != nil && part.file != nil {
+				_ = part.file.Close()
+			}
+		}
+	}()
+	for i, output := range outputs {
+		if parts[i], err = newLasPart(output, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	err = scanPoints(las, func(record []byte) error {
+		rawX, rawY, rawZ := readCoordinates(&las.Header, record)
+		x, y, z := rawX, rawY, rawZ
+		if transform != nil {
+			x, y, z = transform.Apply(x, y, z)
+		}
+		index := assign(x, y, z)
+		if index < 0 {
+			return nil
+		}
+		returnNumber, _, _, _ := readReturnsAndFlags(&las.Header, record, 0)
+		return parts[index].write(record, rawX, rawY, rawZ, returnNumber)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, len(parts))
+	for i, part := range parts {
+		if err := part.close(int(las.Header.VersionMinor)); err != nil {
+			return nil, err
+		}
+		counts[i] = part.numberOfPoints
+	}
+
+	return counts, nil
+}
+
+// calls visit with the raw record of every point of the given las file, in file order, reading readChunkSize points
+// at a time
+func scanPoints(las *LasFile, visit func(record []byte) error) error {
+	recordLength := las.Header.PointRecordLength
+	chunkLength := readChunkSize
+	if las.Header.NumberPoints < chunkLength {
+		chunkLength = las.Header.NumberPoints
+	}
+	b := make([]byte, chunkLength*recordLength)
+
+	for chunkStart := 0; chunkStart < las.Header.NumberPoints; chunkStart += chunkLength {
+		numberOfPoints := chunkLength
+		if chunkStart+numberOfPoints > las.Header.NumberPoints {
+			numberOfPoints = las.Header.NumberPoints - chunkStart
+		}
+		chunk := b[:numberOfPoints*recordLength]
+		chunkOffset := int64(las.Header.OffsetToPoints) + int64(chunkStart)*int64(recordLength)
+		if _, err := las.f.ReadAt(chunk, chunkOffset); err != nil && err != io.EOF {
+			return err
+		}
+		for offset := 0; offset < len(chunk); offset += recordLength {
+			if err := visit(chunk[offset : offset+recordLength]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// reads the coordinates stored in the given point record
+func readCoordinates(header *LasHeader, record []byte) (float64, float64, float64) {
+	offsets := xyzOffets[header.PointFormatID]
+	x := float64(int32(binary.LittleEndian.Uint32(record[offsets[0]:])))*header.XScaleFactor + header.XOffset
+	y := float64(int32(binary.LittleEndian.Uint32(record[offsets[1]:])))*header.YScaleFactor + header.YOffset
+	z := float64(int32(binary.LittleEndian.Uint32(record[offsets[2]:])))*header.ZScaleFactor + header.ZOffset
+	return x, y, z
+}
+
+// a las file being written with a subset of the points of another one
+type lasPart struct {
+	file           *os.File
+	writer         *bufio.Writer
+	header         []byte
+	numberOfPoints int
+	pointsByReturn [extendedNumberOfReturnCounts]int // number of points of each return number, from the first one
+	bounds         [6]float64                        // max and min of x, y and z, in the order they are stored in the header
+}
+
+// creates the given las file writing the given header and variable length records
+func newLasPart(fileName string, prefix []byte) (*lasPart, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	part := &lasPart{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		header: append([]byte(nil), prefix...),
+		bounds: [6]float64{math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1), math.Inf(1)},
+	}
+	if _, err := part.writer.Write(prefix); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return part, nil
+}
+
+// appends the given point record, whose untransformed coordinates are x, y and z
+func (p *lasPart) write(record []byte, x, y, z float64, returnNumber uint8) error {
+	p.numberOfPoints++
+	if returnNumber >= 1 && int(returnNumber) <= extendedNumberOfReturnCounts {
+		p.pointsByReturn[returnNumber-1]++
+	}
+	for i, value := range []float64{x, y, z} {
+		p.bounds[2*i] = math.Max(p.bounds[2*i], value)
+		p.bounds[2*i+1] = math.Min(p.bounds[2*i+1], value)
+	}
+	_, err := p.writer.Write(record)
+	return err
+}
+
+// flushes the points and rewrites the header of a las file of the given minor version with the number of points, the
+// number of points by return and the bounds of the part. The offsets of the waveform packets and of the extended
+// variable length records are cleared, as they are not copied
+func (p *lasPart) close(versionMinor int) error {
+	if err := p.writer.Flush(); err != nil {
+		return err
+	}
+
+	if p.numberOfPoints == 0 {
+		p.bounds = [6]float64{}
+	}
+	for i, value := range p.bounds {
+		binary.LittleEndian.PutUint64(p.header[boundsOffset+8*i:], math.Float64bits(value))
+	}
+	extendedCount := versionMinor >= 4 && len(p.header) >= extendedHeaderSize
+	// the legacy counts are zero in las 1.4 files storing more points than they can represent or extended point formats
+	if !extendedCount || binary.LittleEndian.Uint32(p.header[legacyNumberOfPointsOffset:]) != 0 {
+		binary.LittleEndian.PutUint32(p.header[legacyNumberOfPointsOffset:], uint32(p.numberOfPoints))
+		for i := 0; i < legacyNumberOfReturnCounts; i++ {
+			binary.LittleEndian.PutUint32(p.header[legacyPointsByReturnOffset+4*i:], uint32(p.pointsByReturn[i]))
+		}
+	}
+	if versionMinor >= 3 && len(p.header) >= firstEVLROffset {
+		binary.LittleEndian.PutUint64(p.header[waveformDataOffset:], 0)
+	}
+	if extendedCount {
+		binary.LittleEndian.PutUint64(p.header[firstEVLROffset:], 0)
+		binary.LittleEndian.PutUint32(p.header[numberOfEVLRsOffset:], 0)
+		binary.LittleEndian.PutUint64(p.header[numberOfPointsOffset:], uint64(p.numberOfPoints))
+		for i, count := range p.pointsByReturn {
+			binary.LittleEndian.PutUint64(p.header[pointsByReturnOffset+8*i:], uint64(count))
+		}
+	}
+	if _, err := p.file.WriteAt(p.header, 0); err != nil {
+		return err
+	}
+
+	file := p.file
+	p.file = nil
+	return file.Close()
+}
@@ -12,6 +12,9 @@ type Flags struct {
 	ZOffset                   *float64
 	MaxNumPts                 *int
 	ZGeoidCorrection          *bool
+	GeoidModel                *string
+	GeoidDegree               *int
+	GeoidGrid                 *string
 	FolderProcessing          *bool
 	RecursiveFolderProcessing *bool
 	Silent                    *bool
@@ -19,7 +22,47 @@ type Flags struct {
 	Algorithm                 *string
 	GridCellMaxSize           *float64
 	GridCellMinSize           *float64
+	GridMaxNumPts             *int
+	GridMaxDepth              *int
+	GridBulkBuild             *bool
+	PriorityClasses           *string
+	GridScore                 *string
 	RefineMode                *string
+	MaxMemory                 *int
+	AutoSplit                 *bool
+	Deterministic             *bool
+	Seed                      *int
+	BoundsPercentile          *float64
+	OutlierSigma              *float64
+	NoiseRadius               *float64
+	NoiseMinNeighbours        *int
+	NoiseKNearest             *int
+	NoiseMaxMeanDistance      *float64
+	IncludeClasses            *string
+	ExcludeClasses            *string
+	Returns                   *string
+	ExcludeWithheld           *bool
+	ExcludeSynthetic          *bool
+	CropBox                   *string
+	CropPolygon               *string
+	CropSrid                  *int
+	Expressions               *string
+	ExpressionsFile           *string
+	Colorize                  *string
+	ColorizeSrid              *int
+	ColorMode                 *string
+	ColorRamp                 *string
+	ColorMin                  *float64
+	ColorMax                  *float64
+	HeightAboveGround         *bool
+	GroundCellSize            *float64
+	CurvatureRadius           *float64
+	Transform                 *string
+	TransformConvention       *string
+	ControlPoints             *string
+	ControlPointsCheck        *bool
+	LocalOrigin               *string
+	LocalHeading              *float64
 	Help                      *bool
 	Version                   *bool
 }
@@ -30,16 +73,59 @@ func ParseFlags() Flags {
 	srid := defineIntFlag("srid", "e", 4326, "EPSG srid code of input points.")
 	eightBit := defineBoolFlag("8bit", "b", false, "Assumes the input LAS has colors encoded in eight bit format. Default is false (LAS has 16 bit color depth)")
 	zOffset := defineFloat64Flag("zoffset", "z", 0, "Vertical offset to apply to points, in meters.")
-	maxNumPts := defineIntFlag("maxpts", "m", 50000, "Max number of points per tile for the Random and RandomBox algorithms.")
+	maxNumPts := defineIntFlag("maxpts", "m", 50000, "Max number of points per tile for the KdTree, Random and RandomBox algorithms.")
 	zGeoidCorrection := defineBoolFlag("geoid", "g", false, "Enables Geoid to Ellipsoid elevation correction. Use this flag if your input LAS files have Z coordinates specified relative to the Earth geoid rather than to the standard ellipsoid.")
+	geoidModel := defineStringFlag("geoid-model", "", "egm180", "Spherical harmonic geoid model used by the geoid flag. Can be egm180, the built-in one, or the path of a file of normalized coefficients such as the EGM96 or EGM2008 ones, one per line as degree, order, C and S.")
+	geoidDegree := defineIntFlag("geoid-degree", "", 0, "Degree the geoid model is truncated at. 0 means the max degree of the model, 179 for egm180.")
+	geoidGrid := defineStringFlag("geoid-grid", "", "", "Path of a GTX, BYN or GeoTIFF geoid grid, e.g. EGM96 or EGM2008, whose undulations convert the elevations above the geoid to elevations above the ellipsoid. Used in place of the spherical harmonic model of the geoid flag.")
 	folderProcessing := defineBoolFlag("folder", "f", false, "Enables processing of all las files from input folder. Input must be a folder if specified")
 	recursiveFolderProcessing := defineBoolFlag("recursive", "r", false, "Enables recursive lookup for all .las files inside the subfolders")
 	silent := defineBoolFlag("silent", "s", false, "Use to suppress all the non-error messages.")
 	logTimestamp := defineBoolFlag("timestamp", "t", false, "Adds timestamp to log messages.")
-	algorithm := defineStringFlag("algorithm", "a", "grid", "Sets the algorithm to use. Must be one of Grid,Random,RandomBox. Grid algorithm is highly suggested, others are deprecated and will be removed in future versions.")
+	algorithm := defineStringFlag("algorithm", "a", "grid", "Sets the algorithm to use. Must be one of Grid,Quadtree,KdTree,Voxel,Poisson,Random,RandomBox. Grid algorithm is highly suggested, Random and RandomBox are deprecated and will be removed in future versions.")
 	gridCellMaxSize := defineFloat64Flag("grid-max-size", "x", 5.0, "Max cell size in meters for the grid algorithm. It roughly represents the max spacing between any two samples. ")
 	gridCellMinSize := defineFloat64Flag("grid-min-size", "n", 0.15, "Min cell size in meters for the grid algorithm. It roughly represents the minimum possible size of a 3d tile. ")
+	gridMaxNumPts := defineIntFlag("grid-max-points", "", 0, "Max number of points per tile for the grid algorithm. Tiles reaching the limit push further points to their children. 0 means unlimited.")
+	gridMaxDepth := defineIntFlag("grid-max-depth", "", 0, "Max depth of the tree for the grid algorithm. Tiles at the max depth store all the points they receive, ignoring grid-max-points. 0 means unlimited.")
+	gridBulkBuild := defineBoolFlag("grid-bulk-build", "", false, "Builds the grid tree in bulk once all points are read: the sampling of each subtree is replayed in parallel without locks. Samples points as the grid algorithm, but is faster on machines with many cores.")
+	priorityClasses := defineStringFlag("priority-classes", "", "", "Comma separated list of classifications that grid cells of the Grid and Quadtree algorithms prefer when picking the point to store, regardless of its distance from the cell center, so that sparse features such as power lines stay visible at coarse levels. Each classification can be followed by a colon and a priority, e.g. 14:2,15. Default priority is 1, unlisted classifications have priority 0.")
+	gridScore := defineStringFlag("grid-score", "", "center", "Importance score used by the grid cells of the Grid and Quadtree algorithms to pick the point to store. Can be center, to keep the point closest to the cell center, intensity, red, green, blue or luminance, to keep the point with the highest value of the attribute, curvature, to keep the point with the highest local curvature, stored in the Curvature batch table property, or attribute:NAME, to keep the point with the highest value of the attribute NAME computed by expressions or height-above-ground. Ties are resolved keeping the point closest to the center.")
 	refineMode := defineStringFlag("refine-mode", "", "ADD", "Type of refine mode, can be 'ADD' or 'REPLACE'. 'ADD' means that child tiles will not contain the parent tiles points. 'REPLACE' means that they will also contain the parent tiles points. ADD implies less disk space but more network overhead when fetching the data, REPLACE is the opposite.")
+	maxMemory := defineIntFlag("max-memory", "", 0, "Memory budget in MB. Before reading the points the memory needed to process each input file is estimated from its header, if the budget is exceeded the file is either refused or, if auto-split is enabled, processed in smaller spatial partitions. 0 disables the check.")
+	autoSplit := defineBoolFlag("auto-split", "", false, "Splits the input files that would exceed the max-memory budget in smaller jobs of similar numbers of points, each producing its own tileset, joined by a parent tileset.json. The points of each job are first written to a temporary las file in the output folder. Not supported together with the noise filters, curvature and height-above-ground, which need the neighbours of the points across the job borders.")
+	deterministic := defineBoolFlag("deterministic", "", false, "Generates byte-identical tilesets from the same input and options, at the cost of a slower processing. Points are loaded in file order by a single worker and random sampling uses the given seed.")
+	seed := defineIntFlag("seed", "", 1, "Seed of the random number generators used to shuffle and sample points in deterministic mode. Ignored if deterministic is not set.")
+	boundsPercentile := defineFloat64Flag("bounds-percentile", "", 0, "Computes the bounds of the root tile between the given lower and upper percentiles of the coordinates along each axis, e.g. 0.1 uses the 0.1th and 99.9th percentiles. Points outside the bounds are kept in the tiles closest to them. 0 uses the full extent of the cloud.")
+	outlierSigma := defineFloat64Flag("outlier-sigma", "", 0, "Discards as outliers the points farther than the given number of standard deviations from the mean coordinates of the cloud along any axis, before the bounds of the tree are computed. 0 disables the rejection.")
+	noiseRadius := defineFloat64Flag("noise-radius", "", 1, "Radius in meters of the neighbourhood used by the noise-min-neighbours filter.")
+	noiseMinNeighbours := defineIntFlag("noise-min-neighbours", "", 0, "Removes the noise points having less than the given number of neighbours within noise-radius. Not supported by the Random and RandomBox algorithms. 0 disables the filter.")
+	noiseKNearest := defineIntFlag("noise-knn", "", 0, "Removes the noise points whose mean distance from their given number of nearest neighbours exceeds noise-knn-max-distance. Not supported by the Random and RandomBox algorithms. 0 disables the filter.")
+	noiseMaxMeanDistance := defineFloat64Flag("noise-knn-max-distance", "", 1, "Max mean distance in meters of a point from its noise-knn nearest neighbours.")
+	includeClasses := defineStringFlag("include-classes", "", "", "Comma separated list of classifications to process, e.g. 2,6. Points with other classifications are discarded. Empty processes all classifications.")
+	excludeClasses := defineStringFlag("exclude-classes", "", "", "Comma separated list of classifications to discard, e.g. 7,18.")
+	returns := defineStringFlag("returns", "", "all", "Returns to process. Can be all, first, last or only, to process only the points of single return pulses.")
+	excludeWithheld := defineBoolFlag("exclude-withheld", "", false, "Discards the points flagged as withheld.")
+	excludeSynthetic := defineBoolFlag("exclude-synthetic", "", false, "Discards the points flagged as synthetic.")
+	cropBox := defineStringFlag("crop-box", "", "", "Discards the points outside the given box, expressed as xmin,ymin,xmax,ymax or, to also crop along Z, as xmin,ymin,xmax,ymax,zmin,zmax. Z is always expressed in the vertical datum of the input points.")
+	cropPolygon := defineStringFlag("crop-polygon", "", "", "Discards the points outside the given polygon or multipolygon, expressed as WKT or GeoJSON or as the path of a file containing them.")
+	cropSrid := defineIntFlag("crop-srid", "", 0, "EPSG srid code of the crop-box and crop-polygon coordinates. 0 means the srid of the input points.")
+	expressions := defineStringFlag("expressions", "", "", "Filters and computed attributes to evaluate on the points, separated by semicolons, e.g. \"Z > 10; HeightBand = floor(Z / 10)\".")
+	expressionsFile := defineStringFlag("expressions-file", "", "", "Path of a file containing filters and computed attributes to evaluate on the points, one per line.")
+	colorize := defineStringFlag("colorize", "", "", "Path of a GeoTIFF, or of a TIFF, PNG, JPEG or GIF image with a world file, whose colors are assigned to the points.")
+	colorizeSrid := defineIntFlag("colorize-srid", "", 0, "EPSG srid code of the colorize raster. 0 means the srid read from the GeoTIFF.")
+	colorMode := defineStringFlag("color-mode", "", "las", "Colors of the points. Can be las, to keep the colors stored in the las files, elevation, intensity or height-above-ground, to color the points along a ramp by their elevation, intensity or height above ground, or classification, to use the palette of the ASPRS classifications.")
+	colorRamp := defineStringFlag("color-ramp", "", "", "Ramp of the elevation, intensity and height-above-ground color modes. Can be grayscale, hypsometric, viridis or rainbow. Empty uses hypsometric for elevation, grayscale for intensity and viridis for height-above-ground.")
+	colorMin := defineFloat64Flag("color-min", "", 0, "Elevation, intensity, in the 16 bit units stored in the las files, or height above ground mapped to the start of the color ramp. Must be lower than color-max. If neither is given the range is computed automatically, except for height-above-ground which uses 0 to 30 meters.")
+	colorMax := defineFloat64Flag("color-max", "", 0, "Elevation, intensity, in the 16 bit units stored in the las files, or height above ground mapped to the end of the color ramp. Must be greater than color-min. If neither is given the range is computed automatically, except for height-above-ground which uses 0 to 30 meters.")
+	heightAboveGround := defineBoolFlag("height-above-ground", "", false, "Computes the height of the points above a terrain model built from the points classified as ground, stored in the HeightAboveGround batch table property. Not supported by the Random and RandomBox algorithms.")
+	groundCellSize := defineFloat64Flag("ground-cell-size", "", 1, "Size in meters of the cells of the terrain model used by height-above-ground.")
+	curvatureRadius := defineFloat64Flag("curvature-radius", "", 1, "Radius in meters of the neighbourhood the local curvature of the points is computed on with the curvature grid-score.")
+	transform := defineStringFlag("transform", "", "", "Transform applied to the coordinates of the input points before converting them from the input srid. Can be 7 Helmert parameters tx,ty,tz,rx,ry,rz,s with translations in meters, rotations in arc seconds and scale in ppm, or the 12 or 16 values of a 4x4 affine matrix in row order, or the path of a file containing them.")
+	transformConvention := defineStringFlag("transform-convention", "", "position-vector", "Sign convention of the rotations of the Helmert transform. Can be position-vector or coordinate-frame.")
+	controlPoints := defineStringFlag("gcp", "", "", "Path of a CSV file of ground control points, each with its local x,y,z and its x,y,z in the input srid, optionally preceded by its name. The similarity transform best fitting them is applied to the input points as with the transform flag, and its residuals are reported.")
+	controlPointsCheck := defineBoolFlag("gcp-check", "", false, "Solves the transform of the gcp flag, prints the residual of each control point, their RMS and the transform matrix, then exits without tiling.")
+	localOrigin := defineStringFlag("local-origin", "", "", "Treats the input coordinates as metric local X,Y,Z placed at the given longitude,latitude,height, in degrees and meters above the WGS84 ellipsoid, instead of converting them from the input srid. The tileset is placed with a root transform.")
+	localHeading := defineFloat64Flag("local-heading", "", 0, "Heading of the local Y axis, in degrees clockwise from north, when using local-origin.")
 	help := defineBoolFlag("help", "h", false, "Displays this help.")
 	version := defineBoolFlag("version", "v", false, "Displays the version of gocesiumtiler.")
 
@@ -53,6 +139,9 @@ func ParseFlags() Flags {
 		ZOffset:                   zOffset,
 		MaxNumPts:                 maxNumPts,
 		ZGeoidCorrection:          zGeoidCorrection,
+		GeoidModel:                geoidModel,
+		GeoidDegree:               geoidDegree,
+		GeoidGrid:                 geoidGrid,
 		FolderProcessing:          folderProcessing,
 		RecursiveFolderProcessing: recursiveFolderProcessing,
 		Silent:                    silent,
@@ -60,7 +149,47 @@ func ParseFlags() Flags {
 		Algorithm:                 algorithm,
 		GridCellMaxSize:           gridCellMaxSize,
 		GridCellMinSize:           gridCellMinSize,
+		GridMaxNumPts:             gridMaxNumPts,
+		GridMaxDepth:              gridMaxDepth,
+		GridBulkBuild:             gridBulkBuild,
+		PriorityClasses:           priorityClasses,
+		GridScore:                 gridScore,
 		RefineMode:                refineMode,
+		MaxMemory:                 maxMemory,
+		AutoSplit:                 autoSplit,
+		Deterministic:             deterministic,
+		Seed:                      seed,
+		BoundsPercentile:          boundsPercentile,
+		OutlierSigma:              outlierSigma,
+		NoiseRadius:               noiseRadius,
+		NoiseMinNeighbours:        noiseMinNeighbours,
+		NoiseKNearest:             noiseKNearest,
+		NoiseMaxMeanDistance:      noiseMaxMeanDistance,
+		IncludeClasses:            includeClasses,
+		ExcludeClasses:            excludeClasses,
+		Returns:                   returns,
+		ExcludeWithheld:           excludeWithheld,
+		ExcludeSynthetic:          excludeSynthetic,
+		CropBox:                   cropBox,
+		CropPolygon:               cropPolygon,
+		CropSrid:                  cropSrid,
+		Expressions:               expressions,
+		ExpressionsFile:           expressionsFile,
+		Colorize:                  colorize,
+		ColorizeSrid:              colorizeSrid,
+		ColorMode:                 colorMode,
+		ColorRamp:                 colorRamp,
+		ColorMin:                  colorMin,
+		ColorMax:                  colorMax,
+		HeightAboveGround:         heightAboveGround,
+		GroundCellSize:            groundCellSize,
+		CurvatureRadius:           curvatureRadius,
+		Transform:                 transform,
+		TransformConvention:       transformConvention,
+		ControlPoints:             controlPoints,
+		ControlPointsCheck:        controlPointsCheck,
+		LocalOrigin:               localOrigin,
+		LocalHeading:              localHeading,
 		Help:                      help,
 		Version:                   version,
 	}
@@ -79,7 +208,7 @@ func defineStringFlag(name string, shortHand string, defaultValue string, usage
 func defineIntFlag(name string, shortHand string, defaultValue int, usage string) *int {
 	var output int
 	flag.IntVar(&output, name, defaultValue, usage)
-	if shortHand != name {
+	if shortHand != name && shortHand != "" {
 		flag.IntVar(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 
@@ -89,7 +218,7 @@ func defineIntFlag(name string, shortHand string, defaultValue int, usage string
 func defineFloat64Flag(name string, shortHand string, defaultValue float64, usage string) *float64 {
 	var output float64
 	flag.Float64Var(&output, name, defaultValue, usage)
-	if shortHand != name {
+	if shortHand != name && shortHand != "" {
 		flag.Float64Var(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 	return &output
@@ -98,8 +227,19 @@ func defineFloat64Flag(name string, shortHand string, defaultValue float64, usag
 func defineBoolFlag(name string, shortHand string, defaultValue bool, usage string) *bool {
 	var output bool
 	flag.BoolVar(&output, name, defaultValue, usage)
-	if shortHand != name {
+	if shortHand != name && shortHand != "" {
 		flag.BoolVar(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 	return &output
 }
+
+// Checks if the flag with the given name, not its shorthand, has been explicitly set on the command line
+func IsFlagSet(name string) bool {
+	isSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			isSet = true
+		}
+	})
+	return isSet
+}